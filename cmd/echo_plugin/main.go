@@ -0,0 +1,105 @@
+// Command echo_plugin is the reference plugin of the exec provider: it recognizes
+// one word per chunk of audio, named after the chunk, and shows every message of
+// the protocol described in package plugin.
+//
+//	go build -o echo_plugin ./cmd/echo_plugin
+//	speech_latency benchmark -a audio.wav -p exec --exec ./echo_plugin --interim
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/elishowk/speech_latency/pkg/providers/plugin"
+)
+
+func main() {
+	finalEvery := flag.Int("final-every", 5, "Chunks per utterance")
+	failWith := flag.String("fail", "", "Refuse the configuration with this reason")
+	flag.Parse()
+
+	if err := run(*finalEvery, *failWith); err != nil {
+		fmt.Fprintf(os.Stderr, "echo_plugin: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(finalEvery int, failWith string) error {
+	in := bufio.NewScanner(os.Stdin)
+	in.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	out := json.NewEncoder(os.Stdout)
+
+	// The configuration comes first and is answered before any audio
+	if !in.Scan() {
+		return fmt.Errorf("no configuration: %v", in.Err())
+	}
+	var config plugin.ConfigMessage
+	if err := json.Unmarshal(in.Bytes(), &config); err != nil || config.Type != plugin.TypeConfig {
+		return fmt.Errorf("invalid configuration %q", in.Text())
+	}
+	if failWith != "" {
+		out.Encode(plugin.EventMessage{Type: plugin.TypeError, Message: failWith})
+		return fmt.Errorf("configuration refused: %s", failWith)
+	}
+	if err := out.Encode(plugin.EventMessage{Type: plugin.TypeReady}); err != nil {
+		return err
+	}
+	bytesPerSecond := float64(config.SampleRate * max(config.Channels, 1) * 2)
+
+	var utterance []plugin.Word
+	final := func() error {
+		if len(utterance) == 0 {
+			return nil
+		}
+		if err := out.Encode(plugin.EventMessage{Type: plugin.TypeFinal, Transcript: transcript(utterance), Words: utterance}); err != nil {
+			return err
+		}
+		utterance = nil
+		return out.Encode(plugin.EventMessage{Type: plugin.TypeUtteranceEnd})
+	}
+
+	for in.Scan() {
+		var msg plugin.AudioMessage
+		if err := json.Unmarshal(in.Bytes(), &msg); err != nil {
+			return fmt.Errorf("invalid audio message: %w", err)
+		}
+		if msg.Type == plugin.TypeEnd {
+			break
+		}
+
+		utterance = append(utterance, plugin.Word{
+			Word:       fmt.Sprintf("chunk%d", msg.Seq),
+			Start:      msg.Offset,
+			End:        msg.Offset + float64(len(msg.Data))/bytesPerSecond,
+			Confidence: 1,
+		})
+		if len(utterance) == finalEvery {
+			if err := final(); err != nil {
+				return err
+			}
+			continue
+		}
+		if config.Interim {
+			if err := out.Encode(plugin.EventMessage{Type: plugin.TypeInterim, Transcript: transcript(utterance), Words: utterance}); err != nil {
+				return err
+			}
+		}
+	}
+	if err := in.Err(); err != nil {
+		return err
+	}
+	return final()
+}
+
+// transcript joins the words of an utterance
+func transcript(words []plugin.Word) string {
+	texts := make([]string, len(words))
+	for i, w := range words {
+		texts[i] = w.Word
+	}
+	return strings.Join(texts, " ")
+}
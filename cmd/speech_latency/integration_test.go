@@ -1,10 +1,13 @@
 package main
 
 import (
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"strings"
 	"testing"
+
+	"github.com/elishowk/speech_latency/pkg/mockserver"
 )
 
 func TestCLIIntegration_AudioFileExists(t *testing.T) {
@@ -136,21 +139,25 @@ func TestCLIIntegration_FlagParsing(t *testing.T) {
 		},
 	}
 	
+	// Runs go to a local mock server instead of the Deepgram API
+	srv := httptest.NewServer(mockserver.New(mockserver.Config{}))
+	defer srv.Close()
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			if tt.skip {
 				t.Skip(tt.skipReason)
 			}
 			
-			// Set a short timeout to avoid waiting for actual API calls
+			// Set a short timeout to avoid hanging on a broken run
 			cmd := exec.Command("timeout", "5s", "go", "run", "main.go")
 			cmd.Args = append(cmd.Args, tt.args...)
 			cmd.Dir = "."
 			
 			// Set a fake API key to avoid API key errors
-			cmd.Env = append(os.Environ(), "DEEPGRAM_API_KEY=fake-key-for-testing")
+			cmd.Env = append(os.Environ(), "DEEPGRAM_API_KEY=fake-key-for-testing", "DEEPGRAM_BASE_URL="+srv.URL)
 			
-			output, _ := cmd.CombinedOutput()
+			output, err := cmd.CombinedOutput()
 			
 			// We expect this to fail due to timeout or API error, but not due to flag parsing
 			outputStr := string(output)
@@ -167,6 +174,10 @@ func TestCLIIntegration_FlagParsing(t *testing.T) {
 			if strings.Contains(outputStr, "invalid argument") {
 				t.Errorf("invalid argument error: %s", outputStr)
 			}
+
+			if err != nil {
+				t.Errorf("benchmark against the mock server failed: %v\n%s", err, outputStr)
+			}
 		})
 	}
 }
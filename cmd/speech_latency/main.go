@@ -3,13 +3,27 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/elishowk/speech_latency/internal/config"
 	"github.com/elishowk/speech_latency/pkg/audio"
+	"github.com/elishowk/speech_latency/pkg/bench"
+	"github.com/elishowk/speech_latency/pkg/metrics"
+	"github.com/elishowk/speech_latency/pkg/mockserver"
 	"github.com/elishowk/speech_latency/pkg/providers"
+	"github.com/elishowk/speech_latency/pkg/report"
+	"github.com/elishowk/speech_latency/pkg/scenario"
+	"github.com/elishowk/speech_latency/pkg/scoring"
+	"github.com/elishowk/speech_latency/pkg/session"
 	"github.com/spf13/cobra"
 )
 
@@ -18,6 +32,7 @@ var rootCmd = &cobra.Command{
 	Short: "A CLI tool for measuring speech processing latency",
 	Long: `speech_latency is a command-line tool designed to measure and benchmark
 speech processing latency across different models and configurations.`,
+	SilenceErrors: true,
 }
 
 func init() {
@@ -28,17 +43,54 @@ func init() {
 
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(benchmarkCmd)
+	rootCmd.AddCommand(loadCmd)
+	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(mockServerCmd)
 
 	// Add flags for the benchmark command
-	benchmarkCmd.Flags().StringP("provider", "p", config.GetEnvWithDefault("DEFAULT_PROVIDER", "deepgram"), "Speech recognition provider (deepgram, etc.)")
-	benchmarkCmd.Flags().StringP("audio", "a", "", "Path to the WAV audio file")
-	benchmarkCmd.Flags().IntP("chunk-size", "s", getEnvInt("DEFAULT_CHUNK_SIZE", audio.DefaultChunkSize), "Size of audio chunks in bytes")
-	benchmarkCmd.Flags().IntP("chunk-interval", "i", getEnvInt("DEFAULT_CHUNK_INTERVAL", int(audio.DefaultChunkInterval/time.Millisecond)), "Interval between chunks in milliseconds")
-	benchmarkCmd.Flags().StringP("language", "l", config.GetEnvWithDefault("DEFAULT_LANGUAGE", "en-US"), "Language code")
-	benchmarkCmd.Flags().Bool("interim", true, "Enable interim results")
-	benchmarkCmd.Flags().Bool("punctuate", true, "Enable punctuation")
-	benchmarkCmd.Flags().Bool("smart-format", true, "Enable smart formatting")
+	addStreamFlags(benchmarkCmd)
+	benchmarkCmd.Flags().Bool("per-word", false, "Print the latency of every recognized word")
+	benchmarkCmd.Flags().IntP("runs", "n", getEnvInt("DEFAULT_RUNS", 1), "Number of measured runs")
+	benchmarkCmd.Flags().Int("warmup", getEnvInt("DEFAULT_WARMUP", 0), "Number of warmup runs discarded before measuring")
+	benchmarkCmd.Flags().StringP("reference", "r", "", "Reference transcript, as a text file path or inline text, to score accuracy")
+	benchmarkCmd.Flags().Bool("ignore-case", true, "Ignore case when scoring against the reference")
+	benchmarkCmd.Flags().Bool("ignore-punctuation", true, "Ignore punctuation when scoring against the reference")
+	benchmarkCmd.Flags().String("numbers", string(scoring.NumbersDigits), "Number normalization when scoring (keep, digits, words)")
+	addOutputFlags(benchmarkCmd)
 	benchmarkCmd.MarkFlagRequired("audio")
+
+	// Add flags for the load command
+	addStreamFlags(loadCmd)
+	loadCmd.Flags().IntP("concurrency", "c", getEnvInt("DEFAULT_CONCURRENCY", 10), "Maximum number of simultaneous streams")
+	loadCmd.Flags().Duration("ramp-up", 0, "Time over which streams start, e.g. 30s")
+	loadCmd.Flags().Duration("duration", 0, "Keep starting streams for this long, e.g. 5m (0 runs each stream once)")
+	loadCmd.Flags().Float64("arrival-rate", 0, "New streams per second, skipped while all streams are busy (0 keeps every stream busy)")
+	loadCmd.Flags().Bool("per-stream", false, "Print the latencies of every stream")
+	addOutputFlags(loadCmd)
+	loadCmd.MarkFlagRequired("audio")
+
+	// Add flags for the run command, the stream flags are the defaults of every cell
+	addStreamFlags(runCmd)
+	runCmd.Flags().String("scenario", "", "Path to the YAML scenario file")
+	runCmd.Flags().IntP("runs", "n", getEnvInt("DEFAULT_RUNS", 1), "Number of measured runs of cells that don't set runs")
+	runCmd.Flags().Int("warmup", getEnvInt("DEFAULT_WARMUP", 0), "Number of warmup runs of cells that don't set warmup")
+	runCmd.Flags().Bool("dry-run", false, "List the cells of the scenario without running them")
+	addOutputFlags(runCmd)
+	runCmd.MarkFlagRequired("scenario")
+
+	// Add flags for the mock-server command
+	mockServerCmd.Flags().String("addr", "localhost:8090", "Address to listen on")
+	mockServerCmd.Flags().String("transcript", mockserver.DefaultTranscript, "Transcript returned for any audio")
+	mockServerCmd.Flags().String("transcript-file", "", "Read the transcript from this file, overrides --transcript")
+	mockServerCmd.Flags().Duration("latency", 0, "Delay of every response after the audio it covers, e.g. 300ms")
+	mockServerCmd.Flags().Duration("jitter", 0, "Random extra delay of every response, up to this duration")
+	mockServerCmd.Flags().Int("interim-ms", int(mockserver.DefaultInterimEvery/time.Millisecond), "Audio in milliseconds between two interim results on the live API")
+	mockServerCmd.Flags().Int("final-ms", int(mockserver.DefaultFinalEvery/time.Millisecond), "Audio in milliseconds between two final results on the live API")
+	mockServerCmd.Flags().Int("word-ms", int(mockserver.DefaultWordDuration/time.Millisecond), "Audio in milliseconds each word lasts on the live API")
+	mockServerCmd.Flags().Int("error-code", 0, "HTTP status returned by failing requests (0 never fails)")
+	mockServerCmd.Flags().Float64("error-rate", 1, "Fraction of requests failing with --error-code")
+	mockServerCmd.Flags().String("api-key", "", "API key required from clients (empty accepts any)")
+	mockServerCmd.Flags().Int64("seed", 1, "Seed of the jitter and error draws")
 }
 
 // getEnvInt gets an integer environment variable with a default value
@@ -62,80 +114,919 @@ var versionCmd = &cobra.Command{
 var benchmarkCmd = &cobra.Command{
 	Use:   "benchmark",
 	Short: "Run a speech latency benchmark",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Flags are valid past this point, runtime errors don't need the usage text
+		cmd.SilenceUsage = true
+
+		opts, err := streamOptions(cmd)
+		if err != nil {
+			return err
+		}
+
 		// Get command line flags
-		providerName, _ := cmd.Flags().GetString("provider")
-		audioPath, _ := cmd.Flags().GetString("audio")
-		chunkSize, _ := cmd.Flags().GetInt("chunk-size")
-		chunkInterval, _ := cmd.Flags().GetInt("chunk-interval")
-		language, _ := cmd.Flags().GetString("language")
-		interim, _ := cmd.Flags().GetBool("interim")
-		punctuate, _ := cmd.Flags().GetBool("punctuate")
-		smartFormat, _ := cmd.Flags().GetBool("smart-format")
-
-		// Create WAV streamer
-		streamer, err := audio.NewWAVStreamer(audioPath, chunkSize, time.Duration(chunkInterval)*time.Millisecond)
-		if err != nil {
-			fmt.Printf("Error creating WAV streamer: %v\n", err)
-			os.Exit(1)
-		}
-		defer streamer.Close()
-
-		// Get audio format details
-		sampleRate, channels, _ := streamer.GetAudioFormat()
-		fmt.Printf("Audio format: %d Hz, %d channels\n", sampleRate, channels)
-
-		// Create provider configuration
-		providerConfig := &providers.Config{
-			SampleRate:  sampleRate,
-			Channels:    channels,
+		perWord, _ := cmd.Flags().GetBool("per-word")
+		runs, _ := cmd.Flags().GetInt("runs")
+		warmup, _ := cmd.Flags().GetInt("warmup")
+		referenceFlag, _ := cmd.Flags().GetString("reference")
+		ignoreCase, _ := cmd.Flags().GetBool("ignore-case")
+		ignorePunctuation, _ := cmd.Flags().GetBool("ignore-punctuation")
+		numbersFlag, _ := cmd.Flags().GetString("numbers")
+
+		numbers, err := scoring.ParseNumberMode(numbersFlag)
+		if err != nil {
+			return err
+		}
+		reference, err := loadReference(referenceFlag)
+		if err != nil {
+			return err
+		}
+
+		if runs < 1 {
+			return fmt.Errorf("runs must be at least 1, got %d", runs)
+		}
+		if warmup < 0 {
+			return fmt.Errorf("warmup must not be negative, got %d", warmup)
+		}
+
+		output, err := openOutput(cmd)
+		if err != nil {
+			return err
+		}
+		defer output.Close()
+		out, progress, records := output.out, output.progress, output.records
+
+		recorder, closeRecord, err := openRecord(cmd)
+		if err != nil {
+			return err
+		}
+		defer closeRecord()
+
+		// Check the audio file before anything else
+		if err := probeAudio(progress, opts); err != nil {
+			return err
+		}
+
+		// Get provider endpoint and API key
+		factory := providers.NewFactory()
+		if err := resolveEndpoint(&opts, factory); err != nil {
+			return err
+		}
+		providerName := opts.Provider
+
+		// Warmup runs prime connections and caches, their results are discarded
+		for i := 0; i < warmup; i++ {
+			fmt.Fprintf(progress, "Warmup run %d/%d with %s provider...\n", i+1, warmup, providerName)
+			if _, err := bench.RunOnce(context.Background(), factory, opts); err != nil {
+				fmt.Fprintf(progress, "Warmup run %d failed: %v\n", i+1, err)
+			}
+		}
+
+		var scorer func(string) scoring.Result
+		if reference != "" {
+			normalizer := scoring.Normalizer{
+				IgnoreCase:        ignoreCase,
+				IgnorePunctuation: ignorePunctuation,
+				Numbers:           numbers,
+			}
+			scorer = func(transcript string) scoring.Result {
+				return scoring.Score(reference, transcript, normalizer)
+			}
+		}
+
+		// Only measured runs are recorded
+		opts.Record = recorder
+		results := make([]*bench.Run, runs)
+		var lastErr error
+		for i := 0; i < runs; i++ {
+			if runs > 1 {
+				fmt.Fprintf(progress, "Run %d/%d with %s provider...\n", i+1, runs, providerName)
+			} else {
+				fmt.Fprintf(progress, "Starting benchmark with %s provider...\n", providerName)
+			}
+			run, err := bench.RunOnce(context.Background(), factory, opts)
+			if err == nil {
+				results[i] = run
+			} else {
+				lastErr = err
+			}
+
+			if records != nil {
+				record := report.NewRecord(i+1, opts, run, err)
+				if err == nil && scorer != nil {
+					record.SetScore(scorer(run.Summary.Transcript))
+				}
+				if werr := records.Write(record); werr != nil {
+					return fmt.Errorf("failed to write record: %w", werr)
+				}
+			}
+
+			if err != nil {
+				if runs == 1 {
+					break
+				}
+				fmt.Fprintf(progress, "Run %d failed: %v\n", i+1, err)
+				continue
+			}
+			if records == nil {
+				printRun(out, run, perWord, scorer)
+			}
+		}
+
+		if records != nil {
+			if err := records.Close(); err != nil {
+				return fmt.Errorf("failed to write records: %w", err)
+			}
+		}
+		if runs == 1 && lastErr != nil {
+			return lastErr
+		}
+
+		if runs > 1 {
+			series := bench.Summarize(results)
+			if series.Failures == series.Runs {
+				return fmt.Errorf("all %d runs failed, last error: %w", runs, lastErr)
+			}
+			if records == nil {
+				fmt.Fprintf(out, "\n%d runs, %d failed\n", series.Runs, series.Failures)
+				printSeries(out, "First word latency", "ms", series.FirstWordLatency)
+				printSeries(out, "First final latency", "ms", series.FirstFinalLatency)
+				printSeries(out, "Final latency", "ms", series.FinalLatency)
+				printSeries(out, "Throughput", "words/s", series.Throughput)
+			}
+		}
+		return nil
+	},
+}
+
+// printRun prints the metrics of a single run
+func printRun(out io.Writer, run *bench.Run, perWord bool, scorer func(string) scoring.Result) {
+	summary := run.Summary
+	fmt.Fprintf(out, "Transcription: %s\n", summary.Transcript)
+	fmt.Fprintf(out, "First Word: %s\n", summary.FirstWord)
+	fmt.Fprintf(out, "Is Final: %t\n", summary.FirstWordFinal)
+	fmt.Fprintf(out, "First word latency: %.2f ms\n", metrics.Milliseconds(summary.FirstWordLatency))
+	fmt.Fprintf(out, "Final latency: %.2f ms\n", metrics.Milliseconds(summary.FinalLatency))
+	fmt.Fprintf(out, "Throughput: %.2f words/second\n", summary.Throughput)
+
+	// Word latencies are measured from the time each word's audio was sent
+	if perWord {
+		for _, w := range run.Words.Words {
+			fmt.Fprintf(out, "  %-20s end %6.2fs  first seen %8.2f ms  final %8.2f ms\n",
+				w.Word, w.End, metrics.Milliseconds(w.FirstSeen), metrics.Milliseconds(w.Finalized))
+		}
+	}
+	printDistribution(out, "Word first seen latency", run.Words.FirstSeen)
+	printDistribution(out, "Word final latency", run.Words.Finalized)
+	if run.Pacing.Count > 0 {
+		fmt.Fprintf(out, "Pacing error: mean %.2f ms, p99 %.2f ms, max %.2f ms (%d chunks of %d bytes every %.2f ms)\n",
+			run.Pacing.Mean, run.Pacing.P99, run.Pacing.Max, run.Pacing.Count, run.ChunkSize, metrics.Milliseconds(run.ChunkInterval))
+	}
+
+	if scorer != nil {
+		score := scorer(summary.Transcript)
+		fmt.Fprintf(out, "WER: %.2f%% (%d substitutions, %d deletions, %d insertions over %d words)\n",
+			score.WER()*100, score.Words.Substitutions, score.Words.Deletions, score.Words.Insertions, score.Words.Reference)
+		fmt.Fprintf(out, "CER: %.2f%%\n", score.CER()*100)
+	}
+}
+
+// printSeries prints the statistics of a metric over repeated runs
+func printSeries(out io.Writer, label, unit string, d metrics.Distribution) {
+	if d.Count == 0 {
+		fmt.Fprintf(out, "%s: no samples\n", label)
+		return
+	}
+	fmt.Fprintf(out, "%s (%s): min %.2f, mean %.2f, median %.2f, p90 %.2f, p95 %.2f, p99 %.2f, max %.2f, stddev %.2f\n",
+		label, unit, d.Min, d.Mean, d.P50, d.P90, d.P95, d.P99, d.Max, d.StdDev)
+}
+
+// loadReference reads the reference transcript from a file, or uses the value as inline text;
+// a value looking like a path, with a separator or a .txt extension, must be a readable file
+func loadReference(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	info, err := os.Stat(value)
+	if err == nil && info.Mode().IsRegular() {
+		data, err := os.ReadFile(value)
+		if err != nil {
+			return "", fmt.Errorf("failed to read reference file: %w", err)
+		}
+		return string(data), nil
+	}
+	if strings.ContainsAny(value, `/\`) || strings.HasSuffix(strings.ToLower(value), ".txt") {
+		if err == nil {
+			return "", fmt.Errorf("failed to read reference file: %s is not a regular file", value)
+		}
+		return "", fmt.Errorf("failed to read reference file: %w", err)
+	}
+	return value, nil
+}
+
+// printDistribution prints a latency distribution in milliseconds
+func printDistribution(out io.Writer, label string, d metrics.Distribution) {
+	if d.Count == 0 {
+		fmt.Fprintf(out, "%s: no samples\n", label)
+		return
+	}
+	fmt.Fprintf(out, "%s: mean %.2f ms, p50 %.2f ms, p90 %.2f ms, p99 %.2f ms (%d words)\n",
+		label, d.Mean, d.P50, d.P90, d.P99, d.Count)
+}
+
+// addStreamFlags adds the flags describing the audio, its streaming and the provider
+func addStreamFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("provider", "p", config.GetEnvWithDefault("DEFAULT_PROVIDER", "deepgram"), "Speech recognition provider (deepgram, assemblyai, aws-transcribe, azure, google, openai, speechmatics, vosk, whisper-server, exec, websocket-generic, replay)")
+	cmd.Flags().StringP("audio", "a", "", "Path to the WAV audio file")
+	cmd.Flags().IntP("chunk-size", "s", getEnvInt("DEFAULT_CHUNK_SIZE", audio.DefaultChunkSize), "Size of audio chunks in bytes")
+	cmd.Flags().IntP("chunk-interval", "i", getEnvInt("DEFAULT_CHUNK_INTERVAL", 0), "Fixed interval between chunks in milliseconds (0 sends each chunk once its audio would have played)")
+	cmd.Flags().Int("chunk-ms", getEnvInt("DEFAULT_CHUNK_MS", 0), "Duration of audio chunks in milliseconds, overrides --chunk-size and --chunk-interval")
+	cmd.Flags().Float64("realtime-factor", 1, "Streaming speed relative to real time (0.5 for half speed, 2 for double, 0 for unpaced)")
+	cmd.Flags().Int("target-rate", getEnvInt("DEFAULT_TARGET_RATE", 0), "Resample the audio to this sample rate in Hz (0 keeps the file rate)")
+	cmd.Flags().Int("channels", getEnvInt("DEFAULT_CHANNELS", 0), "Downmix or duplicate the audio to this many channels (0 keeps the file channels)")
+	cmd.Flags().Int("select-channel", 0, "Stream only this 1-based channel of the file as mono")
+	cmd.Flags().String("resample-quality", string(audio.QualityMedium), "Resampling filter quality (low, medium, high)")
+	cmd.Flags().StringP("language", "l", config.GetEnvWithDefault("DEFAULT_LANGUAGE", "en-US"), "Language code")
+	cmd.Flags().Bool("interim", true, "Enable interim results")
+	cmd.Flags().Bool("punctuate", true, "Enable punctuation")
+	cmd.Flags().Bool("smart-format", true, "Enable smart formatting")
+	cmd.Flags().StringP("model", "m", config.GetEnvWithDefault("DEFAULT_MODEL", ""), "Provider model (empty for the provider default)")
+	cmd.Flags().Bool("live", false, "Stream audio in real time over the provider's live API")
+	cmd.Flags().String("base-url", "", "Provider API endpoint, e.g. a local mock server (defaults to <PROVIDER>_BASE_URL or the provider's)")
+	cmd.Flags().String("model-version", config.GetEnvWithDefault("DEFAULT_MODEL_VERSION", ""), "Provider model version (empty for the latest)")
+	cmd.Flags().String("tier", config.GetEnvWithDefault("DEFAULT_TIER", ""), "Provider model tier, e.g. enhanced")
+	cmd.Flags().Int("endpointing", getEnvInt("DEFAULT_ENDPOINTING", 0), "Silence in milliseconds ending a live utterance (0 for the provider default, -1 disables)")
+	cmd.Flags().Int("utterance-end-ms", getEnvInt("DEFAULT_UTTERANCE_END_MS", 0), "Gap between words in milliseconds ending a live utterance (0 disables)")
+	cmd.Flags().Int("max-delay-ms", getEnvInt("DEFAULT_MAX_DELAY_MS", 0), "Longest delay in milliseconds before a word is final (0 for the provider default)")
+	cmd.Flags().String("operating-point", config.GetEnvWithDefault("DEFAULT_OPERATING_POINT", ""), "Provider operating point, e.g. standard or enhanced")
+	cmd.Flags().Int("window-step-ms", getEnvInt("DEFAULT_WINDOW_STEP_MS", 0), "Audio in milliseconds between two submissions of the sliding window of whisper-server (0 for the provider default)")
+	cmd.Flags().Int("window-ms", getEnvInt("DEFAULT_WINDOW_MS", 0), "Length in milliseconds of the sliding window of whisper-server (0 for the provider default)")
+	cmd.Flags().StringArray("keyterm", nil, "Term to boost, repeatable")
+	cmd.Flags().StringArray("keywords", nil, "Keyword to boost, as word:intensifier, repeatable")
+	cmd.Flags().Bool("diarize", false, "Label the speaker of every word")
+	cmd.Flags().Bool("multichannel", false, "Transcribe each channel independently")
+	cmd.Flags().Bool("numerals", false, "Write numbers as digits")
+	cmd.Flags().Bool("profanity-filter", false, "Mask profanity")
+	cmd.Flags().StringSlice("redact", nil, "Entities to redact, e.g. pci,ssn,numbers")
+	cmd.Flags().StringArray("provider-opt", nil, "Provider request parameter as key=value, passed through as is, repeatable")
+	cmd.Flags().String("project", config.GetEnvWithDefault("GOOGLE_CLOUD_PROJECT", ""), "Cloud project of the provider (google)")
+	cmd.Flags().String("region", config.GetEnvWithDefault("DEFAULT_REGION", ""), "Provider region or location, e.g. us-central1")
+	cmd.Flags().String("credentials", "", "Path to a credentials file used instead of the API key, e.g. a Google service-account JSON key (defaults to <PROVIDER>_CREDENTIALS)")
+	cmd.Flags().Bool("insecure", false, "Connect to --base-url without TLS, e.g. a local fake server")
+	cmd.Flags().String("recognizer", "", "Saved recognizer used instead of the inline configuration (google)")
+	cmd.Flags().Float64("min-stability", 0, "Stability from 0 to 1 below which interim results are not reported (google)")
+	cmd.Flags().String("exec", config.GetEnvWithDefault("EXEC_COMMAND", ""), "Plugin command run by the exec provider, split on spaces, e.g. \"./echo_plugin -final-every 3\"")
+	cmd.Flags().String("ws-template", config.GetEnvWithDefault("WEBSOCKET_GENERIC_TEMPLATE", ""), "Protocol template file of the websocket-generic provider")
+	cmd.Flags().String("session", "", "Session file recorded with --record, replayed by the replay provider")
+	cmd.Flags().Int("session-run", 1, "Run of the session file replayed by the replay provider, from 1")
+	cmd.Flags().String("record", "", "Record the requests, audio sends, raw messages and events of every measured run to this JSONL session file")
+}
+
+// openRecord opens the session file selected by --record; the writer is nil without one
+func openRecord(cmd *cobra.Command) (*session.Writer, func() error, error) {
+	recordPath, _ := cmd.Flags().GetString("record")
+	if recordPath == "" {
+		return nil, func() error { return nil }, nil
+	}
+	recordFile, err := os.Create(recordPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create session file: %w", err)
+	}
+	return session.NewWriter(recordFile), recordFile.Close, nil
+}
+
+// parseProviderOptions parses key=value passthrough options, keeping repeated keys
+func parseProviderOptions(pairs []string) (url.Values, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	options := url.Values{}
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid provider option %q, expected key=value", pair)
+		}
+		options.Add(key, value)
+	}
+	return options, nil
+}
+
+// streamOptions builds the run options from the stream flags, without the API key
+func streamOptions(cmd *cobra.Command) (bench.Options, error) {
+	providerName, _ := cmd.Flags().GetString("provider")
+	audioPath, _ := cmd.Flags().GetString("audio")
+	chunkSize, _ := cmd.Flags().GetInt("chunk-size")
+	chunkInterval, _ := cmd.Flags().GetInt("chunk-interval")
+	chunkMs, _ := cmd.Flags().GetInt("chunk-ms")
+	realtimeFactor, _ := cmd.Flags().GetFloat64("realtime-factor")
+	targetRate, _ := cmd.Flags().GetInt("target-rate")
+	targetChannels, _ := cmd.Flags().GetInt("channels")
+	selectChannel, _ := cmd.Flags().GetInt("select-channel")
+	qualityFlag, _ := cmd.Flags().GetString("resample-quality")
+	language, _ := cmd.Flags().GetString("language")
+	interim, _ := cmd.Flags().GetBool("interim")
+	punctuate, _ := cmd.Flags().GetBool("punctuate")
+	smartFormat, _ := cmd.Flags().GetBool("smart-format")
+	model, _ := cmd.Flags().GetString("model")
+	live, _ := cmd.Flags().GetBool("live")
+	baseURL, _ := cmd.Flags().GetString("base-url")
+	modelVersion, _ := cmd.Flags().GetString("model-version")
+	tier, _ := cmd.Flags().GetString("tier")
+	endpointing, _ := cmd.Flags().GetInt("endpointing")
+	utteranceEndMs, _ := cmd.Flags().GetInt("utterance-end-ms")
+	maxDelayMs, _ := cmd.Flags().GetInt("max-delay-ms")
+	operatingPoint, _ := cmd.Flags().GetString("operating-point")
+	windowStepMs, _ := cmd.Flags().GetInt("window-step-ms")
+	windowMs, _ := cmd.Flags().GetInt("window-ms")
+	keyterms, _ := cmd.Flags().GetStringArray("keyterm")
+	keywords, _ := cmd.Flags().GetStringArray("keywords")
+	diarize, _ := cmd.Flags().GetBool("diarize")
+	multichannel, _ := cmd.Flags().GetBool("multichannel")
+	numerals, _ := cmd.Flags().GetBool("numerals")
+	profanityFilter, _ := cmd.Flags().GetBool("profanity-filter")
+	redact, _ := cmd.Flags().GetStringSlice("redact")
+	providerOpts, _ := cmd.Flags().GetStringArray("provider-opt")
+	project, _ := cmd.Flags().GetString("project")
+	region, _ := cmd.Flags().GetString("region")
+	credentials, _ := cmd.Flags().GetString("credentials")
+	insecure, _ := cmd.Flags().GetBool("insecure")
+	recognizer, _ := cmd.Flags().GetString("recognizer")
+	minStability, _ := cmd.Flags().GetFloat64("min-stability")
+	execCommand, _ := cmd.Flags().GetString("exec")
+	wsTemplate, _ := cmd.Flags().GetString("ws-template")
+	sessionPath, _ := cmd.Flags().GetString("session")
+	sessionRun, _ := cmd.Flags().GetInt("session-run")
+
+	quality, err := audio.ParseResampleQuality(qualityFlag)
+	if err != nil {
+		return bench.Options{}, err
+	}
+	options, err := parseProviderOptions(providerOpts)
+	if err != nil {
+		return bench.Options{}, err
+	}
+	if utteranceEndMs < 0 {
+		return bench.Options{}, fmt.Errorf("utterance end must not be negative, got %d ms", utteranceEndMs)
+	}
+	if chunkMs < 0 {
+		return bench.Options{}, fmt.Errorf("chunk duration must not be negative, got %d ms", chunkMs)
+	}
+	if sessionRun < 1 {
+		return bench.Options{}, fmt.Errorf("session run must be at least 1, got %d", sessionRun)
+	}
+	if minStability < 0 || minStability > 1 {
+		return bench.Options{}, fmt.Errorf("minimum stability must be between 0 and 1, got %g", minStability)
+	}
+	if windowStepMs < 0 || windowMs < 0 {
+		return bench.Options{}, fmt.Errorf("window step and length must not be negative, got %d and %d ms", windowStepMs, windowMs)
+	}
+	if realtimeFactor < 0 {
+		return bench.Options{}, fmt.Errorf("realtime factor must not be negative, got %g", realtimeFactor)
+	}
+
+	return bench.Options{
+		Provider:       providerName,
+		AudioPath:      audioPath,
+		ChunkSize:      chunkSize,
+		ChunkInterval:  time.Duration(chunkInterval) * time.Millisecond,
+		ChunkDuration:  time.Duration(chunkMs) * time.Millisecond,
+		RealtimeFactor: realtimeFactor,
+		Unpaced:        realtimeFactor == 0,
+		Transform: audio.Transform{
+			SampleRate:    targetRate,
+			Channels:      targetChannels,
+			SelectChannel: selectChannel,
+			Quality:       quality,
+		},
+		Config: providers.Config{
 			Language:    language,
 			Interim:     interim,
 			Punctuate:   punctuate,
 			SmartFormat: smartFormat,
+			Live:        live,
+			Model:       model,
+			BaseURL:     baseURL,
+
+			ModelVersion:    modelVersion,
+			Tier:            tier,
+			EndpointingMs:   endpointing,
+			UtteranceEndMs:  utteranceEndMs,
+			MaxDelayMs:      maxDelayMs,
+			OperatingPoint:  operatingPoint,
+			WindowStepMs:    windowStepMs,
+			WindowMs:        windowMs,
+			Keyterms:        keyterms,
+			Keywords:        keywords,
+			Diarize:         diarize,
+			Multichannel:    multichannel,
+			Numerals:        numerals,
+			ProfanityFilter: profanityFilter,
+			Redact:          redact,
+			Options:         options,
+
+			Project:     project,
+			Region:      region,
+			Credentials: credentials,
+			Insecure:    insecure,
+
+			Recognizer:   recognizer,
+			MinStability: minStability,
+
+			Command:    strings.Fields(execCommand),
+			Template:   wsTemplate,
+			Session:    sessionPath,
+			SessionRun: sessionRun,
+		},
+	}, nil
+}
+
+// resolveEndpoint falls back to the provider's <PROVIDER>_BASE_URL and <PROVIDER>_CREDENTIALS
+// environment variables when they were not given on the command line, then reads the
+// provider's API key, failing without one only when the provider needs it as configured
+func resolveEndpoint(opts *bench.Options, factory *providers.Factory) error {
+	if opts.Config.BaseURL == "" {
+		opts.Config.BaseURL = config.GetProviderBaseURL(opts.Provider)
+	}
+	if opts.Config.Credentials == "" {
+		opts.Config.Credentials = config.GetProviderCredentials(opts.Provider)
+	}
+	key, err := config.GetProviderAPIKey(opts.Provider)
+	if err != nil && factory.NeedsAPIKey(opts.Provider, &opts.Config) {
+		return err
+	}
+	opts.APIKey = key
+	return nil
+}
+
+// probeAudio checks that the audio file can be streamed as configured and prints its format
+func probeAudio(progress io.Writer, opts bench.Options) error {
+	streamer, err := audio.NewWAVStreamer(opts.AudioPath, opts.ChunkSize, opts.ChunkInterval)
+	if err != nil {
+		return fmt.Errorf("failed to create WAV streamer: %w", err)
+	}
+	defer streamer.Close()
+
+	sampleRate, channels, _ := streamer.GetAudioFormat()
+	fmt.Fprintf(progress, "Audio format: %d Hz, %d channels, %s\n", sampleRate, channels, streamer.SourceFormat())
+	if err := streamer.SetTransform(opts.Transform); err != nil {
+		return fmt.Errorf("failed to convert audio: %w", err)
+	}
+	if targetRate, targetChannels, _ := streamer.GetAudioFormat(); targetRate != sampleRate || targetChannels != channels || opts.Transform.SelectChannel > 0 {
+		fmt.Fprintf(progress, "Streaming as: %d Hz, %d channels, 16-bit PCM\n", targetRate, targetChannels)
+	}
+	return nil
+}
+
+// addOutputFlags adds the flags selecting the result format and destination
+func addOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("output", "o", config.GetEnvWithDefault("DEFAULT_OUTPUT", string(report.Text)), "Output format (text, json, jsonl, csv)")
+	cmd.Flags().String("output-file", "", "Write results to this file instead of stdout")
+}
+
+// output is where a command writes its results and progress
+type output struct {
+	format   report.Format
+	out      io.Writer     // results
+	progress io.Writer     // progress messages
+	records  report.Writer // nil for the text format
+	file     *os.File      // output file, nil for stdout
+}
+
+// openOutput opens the result destination selected by the output flags
+func openOutput(cmd *cobra.Command) (*output, error) {
+	outputFlag, _ := cmd.Flags().GetString("output")
+	outputFile, _ := cmd.Flags().GetString("output-file")
+
+	format, err := report.ParseFormat(outputFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	// Results go to the output file or stdout; progress goes to stderr
+	// unless it is interleaved with the human readable results on stdout
+	o := &output{format: format, out: cmd.OutOrStdout(), progress: cmd.ErrOrStderr()}
+	if outputFile != "" {
+		if o.file, err = os.Create(outputFile); err != nil {
+			return nil, fmt.Errorf("failed to create output file: %w", err)
 		}
+		o.out = o.file
+	}
+	if format == report.Text && outputFile == "" {
+		o.progress = o.out
+	}
+	if format != report.Text {
+		if o.records, err = report.NewWriter(format, o.out); err != nil {
+			o.Close()
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// Close closes the output file; records must be flushed first
+func (o *output) Close() error {
+	if o.file == nil {
+		return nil
+	}
+	return o.file.Close()
+}
 
-		// Get provider API key
-		apiKey, err := config.GetProviderAPIKey(providerName)
+var loadCmd = &cobra.Command{
+	Use:   "load",
+	Short: "Run many simultaneous streams to measure latency under load",
+	Long: `load streams the audio file over many simultaneous connections, each with its
+own provider, and reports how latency and error rates degrade with concurrency.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Flags are valid past this point, runtime errors don't need the usage text
+		cmd.SilenceUsage = true
+
+		opts, err := streamOptions(cmd)
 		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+			return err
+		}
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		rampUp, _ := cmd.Flags().GetDuration("ramp-up")
+		duration, _ := cmd.Flags().GetDuration("duration")
+		arrivalRate, _ := cmd.Flags().GetFloat64("arrival-rate")
+		perStream, _ := cmd.Flags().GetBool("per-stream")
+
+		loadOpts := bench.LoadOptions{
+			Run:         opts,
+			Concurrency: concurrency,
+			RampUp:      rampUp,
+			Duration:    duration,
+			ArrivalRate: arrivalRate,
+		}
+		if err := loadOpts.Validate(); err != nil {
+			return err
 		}
 
-		// Create provider factory and get provider
+		output, err := openOutput(cmd)
+		if err != nil {
+			return err
+		}
+		defer output.Close()
+		out, progress, records := output.out, output.progress, output.records
+
+		if err := probeAudio(progress, opts); err != nil {
+			return err
+		}
 		factory := providers.NewFactory()
-		provider, err := factory.CreateProvider(providerName, providerConfig, apiKey)
+		if err := resolveEndpoint(&loadOpts.Run, factory); err != nil {
+			return err
+		}
+		recorder, closeRecord, err := openRecord(cmd)
+		if err != nil {
+			return err
+		}
+		defer closeRecord()
+		loadOpts.Run.Record = recorder
+
+		// Interrupting the load test stops it and still reports the completed streams
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		fmt.Fprintf(progress, "Load test with %s provider: up to %d simultaneous streams\n", opts.Provider, concurrency)
+		var writeErr error
+		result, err := bench.RunLoad(ctx, factory, loadOpts, func(s bench.StreamResult) {
+			if s.Err != nil {
+				fmt.Fprintf(progress, "Stream %d failed after %.2f s: %v\n", s.Stream, s.Offset.Seconds(), s.Err)
+			} else {
+				fmt.Fprintf(progress, "Stream %d done (%d in flight at start)\n", s.Stream, s.Concurrency)
+			}
+			if records != nil && writeErr == nil {
+				writeErr = records.Write(report.NewStreamRecord(opts, s))
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if writeErr != nil {
+			return fmt.Errorf("failed to write record: %w", writeErr)
+		}
+
+		// Structured output only carries records, the summary goes with the progress
+		summary := out
+		if records != nil {
+			if err := records.Close(); err != nil {
+				return fmt.Errorf("failed to write records: %w", err)
+			}
+			summary = progress
+		}
+		if perStream && records == nil {
+			for _, s := range result.Streams {
+				printStream(out, s)
+			}
+		}
+		printLoad(summary, result)
+
+		if len(result.Streams) > 0 && result.Series.Failures == len(result.Streams) {
+			return fmt.Errorf("all %d streams failed", len(result.Streams))
+		}
+		return nil
+	},
+}
+
+// printStream prints the latencies of one stream of a load test
+func printStream(out io.Writer, s bench.StreamResult) {
+	if s.Err != nil {
+		fmt.Fprintf(out, "Stream %4d  start %8.2f s  concurrency %3d  error: %v\n", s.Stream, s.Offset.Seconds(), s.Concurrency, s.Err)
+		return
+	}
+	summary := s.Run.Summary
+	fmt.Fprintf(out, "Stream %4d  start %8.2f s  concurrency %3d  first word %8.2f ms  final %8.2f ms  word final p90 %8.2f ms\n",
+		s.Stream, s.Offset.Seconds(), s.Concurrency, metrics.Milliseconds(summary.FirstWordLatency),
+		metrics.Milliseconds(summary.FinalLatency), s.Run.Words.Finalized.P90)
+}
+
+// printLoad prints the aggregate results of a load test
+func printLoad(out io.Writer, result *bench.LoadResult) {
+	fmt.Fprintf(out, "\n%d streams in %.2f s, %d failed (%.2f%% error rate), %d skipped, peak concurrency %d\n",
+		len(result.Streams), result.Elapsed.Seconds(), result.Series.Failures, result.ErrorRate()*100,
+		result.Skipped, result.PeakConcurrency)
+	printSeries(out, "First word latency", "ms", result.Series.FirstWordLatency)
+	printSeries(out, "First final latency", "ms", result.Series.FirstFinalLatency)
+	printSeries(out, "Final latency", "ms", result.Series.FinalLatency)
+	printSeries(out, "Throughput", "words/s", result.Series.Throughput)
+	printDistribution(out, "Word first seen latency", result.WordFirstSeen)
+	printDistribution(out, "Word final latency", result.WordFinalized)
+
+	// Most frequent errors first
+	messages := make([]string, 0, len(result.Errors))
+	for message := range result.Errors {
+		messages = append(messages, message)
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		if result.Errors[messages[i]] != result.Errors[messages[j]] {
+			return result.Errors[messages[i]] > result.Errors[messages[j]]
+		}
+		return messages[i] < messages[j]
+	})
+	for _, message := range messages {
+		fmt.Fprintf(out, "  %4d x %s\n", result.Errors[message], message)
+	}
+}
+
+// cellPlan is a scenario cell ready to run
+type cellPlan struct {
+	name      string
+	opts      bench.Options
+	runs      int
+	warmup    int
+	reference string
+}
+
+// cellResult is the outcome of the measured runs of a cell
+type cellResult struct {
+	name   string
+	series bench.Series
+	wer    metrics.Distribution // WER of successful runs when the cell has a reference
+}
+
+var runCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run a benchmark matrix from a scenario file",
+	Long: `run executes every cell of a scenario file, the combinations of its matrix
+followed by its listed cases, and groups the results per cell. Settings a cell
+leaves unset take the value of the command line flags.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Flags are valid past this point, runtime errors don't need the usage text
+		cmd.SilenceUsage = true
+
+		base, err := streamOptions(cmd)
 		if err != nil {
-			fmt.Printf("Error creating provider: %v\n", err)
-			os.Exit(1)
+			return err
+		}
+		scenarioPath, _ := cmd.Flags().GetString("scenario")
+		defaultRuns, _ := cmd.Flags().GetInt("runs")
+		defaultWarmup, _ := cmd.Flags().GetInt("warmup")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if defaultRuns < 1 {
+			return fmt.Errorf("runs must be at least 1, got %d", defaultRuns)
+		}
+		if defaultWarmup < 0 {
+			return fmt.Errorf("warmup must not be negative, got %d", defaultWarmup)
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
+		s, err := scenario.Load(scenarioPath)
+		if err != nil {
+			return err
+		}
+		cells, err := s.Cells()
+		if err != nil {
+			return err
+		}
 
-		// Get audio stream
-		audioStream, err := streamer.Stream()
+		output, err := openOutput(cmd)
 		if err != nil {
-			fmt.Printf("Error getting audio stream: %v\n", err)
-			os.Exit(1)
+			return err
 		}
+		defer output.Close()
+		out, progress, records := output.out, output.progress, output.records
+
+		// Check every cell before running any, so a typo doesn't surface hours in
+		factory := providers.NewFactory()
+		plans := make([]cellPlan, len(cells))
+		for i, cell := range cells {
+			plan := cellPlan{name: cell.Name, opts: cell.Case.Apply(base)}
+			plan.runs, plan.warmup = cell.Case.RunCounts(defaultRuns, defaultWarmup)
+			if dryRun {
+				fmt.Fprintf(out, "%s: %s, %d runs, %d warmup\n", cell.Name, plan.opts.AudioPath, plan.runs, plan.warmup)
+				continue
+			}
 
-		// Run benchmark
-		fmt.Printf("Starting benchmark with %s provider...\n", providerName)
-		result, err := provider.StreamAudio(ctx, audioStream)
+			if plan.opts.AudioPath == "" {
+				return fmt.Errorf("%s: no audio file, set audio in the scenario or --audio", cell.Name)
+			}
+			if err := probeAudio(io.Discard, plan.opts); err != nil {
+				return fmt.Errorf("%s: %w", cell.Name, err)
+			}
+			if cell.Case.Reference != nil {
+				if plan.reference, err = loadReference(*cell.Case.Reference); err != nil {
+					return fmt.Errorf("%s: %w", cell.Name, err)
+				}
+			}
+			if err := resolveEndpoint(&plan.opts, factory); err != nil {
+				return fmt.Errorf("%s: %w", cell.Name, err)
+			}
+			plans[i] = plan
+		}
+		if dryRun {
+			return nil
+		}
+		recorder, closeRecord, err := openRecord(cmd)
 		if err != nil {
-			fmt.Printf("Error streaming audio: %v\n", err)
-			os.Exit(1)
+			return err
+		}
+		defer closeRecord()
+
+		if s.Name != "" {
+			fmt.Fprintf(progress, "Scenario %s: %d cells\n", s.Name, len(plans))
+		}
+		results := make([]cellResult, len(plans))
+		recordIndex := 0
+		for i, plan := range plans {
+			for j := 0; j < plan.warmup; j++ {
+				fmt.Fprintf(progress, "[%d/%d] %s: warmup run %d/%d...\n", i+1, len(plans), plan.name, j+1, plan.warmup)
+				if _, err := bench.RunOnce(context.Background(), factory, plan.opts); err != nil {
+					fmt.Fprintf(progress, "Warmup run %d failed: %v\n", j+1, err)
+				}
+			}
+
+			// Only measured runs are recorded
+			measured := plan.opts
+			measured.Record = recorder
+			runs := make([]*bench.Run, plan.runs)
+			var wers []float64
+			for j := 0; j < plan.runs; j++ {
+				fmt.Fprintf(progress, "[%d/%d] %s: run %d/%d...\n", i+1, len(plans), plan.name, j+1, plan.runs)
+				run, err := bench.RunOnce(context.Background(), factory, measured)
+				if err != nil {
+					fmt.Fprintf(progress, "Run %d failed: %v\n", j+1, err)
+				} else {
+					runs[j] = run
+				}
+
+				var score *scoring.Result
+				if err == nil && plan.reference != "" {
+					result := scoring.Score(plan.reference, run.Summary.Transcript, scoring.DefaultNormalizer)
+					score = &result
+					wers = append(wers, result.WER())
+				}
+				if records != nil {
+					recordIndex++
+					record := report.NewRecord(recordIndex, plan.opts, run, err)
+					record.Cell = plan.name
+					if score != nil {
+						record.SetScore(*score)
+					}
+					if werr := records.Write(record); werr != nil {
+						return fmt.Errorf("failed to write record: %w", werr)
+					}
+				}
+			}
+			results[i] = cellResult{name: plan.name, series: bench.Summarize(runs), wer: metrics.Describe(wers)}
 		}
 
-		fmt.Printf("First word latency: %.2f ms\n", result.Latency)
-		fmt.Printf("Throughput: %.2f words/second\n", result.Throughput)
+		if records != nil {
+			if err := records.Close(); err != nil {
+				return fmt.Errorf("failed to write records: %w", err)
+			}
+			return nil
+		}
+		for _, result := range results {
+			printCell(out, result)
+		}
+		printCellTable(out, results)
+		return nil
+	},
+}
+
+// printCell prints the statistics of the runs of a scenario cell
+func printCell(out io.Writer, result cellResult) {
+	fmt.Fprintf(out, "\n== %s (%d runs, %d failed) ==\n", result.name, result.series.Runs, result.series.Failures)
+	printSeries(out, "First word latency", "ms", result.series.FirstWordLatency)
+	printSeries(out, "First final latency", "ms", result.series.FirstFinalLatency)
+	printSeries(out, "Final latency", "ms", result.series.FinalLatency)
+	printSeries(out, "Throughput", "words/s", result.series.Throughput)
+	if result.wer.Count > 0 {
+		fmt.Fprintf(out, "WER: mean %.2f%%, min %.2f%%, max %.2f%%\n", result.wer.Mean*100, result.wer.Min*100, result.wer.Max*100)
+	}
+}
+
+// printCellTable prints one line per scenario cell to compare them
+func printCellTable(out io.Writer, results []cellResult) {
+	width := len("Cell")
+	for _, result := range results {
+		width = max(width, len(result.name))
+	}
+
+	fmt.Fprintf(out, "\n%-*s  %16s  %12s  %10s  %8s  %8s\n", width, "Cell", "First word p50", "Final p50", "Words/s", "WER", "Failed")
+	for _, result := range results {
+		wer := "-"
+		if result.wer.Count > 0 {
+			wer = fmt.Sprintf("%.2f%%", result.wer.Mean*100)
+		}
+		fmt.Fprintf(out, "%-*s  %13.2f ms  %9.2f ms  %10.2f  %8s  %4d/%-3d\n", width, result.name,
+			result.series.FirstWordLatency.P50, result.series.FinalLatency.P50, result.series.Throughput.Mean,
+			wer, result.series.Failures, result.series.Runs)
+	}
+}
+
+var mockServerCmd = &cobra.Command{
+	Use:   "mock-server",
+	Short: "Run a local server emulating the Deepgram API",
+	Long: `Run a local server emulating Deepgram's pre-recorded and live APIs, returning a
+scripted transcript with configurable latency, jitter, errors and interim cadence.
+Point the benchmark at it with --base-url or DEEPGRAM_BASE_URL.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+
+		addr, _ := cmd.Flags().GetString("addr")
+		transcript, _ := cmd.Flags().GetString("transcript")
+		transcriptFile, _ := cmd.Flags().GetString("transcript-file")
+		latency, _ := cmd.Flags().GetDuration("latency")
+		jitter, _ := cmd.Flags().GetDuration("jitter")
+		interimMs, _ := cmd.Flags().GetInt("interim-ms")
+		finalMs, _ := cmd.Flags().GetInt("final-ms")
+		wordMs, _ := cmd.Flags().GetInt("word-ms")
+		errorCode, _ := cmd.Flags().GetInt("error-code")
+		errorRate, _ := cmd.Flags().GetFloat64("error-rate")
+		apiKey, _ := cmd.Flags().GetString("api-key")
+		seed, _ := cmd.Flags().GetInt64("seed")
+
+		if transcriptFile != "" {
+			data, err := os.ReadFile(transcriptFile)
+			if err != nil {
+				return fmt.Errorf("failed to read transcript: %w", err)
+			}
+			transcript = string(data)
+		}
+		if latency < 0 || jitter < 0 {
+			return fmt.Errorf("latency and jitter must not be negative")
+		}
+		if errorRate < 0 || errorRate > 1 {
+			return fmt.Errorf("error rate must be between 0 and 1, got %g", errorRate)
+		}
+
+		server := &http.Server{
+			Addr: addr,
+			Handler: mockserver.New(mockserver.Config{
+				Transcript:   transcript,
+				Latency:      latency,
+				Jitter:       jitter,
+				ErrorCode:    errorCode,
+				ErrorRate:    errorRate,
+				APIKey:       apiKey,
+				WordDuration: time.Duration(wordMs) * time.Millisecond,
+				InterimEvery: time.Duration(interimMs) * time.Millisecond,
+				FinalEvery:   time.Duration(finalMs) * time.Millisecond,
+				Seed:         seed,
+			}),
+		}
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("failed to listen: %w", err)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			server.Close()
+		}()
+
+		fmt.Printf("Mock Deepgram server listening on http://%s\n", listener.Addr())
+		fmt.Printf("Benchmark against it with --base-url http://%s\n", listener.Addr())
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			return fmt.Errorf("failed to serve: %w", err)
+		}
+		return nil
 	},
 }
 
 func main() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 } 
\ No newline at end of file
@@ -2,11 +2,19 @@ package main
 
 import (
 	"bytes"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/elishowk/speech_latency/internal/config"
+	"github.com/elishowk/speech_latency/pkg/bench"
+	"github.com/elishowk/speech_latency/pkg/mockserver"
+	"github.com/elishowk/speech_latency/pkg/providers"
+	"github.com/elishowk/speech_latency/pkg/scoring"
+	"github.com/elishowk/speech_latency/pkg/session"
 	"github.com/spf13/cobra"
 )
 
@@ -118,4 +126,205 @@ func TestInvalidFlagValues(t *testing.T) {
 			}
 		})
 	}
-} 
\ No newline at end of file
+} 
+func TestLoadReference(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reference.txt")
+	if err := os.WriteFile(path, []byte("hello from a file"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := loadReference(path); err != nil || got != "hello from a file" {
+		t.Errorf("expected file contents, got %q (%v)", got, err)
+	}
+	if got, err := loadReference("hello inline"); err != nil || got != "hello inline" {
+		t.Errorf("expected inline text, got %q (%v)", got, err)
+	}
+	for _, mistyped := range []string{filepath.Join(t.TempDir(), "missing"), "referense.txt", t.TempDir()} {
+		if _, err := loadReference(mistyped); err == nil {
+			t.Errorf("%s: expected error for a path that cannot be read", mistyped)
+		}
+	}
+}
+
+func TestBenchmarkCommand_InvalidNumberMode(t *testing.T) {
+	// Flags keep their values between executions of rootCmd
+	defer benchmarkCmd.Flags().Set("numbers", string(scoring.NumbersDigits))
+
+	_, err := executeCommand(rootCmd, "benchmark", "-a", "../../audio.wav", "--numbers", "roman")
+	if err == nil || !strings.Contains(err.Error(), "unknown number mode") {
+		t.Errorf("expected unknown number mode error, got: %v", err)
+	}
+}
+
+func TestLoadCommand_InvalidOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"zero concurrency", []string{"-c", "0"}, "concurrency must be at least 1"},
+		{"arrival rate without duration", []string{"--arrival-rate", "5"}, "requires a duration"},
+		{"negative window", []string{"--window-ms", "-1"}, "window step and length must not be negative"},
+		{"stability above 1", []string{"--min-stability", "1.5"}, "minimum stability must be between 0 and 1"},
+		{"session run 0", []string{"--session-run", "0"}, "session run must be at least 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Flags keep their values between executions of rootCmd
+			defer loadCmd.Flags().Set("concurrency", "10")
+			defer loadCmd.Flags().Set("arrival-rate", "0")
+			defer loadCmd.Flags().Set("window-ms", "0")
+			defer loadCmd.Flags().Set("min-stability", "0")
+			defer loadCmd.Flags().Set("session-run", "1")
+
+			args := append([]string{"load", "-a", "../../audio.wav"}, tt.args...)
+			_, err := executeCommand(rootCmd, args...)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected %q error, got: %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestRunCommand_DryRun(t *testing.T) {
+	defer runCmd.Flags().Set("dry-run", "false")
+
+	path := filepath.Join(t.TempDir(), "bench.yaml")
+	scenario := "matrix:\n  provider: [deepgram, other]\n  chunk_ms: [20, 100]\ndefaults:\n  runs: 3\n"
+	if err := os.WriteFile(path, []byte(scenario), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := executeCommand(rootCmd, "run", "--scenario", path, "-a", "../../audio.wav", "--dry-run")
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	lines := strings.Split(output, "\n")
+	if len(lines) != 4 || lines[3] != "provider=other chunk_ms=100: ../../audio.wav, 3 runs, 0 warmup" {
+		t.Errorf("unexpected cells:\n%s", output)
+	}
+}
+
+func TestBenchmarkCommand_MockServer(t *testing.T) {
+	defer benchmarkCmd.Flags().Set("base-url", "")
+	defer benchmarkCmd.Flags().Set("realtime-factor", "1")
+	defer benchmarkCmd.Flags().Set("output", "text")
+	t.Setenv("DEEPGRAM_API_KEY", "test-key")
+
+	srv := httptest.NewServer(mockserver.New(mockserver.Config{Transcript: "scripted words", APIKey: "test-key"}))
+	defer srv.Close()
+
+	// Earlier tests may leave invalid chunking flags behind
+	output, err := executeCommand(rootCmd, "benchmark", "-a", "../../audio.wav", "-s", "4096", "-i", "100",
+		"--base-url", srv.URL, "--realtime-factor", "0", "-o", "json")
+	if err != nil {
+		t.Fatalf("benchmark failed: %v\n%s", err, output)
+	}
+	if !strings.Contains(output, `"transcript": "scripted words"`) {
+		t.Errorf("expected the mock transcript in the report:\n%s", output)
+	}
+}
+
+func TestBenchmarkCommand_SlowPacedStream(t *testing.T) {
+	if testing.Short() {
+		t.Skip("streams for longer than bench.DefaultTimeout")
+	}
+	defer benchmarkCmd.Flags().Set("base-url", "")
+	defer benchmarkCmd.Flags().Set("realtime-factor", "1")
+	defer benchmarkCmd.Flags().Set("chunk-ms", "0")
+	defer benchmarkCmd.Flags().Set("live", "false")
+	defer benchmarkCmd.Flags().Set("output", "text")
+	t.Setenv("DEEPGRAM_API_KEY", "test-key")
+
+	srv := httptest.NewServer(mockserver.New(mockserver.Config{Transcript: "scripted words", APIKey: "test-key"}))
+	defer srv.Close()
+
+	// The 20.5 s file streams in about 34 s at 0.6x, past the fixed margin of a run
+	start := time.Now()
+	output, err := executeCommand(rootCmd, "benchmark", "-a", "../../audio.wav", "--chunk-ms", "100", "--live",
+		"--base-url", srv.URL, "--realtime-factor", "0.6", "-o", "json")
+	if err != nil {
+		t.Fatalf("benchmark failed: %v\n%s", err, output)
+	}
+	if elapsed := time.Since(start); elapsed <= bench.DefaultTimeout {
+		t.Errorf("expected the stream to outlast %s, it took %s", bench.DefaultTimeout, elapsed)
+	}
+	if !strings.Contains(output, `"transcript": "scripted words"`) {
+		t.Errorf("expected the mock transcript in the report:\n%s", output)
+	}
+}
+
+func TestLoadCommand_Record(t *testing.T) {
+	defer loadCmd.Flags().Set("base-url", "")
+	defer loadCmd.Flags().Set("realtime-factor", "1")
+	defer loadCmd.Flags().Set("concurrency", "10")
+	defer loadCmd.Flags().Set("record", "")
+	t.Setenv("DEEPGRAM_API_KEY", "test-key")
+
+	srv := httptest.NewServer(mockserver.New(mockserver.Config{Transcript: "scripted words", APIKey: "test-key"}))
+	defer srv.Close()
+
+	// Every stream of the load test is recorded
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	output, err := executeCommand(rootCmd, "load", "-a", "../../audio.wav", "-s", "4096", "-i", "100",
+		"--base-url", srv.URL, "--realtime-factor", "0", "-c", "2", "--record", path)
+	if err != nil {
+		t.Fatalf("load failed: %v\n%s", err, output)
+	}
+	sessions, err := session.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].Provider != "deepgram" || len(sessions[1].Events) == 0 {
+		t.Errorf("expected 2 recorded streams, got %+v", sessions)
+	}
+}
+
+func TestParseProviderOptions(t *testing.T) {
+	options, err := parseProviderOptions([]string{"filler_words=true", "keyterm=a=b", "keyterm=c"})
+	if err != nil {
+		t.Fatalf("parseProviderOptions failed: %v", err)
+	}
+	if options.Get("filler_words") != "true" || len(options["keyterm"]) != 2 || options["keyterm"][0] != "a=b" {
+		t.Errorf("unexpected options %v", options)
+	}
+
+	for _, pair := range []string{"novalue", "=value"} {
+		if _, err := parseProviderOptions([]string{pair}); err == nil {
+			t.Errorf("%q: expected error", pair)
+		}
+	}
+}
+
+func TestResolveEndpoint(t *testing.T) {
+	for _, name := range []string{"DEEPGRAM", "GOOGLE", "VOSK", "WHISPER_SERVER", "EXEC", "WEBSOCKET_GENERIC", "REPLAY"} {
+		t.Setenv(name+"_API_KEY", "")
+		t.Setenv(name+"_BASE_URL", "")
+		t.Setenv(name+"_CREDENTIALS", "")
+	}
+	tests := []struct {
+		name    string
+		opts    bench.Options
+		wantErr bool
+	}{
+		{"hosted provider", bench.Options{Provider: "deepgram"}, true},
+		{"hosted provider with a base URL", bench.Options{Provider: "deepgram", Config: providers.Config{BaseURL: "http://localhost:8080"}}, false},
+		{"credentials file", bench.Options{Provider: "google", Config: providers.Config{Credentials: "key.json"}}, false},
+		{"credentials file of another provider", bench.Options{Provider: "deepgram", Config: providers.Config{Credentials: "key.json"}}, true},
+		{"local server", bench.Options{Provider: "vosk"}, false},
+		{"local whisper server", bench.Options{Provider: "whisper-server"}, false},
+		{"plugin", bench.Options{Provider: "exec"}, false},
+		{"plugin command with another provider", bench.Options{Provider: "deepgram", Config: providers.Config{Command: []string{"./plugin"}}}, true},
+		{"template", bench.Options{Provider: "websocket-generic"}, false},
+		{"template with another provider", bench.Options{Provider: "deepgram", Config: providers.Config{Template: "vendor.yaml"}}, true},
+		{"replay", bench.Options{Provider: "replay"}, false},
+		{"session with another provider", bench.Options{Provider: "deepgram", Config: providers.Config{Session: "runs.jsonl"}}, true},
+	}
+	for _, tt := range tests {
+		err := resolveEndpoint(&tt.opts, providers.NewFactory())
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+	}
+}
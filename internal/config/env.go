@@ -34,15 +34,47 @@ func LoadEnv() error {
 	return nil
 }
 
-// GetProviderAPIKey gets the API key for the specified provider
+// envPrefix turns a provider name into its environment variable prefix, e.g.
+// aws-transcribe into AWS_TRANSCRIBE
+func envPrefix(providerName string) string {
+	return strings.ToUpper(strings.ReplaceAll(providerName, "-", "_"))
+}
+
+// GetProviderAPIKey gets the API key for the specified provider; AWS providers fall back
+// to the standard AWS access key variables, joined as ACCESS_KEY_ID:SECRET_ACCESS_KEY[:SESSION_TOKEN]
 func GetProviderAPIKey(providerName string) (string, error) {
-	key := os.Getenv(fmt.Sprintf("%s_API_KEY", strings.ToUpper(providerName)))
+	key := os.Getenv(fmt.Sprintf("%s_API_KEY", envPrefix(providerName)))
+	if key == "" && strings.HasPrefix(providerName, "aws-") {
+		key = awsAccessKey()
+	}
 	if key == "" {
-		return "", fmt.Errorf("%s_API_KEY environment variable is required", strings.ToUpper(providerName))
+		return "", fmt.Errorf("%s_API_KEY environment variable is required", envPrefix(providerName))
 	}
 	return key, nil
 }
 
+// awsAccessKey joins AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN, empty when unset
+func awsAccessKey() string {
+	id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if id == "" || secret == "" {
+		return ""
+	}
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		return id + ":" + secret + ":" + token
+	}
+	return id + ":" + secret
+}
+
+// GetProviderBaseURL gets the API endpoint override for the specified provider, empty when unset
+func GetProviderBaseURL(providerName string) string {
+	return os.Getenv(fmt.Sprintf("%s_BASE_URL", envPrefix(providerName)))
+}
+
+// GetProviderCredentials gets the credentials file path for the specified provider, empty when unset
+func GetProviderCredentials(providerName string) string {
+	return os.Getenv(fmt.Sprintf("%s_CREDENTIALS", envPrefix(providerName)))
+}
+
 // GetEnvWithDefault gets an environment variable with a default value
 func GetEnvWithDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -0,0 +1,62 @@
+package audio
+
+import (
+	"sync"
+	"time"
+)
+
+// pacer hands out chunks on a schedule fixed from the start of the stream: chunk N
+// (1-based) is due at start + N*interval, or once the audio of chunks 1 to N would
+// have played at rate, so sleep overhead does not accumulate into drift
+type pacer struct {
+	mu       sync.Mutex
+	interval time.Duration // fixed interval between chunks, overrides rate when set
+	rate     float64       // bytes of audio handed out per second, 0 with no interval streams unpaced
+	start    time.Time     // time the first chunk was read
+	chunks   int64
+	bytes    int64
+	errors   []time.Duration // how late each chunk was handed out
+}
+
+// reset restarts the schedule at the next chunk
+func (p *pacer) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.start = time.Time{}
+	p.chunks = 0
+	p.bytes = 0
+	p.errors = nil
+}
+
+// wait blocks until the next chunk, of n bytes, is due and records how late it is
+func (p *pacer) wait(n int) {
+	if p.interval <= 0 && p.rate <= 0 {
+		return
+	}
+
+	p.mu.Lock()
+	if p.start.IsZero() {
+		p.start = time.Now()
+	}
+	p.chunks++
+	p.bytes += int64(n)
+	due := p.start.Add(time.Duration(p.chunks) * p.interval)
+	if p.interval <= 0 {
+		due = p.start.Add(time.Duration(float64(p.bytes) / p.rate * float64(time.Second)))
+	}
+	p.mu.Unlock()
+
+	time.Sleep(time.Until(due))
+	late := time.Since(due)
+
+	p.mu.Lock()
+	p.errors = append(p.errors, late)
+	p.mu.Unlock()
+}
+
+// Errors returns how late each paced chunk was handed out
+func (p *pacer) Errors() []time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]time.Duration(nil), p.errors...)
+}
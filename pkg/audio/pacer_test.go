@@ -0,0 +1,147 @@
+package audio
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestWAVStreamer_ChunkDuration(t *testing.T) {
+	path := writeFile(t, riffFile(riffChunk("fmt ", fmtBody(formatPCM, 2, 16000, 16)), riffChunk("data", make([]byte, 6400))))
+
+	streamer, err := NewWAVStreamer(path, DefaultChunkSize, 0)
+	if err != nil {
+		t.Fatalf("NewWAVStreamer failed: %v", err)
+	}
+	defer streamer.Close()
+
+	if err := streamer.SetChunkDuration(20 * time.Millisecond); err != nil {
+		t.Fatalf("SetChunkDuration failed: %v", err)
+	}
+	if size, interval := streamer.GetChunking(); size != 1280 || interval != 20*time.Millisecond {
+		t.Errorf("expected 1280 bytes every 20ms, got %d bytes every %s", size, interval)
+	}
+
+	// Chunk sizes follow the streamed format, not the file format
+	if err := streamer.SetTransform(Transform{SampleRate: 8000, Channels: 1}); err != nil {
+		t.Fatalf("SetTransform failed: %v", err)
+	}
+	if size, _ := streamer.GetChunking(); size != 320 {
+		t.Errorf("expected 320 bytes per chunk after conversion, got %d", size)
+	}
+	if d := streamer.StreamDuration(); d != 100*time.Millisecond {
+		t.Errorf("expected the audio to stream in 100ms, got %s", d)
+	}
+
+	tests := []struct {
+		factor float64
+		want   time.Duration
+	}{
+		{2, 10 * time.Millisecond},
+		{0.5, 40 * time.Millisecond},
+		{0, 0},
+	}
+	for _, tt := range tests {
+		if err := streamer.SetRealtimeFactor(tt.factor); err != nil {
+			t.Fatalf("SetRealtimeFactor(%g) failed: %v", tt.factor, err)
+		}
+		if _, interval := streamer.GetChunking(); interval != tt.want {
+			t.Errorf("factor %g: expected interval %s, got %s", tt.factor, tt.want, interval)
+		}
+	}
+
+	if err := streamer.SetRealtimeFactor(-1); err == nil {
+		t.Error("expected error for negative realtime factor")
+	}
+	if err := streamer.SetChunkDuration(0); err == nil {
+		t.Error("expected error for zero chunk duration")
+	}
+}
+
+func TestWAVStreamer_PacesWithoutDrift(t *testing.T) {
+	// 10 chunks of 10ms each, read through a buffer smaller than a chunk
+	path := writeFile(t, riffFile(riffChunk("fmt ", fmtBody(formatPCM, 1, 16000, 16)), riffChunk("data", make([]byte, 3200))))
+
+	streamer, err := NewWAVStreamer(path, DefaultChunkSize, 0)
+	if err != nil {
+		t.Fatalf("NewWAVStreamer failed: %v", err)
+	}
+	defer streamer.Close()
+	if err := streamer.SetChunkDuration(10 * time.Millisecond); err != nil {
+		t.Fatalf("SetChunkDuration failed: %v", err)
+	}
+
+	stream, err := streamer.Stream()
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	start := time.Now()
+	if _, err := io.CopyBuffer(io.Discard, stream, make([]byte, 100)); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if elapsed < 100*time.Millisecond {
+		t.Errorf("streamed 100ms of audio in %s, faster than real time", elapsed)
+	}
+	sends := streamer.Sends()
+	if len(sends) != 10 {
+		t.Fatalf("expected 10 chunks, got %d", len(sends))
+	}
+	errors := streamer.PacingErrors()
+	if len(errors) != 10 {
+		t.Fatalf("expected 10 pacing errors, got %d", len(errors))
+	}
+
+	// Every chunk is scheduled from the start, so lateness does not add up
+	for i, send := range sends {
+		due := start.Add(time.Duration(i+1) * 10 * time.Millisecond)
+		if early := due.Sub(send.At); early > time.Millisecond {
+			t.Errorf("chunk %d handed out %s early", i+1, early)
+		}
+	}
+}
+
+func TestWAVStreamer_PacesByChunkAudio(t *testing.T) {
+	// 4 chunks of 30ms and a last one of 20ms, sent at twice real time
+	path := writeFile(t, riffFile(riffChunk("fmt ", fmtBody(formatPCM, 1, 16000, 16)), riffChunk("data", make([]byte, 4480))))
+
+	streamer, err := NewWAVStreamer(path, 960, 0)
+	if err != nil {
+		t.Fatalf("NewWAVStreamer failed: %v", err)
+	}
+	defer streamer.Close()
+	if err := streamer.SetRealtimeFactor(2); err != nil {
+		t.Fatalf("SetRealtimeFactor failed: %v", err)
+	}
+	if size, interval := streamer.GetChunking(); size != 960 || interval != 15*time.Millisecond {
+		t.Errorf("expected 960 bytes every 15ms, got %d bytes every %s", size, interval)
+	}
+	if d := streamer.StreamDuration(); d != 70*time.Millisecond {
+		t.Errorf("expected the audio to stream in 70ms, got %s", d)
+	}
+
+	stream, err := streamer.Stream()
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	start := time.Now()
+	if _, err := io.Copy(io.Discard, stream); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+
+	sends := streamer.Sends()
+	if len(sends) != 5 {
+		t.Fatalf("expected 5 chunks, got %d", len(sends))
+	}
+	// Each chunk is due once its audio would have played at twice real time
+	for i, send := range sends {
+		due := start.Add(send.Offset / 2)
+		if early := due.Sub(send.At); early > time.Millisecond {
+			t.Errorf("chunk %d handed out %s early", i+1, early)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Errorf("streamed 140ms of audio in %s, faster than twice real time", elapsed)
+	}
+}
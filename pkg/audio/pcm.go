@@ -0,0 +1,159 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// EncodingLinear16 is the encoding of the audio handed out by the streamer:
+// 16-bit signed little-endian linear PCM
+const EncodingLinear16 = "linear16"
+
+// WAVE format tags
+const (
+	formatIEEEFloat  = 3
+	formatALaw       = 6
+	formatMuLaw      = 7
+	formatExtensible = 0xFFFE
+)
+
+// sampleDecoder converts one encoded sample to 16-bit linear PCM
+type sampleDecoder func([]byte) int16
+
+// decoderFor returns the decoder of a format tag and sample size
+func decoderFor(formatTag, bitsPerSample int) (sampleDecoder, error) {
+	switch {
+	case formatTag == formatPCM && bitsPerSample == 8:
+		// 8-bit PCM is unsigned, centered on 128
+		return func(b []byte) int16 { return (int16(b[0]) - 128) << 8 }, nil
+	case formatTag == formatPCM && bitsPerSample == 16:
+		return func(b []byte) int16 { return int16(binary.LittleEndian.Uint16(b)) }, nil
+	case formatTag == formatPCM && bitsPerSample == 24:
+		return func(b []byte) int16 { return int16(b[1]) | int16(int8(b[2]))<<8 }, nil
+	case formatTag == formatPCM && bitsPerSample == 32:
+		return func(b []byte) int16 { return int16(binary.LittleEndian.Uint32(b) >> 16) }, nil
+	case formatTag == formatIEEEFloat && bitsPerSample == 32:
+		return func(b []byte) int16 {
+			return floatToPCM16(float64(math.Float32frombits(binary.LittleEndian.Uint32(b))))
+		}, nil
+	case formatTag == formatIEEEFloat && bitsPerSample == 64:
+		return func(b []byte) int16 { return floatToPCM16(math.Float64frombits(binary.LittleEndian.Uint64(b))) }, nil
+	case formatTag == formatALaw && bitsPerSample == 8:
+		return func(b []byte) int16 { return decodeALaw(b[0]) }, nil
+	case formatTag == formatMuLaw && bitsPerSample == 8:
+		return func(b []byte) int16 { return decodeMuLaw(b[0]) }, nil
+	}
+	return nil, fmt.Errorf("unsupported WAV encoding: %d-bit %s", bitsPerSample, formatName(formatTag))
+}
+
+// formatName returns a readable name of a format tag
+func formatName(formatTag int) string {
+	switch formatTag {
+	case formatPCM:
+		return "PCM"
+	case formatIEEEFloat:
+		return "float"
+	case formatALaw:
+		return "A-law"
+	case formatMuLaw:
+		return "mu-law"
+	default:
+		return fmt.Sprintf("format 0x%04X", formatTag)
+	}
+}
+
+// floatToPCM16 scales a [-1, 1] sample to 16 bits, clipping out of range values
+func floatToPCM16(v float64) int16 {
+	switch {
+	case math.IsNaN(v):
+		return 0
+	case v >= 1:
+		return math.MaxInt16
+	case v <= -1:
+		return math.MinInt16
+	}
+	return int16(math.Round(v * math.MaxInt16))
+}
+
+// decodeMuLaw expands a G.711 mu-law sample
+func decodeMuLaw(u byte) int16 {
+	u = ^u
+	exponent := (u >> 4) & 0x07
+	magnitude := ((int16(u&0x0F) << 3) + 0x84) << exponent
+	if u&0x80 != 0 {
+		return 0x84 - magnitude
+	}
+	return magnitude - 0x84
+}
+
+// decodeALaw expands a G.711 A-law sample
+func decodeALaw(a byte) int16 {
+	a ^= 0x55
+	exponent := (a >> 4) & 0x07
+	magnitude := int16(a&0x0F)<<4 + 8
+	if exponent != 0 {
+		magnitude = (magnitude + 0x100) << (exponent - 1)
+	}
+	if a&0x80 != 0 {
+		return magnitude
+	}
+	return -magnitude
+}
+
+// pcm16Reader decodes whole samples from src into 16-bit linear PCM
+type pcm16Reader struct {
+	src         io.Reader
+	decode      sampleDecoder
+	sampleBytes int
+	in          []byte
+	out         []byte
+	pending     []byte // decoded bytes not returned yet
+}
+
+// newPCM16Reader wraps src, returning it unchanged when it already holds 16-bit PCM
+func newPCM16Reader(src io.Reader, formatTag, bitsPerSample int) (io.Reader, error) {
+	decode, err := decoderFor(formatTag, bitsPerSample)
+	if err != nil {
+		return nil, err
+	}
+	if formatTag == formatPCM && bitsPerSample == 16 {
+		return src, nil
+	}
+	return &pcm16Reader{src: src, decode: decode, sampleBytes: bitsPerSample / 8}, nil
+}
+
+func (r *pcm16Reader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if len(r.pending) == 0 {
+		// Read as many whole source samples as fit in p once decoded
+		samples := max(len(p)/2, 1)
+		if cap(r.in) < samples*r.sampleBytes {
+			r.in = make([]byte, samples*r.sampleBytes)
+			r.out = make([]byte, samples*2)
+		}
+		in := r.in[:samples*r.sampleBytes]
+
+		n, err := io.ReadFull(r.src, in)
+		n -= n % r.sampleBytes
+		if n == 0 {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				err = io.EOF
+			}
+			return 0, err
+		}
+		for i := 0; i < n/r.sampleBytes; i++ {
+			sample := r.decode(in[i*r.sampleBytes : (i+1)*r.sampleBytes])
+			binary.LittleEndian.PutUint16(r.out[i*2:], uint16(sample))
+		}
+		r.pending = r.out[:n/r.sampleBytes*2]
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
@@ -0,0 +1,142 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecoders(t *testing.T) {
+	float32Bytes := func(v float32) []byte {
+		return binary.LittleEndian.AppendUint32(nil, math.Float32bits(v))
+	}
+	float64Bytes := func(v float64) []byte {
+		return binary.LittleEndian.AppendUint64(nil, math.Float64bits(v))
+	}
+
+	tests := []struct {
+		name          string
+		formatTag     int
+		bitsPerSample int
+		input         []byte
+		want          int16
+	}{
+		{"8-bit silence", formatPCM, 8, []byte{128}, 0},
+		{"8-bit min", formatPCM, 8, []byte{0}, math.MinInt16},
+		{"8-bit max", formatPCM, 8, []byte{255}, 127 << 8},
+		{"16-bit", formatPCM, 16, []byte{0x34, 0x12}, 0x1234},
+		{"24-bit positive", formatPCM, 24, []byte{0xFF, 0x34, 0x12}, 0x1234},
+		{"24-bit negative", formatPCM, 24, []byte{0x00, 0x00, 0x80}, math.MinInt16},
+		{"32-bit", formatPCM, 32, []byte{0xFF, 0xFF, 0x34, 0x12}, 0x1234},
+		{"float32 half", formatIEEEFloat, 32, float32Bytes(0.5), 16384},
+		{"float32 clipped", formatIEEEFloat, 32, float32Bytes(-1.5), math.MinInt16},
+		{"float64 full scale", formatIEEEFloat, 64, float64Bytes(1), math.MaxInt16},
+		{"mu-law zero", formatMuLaw, 8, []byte{0xFF}, 0},
+		{"mu-law max", formatMuLaw, 8, []byte{0x80}, 32124},
+		{"mu-law min", formatMuLaw, 8, []byte{0x00}, -32124},
+		{"A-law small", formatALaw, 8, []byte{0xD5}, 8},
+		{"A-law max", formatALaw, 8, []byte{0xAA}, 32256},
+		{"A-law min", formatALaw, 8, []byte{0x2A}, -32256},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decode, err := decoderFor(tt.formatTag, tt.bitsPerSample)
+			if err != nil {
+				t.Fatalf("decoderFor failed: %v", err)
+			}
+			if got := decode(tt.input); got != tt.want {
+				t.Errorf("decode(%v) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := decoderFor(formatIEEEFloat, 16); err == nil {
+		t.Error("expected error for 16-bit float")
+	}
+}
+
+func TestPCM16Reader(t *testing.T) {
+	// Three 24-bit samples read one byte at a time through odd sized buffers
+	src := []byte{0, 1, 0, 0, 2, 0, 0, 0xFF, 0xFF}
+	reader, err := newPCM16Reader(iotest.OneByteReader(bytes.NewReader(src)), formatPCM, 24)
+	if err != nil {
+		t.Fatalf("newPCM16Reader failed: %v", err)
+	}
+
+	var got []byte
+	buf := make([]byte, 3)
+	for {
+		n, err := reader.Read(buf)
+		got = append(got, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+	}
+
+	want := []byte{1, 0, 2, 0, 0xFF, 0xFF}
+	if !bytes.Equal(got, want) {
+		t.Errorf("decoded %v, want %v", got, want)
+	}
+}
+
+func TestParseWAV_Extensible(t *testing.T) {
+	body := make([]byte, 40)
+	copy(body, fmtBody(formatExtensible, 2, 48000, 32))
+	binary.LittleEndian.PutUint16(body[16:18], 22)
+	binary.LittleEndian.PutUint16(body[18:20], 32)
+	binary.LittleEndian.PutUint16(body[24:26], formatIEEEFloat)
+
+	data := riffFile(riffChunk("fmt ", body), riffChunk("fact", make([]byte, 4)), riffChunk("data", make([]byte, 16)))
+	format, err := parseWAV(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("parseWAV failed: %v", err)
+	}
+	if format.formatTag != formatIEEEFloat || format.bitsPerSample != 32 || format.channels != 2 {
+		t.Errorf("unexpected format %+v", format)
+	}
+}
+
+func TestWAVStreamer_ConvertsToLinear16(t *testing.T) {
+	samples := []float32{0, 0.5, -0.5, 1}
+	var audio []byte
+	for _, s := range samples {
+		audio = binary.LittleEndian.AppendUint32(audio, math.Float32bits(s))
+	}
+	path := writeFile(t, riffFile(riffChunk("fmt ", fmtBody(formatIEEEFloat, 1, 8000, 32)), riffChunk("data", audio)))
+
+	streamer, err := NewWAVStreamer(path, 3, 0)
+	if err != nil {
+		t.Fatalf("NewWAVStreamer failed: %v", err)
+	}
+	defer streamer.Close()
+
+	if _, _, bytesPerSample := streamer.GetAudioFormat(); bytesPerSample != 2 || streamer.Encoding() != EncodingLinear16 {
+		t.Errorf("expected 16-bit linear output, got %d bytes %s", bytesPerSample, streamer.Encoding())
+	}
+	if !strings.Contains(streamer.SourceFormat(), "32-bit float") {
+		t.Errorf("unexpected source format %q", streamer.SourceFormat())
+	}
+
+	stream, _ := streamer.Stream()
+	got, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	want := []int16{0, 16384, -16384, math.MaxInt16}
+	if len(got) != len(want)*2 {
+		t.Fatalf("expected %d bytes, got %d", len(want)*2, len(got))
+	}
+	for i, w := range want {
+		if s := int16(binary.LittleEndian.Uint16(got[i*2:])); s != w {
+			t.Errorf("sample %d = %d, want %d", i, s, w)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// formatPCM is the WAVE format tag of linear PCM
+const formatPCM = 1
+
+// wavFormat describes the audio stored in a WAV file
+type wavFormat struct {
+	formatTag     int
+	channels      int
+	sampleRate    int
+	bitsPerSample int
+	blockAlign    int
+	dataOffset    int64 // position of the first audio byte in the file
+	dataSize      int64 // length of the audio data in bytes
+}
+
+// parseWAV walks the RIFF chunks of a WAV file to find its format and audio data,
+// skipping any other chunk (LIST, fact, bext, JUNK, ...) wherever it appears
+func parseWAV(r io.ReadSeeker) (*wavFormat, error) {
+	fileSize, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WAV file size: %w", err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek to WAV header: %w", err)
+	}
+
+	riff := make([]byte, 12)
+	if _, err := io.ReadFull(r, riff); err != nil {
+		return nil, fmt.Errorf("failed to read WAV header: %w", truncated(err))
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("invalid WAV file format")
+	}
+
+	var format *wavFormat
+	offset := int64(12)
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				if format == nil {
+					return nil, fmt.Errorf("invalid WAV file: missing fmt chunk")
+				}
+				return nil, fmt.Errorf("invalid WAV file: missing data chunk")
+			}
+			return nil, fmt.Errorf("failed to read chunk header: %w", truncated(err))
+		}
+		id := string(header[0:4])
+		size := int64(binary.LittleEndian.Uint32(header[4:8]))
+		offset += 8
+
+		padding := size % 2
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, fmt.Errorf("invalid WAV file: fmt chunk too short (%d bytes)", size)
+			}
+			body := make([]byte, size)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return nil, fmt.Errorf("failed to read fmt chunk: %w", truncated(err))
+			}
+			format = parseFormatChunk(body)
+			if _, err := r.Seek(padding, io.SeekCurrent); err != nil {
+				return nil, fmt.Errorf("failed to skip fmt chunk padding: %w", err)
+			}
+
+		case "data":
+			if format == nil {
+				return nil, fmt.Errorf("invalid WAV file: data chunk before fmt chunk")
+			}
+			if err := format.validate(); err != nil {
+				return nil, err
+			}
+			// Files written by live recorders may leave the size unset, 0 or 0xFFFFFFFF,
+			// meaning the audio runs to the end of the file
+			available := fileSize - offset
+			if size == 0 || size == math.MaxUint32 {
+				size = available
+			} else if size > available {
+				return nil, fmt.Errorf("invalid WAV file: truncated \"data\" chunk, %d of %d bytes", available, size)
+			}
+			size -= size % int64(format.blockAlign)
+			format.dataOffset = offset
+			format.dataSize = size
+			return format, nil
+
+		default:
+			// Skip the chunk body and its padding byte when the size is odd
+			if offset+size > fileSize {
+				return nil, fmt.Errorf("invalid WAV file: truncated %q chunk", id)
+			}
+			if _, err := r.Seek(size+padding, io.SeekCurrent); err != nil {
+				return nil, fmt.Errorf("failed to skip %q chunk: %w", id, err)
+			}
+		}
+		offset += size + padding
+	}
+}
+
+// WAVDuration returns the duration of the audio stored in a WAV file
+func WAVDuration(r io.ReadSeeker) (time.Duration, error) {
+	format, err := parseWAV(r)
+	if err != nil {
+		return 0, err
+	}
+	frames := format.dataSize / int64(format.blockAlign)
+	return time.Duration(frames * int64(time.Second) / int64(format.sampleRate)), nil
+}
+
+// parseFormatChunk decodes the body of a fmt chunk
+func parseFormatChunk(body []byte) *wavFormat {
+	format := &wavFormat{
+		formatTag:     int(binary.LittleEndian.Uint16(body[0:2])),
+		channels:      int(binary.LittleEndian.Uint16(body[2:4])),
+		sampleRate:    int(binary.LittleEndian.Uint32(body[4:8])),
+		blockAlign:    int(binary.LittleEndian.Uint16(body[12:14])),
+		bitsPerSample: int(binary.LittleEndian.Uint16(body[14:16])),
+	}
+
+	// WAVE_FORMAT_EXTENSIBLE carries the actual format tag at the start of its sub-format GUID
+	if format.formatTag == formatExtensible && len(body) >= 40 {
+		format.formatTag = int(binary.LittleEndian.Uint16(body[24:26]))
+	}
+	return format
+}
+
+// validate checks that the format can be streamed
+func (f *wavFormat) validate() error {
+	switch f.formatTag {
+	case formatPCM, formatIEEEFloat, formatALaw, formatMuLaw:
+	default:
+		return fmt.Errorf("unsupported WAV format tag 0x%04X", f.formatTag)
+	}
+	if _, err := decoderFor(f.formatTag, f.bitsPerSample); err != nil {
+		return err
+	}
+	if f.channels == 0 || f.sampleRate == 0 || f.bitsPerSample == 0 {
+		return fmt.Errorf("invalid WAV format: %d channels, %d Hz, %d bits per sample", f.channels, f.sampleRate, f.bitsPerSample)
+	}
+	if f.blockAlign != f.channels*((f.bitsPerSample+7)/8) {
+		return fmt.Errorf("invalid WAV format: block align %d does not match %d channels of %d bits", f.blockAlign, f.channels, f.bitsPerSample)
+	}
+	return nil
+}
+
+// truncated reports a short read as a truncated file
+func truncated(err error) error {
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return fmt.Errorf("file is truncated")
+	}
+	return err
+}
@@ -0,0 +1,157 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// riffChunk builds a chunk with its padding byte when the body has an odd size
+func riffChunk(id string, body []byte) []byte {
+	chunk := make([]byte, 8, 8+len(body)+1)
+	copy(chunk[0:4], id)
+	binary.LittleEndian.PutUint32(chunk[4:8], uint32(len(body)))
+	chunk = append(chunk, body...)
+	if len(body)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// fmtBody builds the body of a fmt chunk
+func fmtBody(formatTag, channels, sampleRate, bitsPerSample int) []byte {
+	blockAlign := channels * bitsPerSample / 8
+	body := make([]byte, 16)
+	binary.LittleEndian.PutUint16(body[0:2], uint16(formatTag))
+	binary.LittleEndian.PutUint16(body[2:4], uint16(channels))
+	binary.LittleEndian.PutUint32(body[4:8], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(body[8:12], uint32(sampleRate*blockAlign))
+	binary.LittleEndian.PutUint16(body[12:14], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(body[14:16], uint16(bitsPerSample))
+	return body
+}
+
+// riffFile assembles a RIFF/WAVE file from chunks
+func riffFile(chunks ...[]byte) []byte {
+	body := []byte("WAVE")
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+	file := make([]byte, 8)
+	copy(file[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(file[4:8], uint32(len(body)))
+	return append(file, body...)
+}
+
+func writeFile(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.wav")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseWAV_SkipsMetadataChunks(t *testing.T) {
+	audio := []byte{1, 0, 2, 0, 3, 0, 4, 0}
+	data := riffFile(
+		riffChunk("JUNK", make([]byte, 28)),
+		riffChunk("fmt ", fmtBody(formatPCM, 1, 16000, 16)),
+		riffChunk("bext", make([]byte, 603)), // odd size, padded
+		riffChunk("fact", []byte{4, 0, 0, 0}),
+		riffChunk("data", audio),
+		riffChunk("LIST", []byte("INFOISFT\x05\x00\x00\x00Test\x00")),
+	)
+
+	format, err := parseWAV(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("parseWAV failed: %v", err)
+	}
+	if format.sampleRate != 16000 || format.channels != 1 || format.bitsPerSample != 16 {
+		t.Errorf("unexpected format %+v", format)
+	}
+	if got := data[format.dataOffset : format.dataOffset+format.dataSize]; !bytes.Equal(got, audio) {
+		t.Errorf("data chunk = %v, want %v", got, audio)
+	}
+}
+
+func TestParseWAV_Errors(t *testing.T) {
+	pcm := riffChunk("fmt ", fmtBody(formatPCM, 1, 16000, 16))
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"not a RIFF file", []byte("OggS0000WAVEfmt "), "invalid WAV file format"},
+		{"truncated header", []byte("RIFF"), "file is truncated"},
+		{"missing fmt", riffFile(riffChunk("LIST", []byte("INFO"))), "missing fmt chunk"},
+		{"missing data", riffFile(pcm), "missing data chunk"},
+		{"data before fmt", riffFile(riffChunk("data", []byte{0, 0}), pcm), "data chunk before fmt chunk"},
+		{"non PCM", riffFile(riffChunk("fmt ", fmtBody(0x55, 1, 16000, 16)), riffChunk("data", []byte{0, 0})), "unsupported WAV format tag 0x0055"},
+		{"truncated chunk", riffFile(pcm, []byte("LIST\xff\x00\x00\x00INFO")), "truncated \"LIST\" chunk"},
+		{"short fmt", riffFile(riffChunk("fmt ", make([]byte, 8))), "fmt chunk too short"},
+		{"truncated data", append(riffFile(pcm), []byte("data\x10\x00\x00\x00\x00\x00")...), "truncated \"data\" chunk, 2 of 16 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseWAV(bytes.NewReader(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestParseWAV_UnsetDataSize(t *testing.T) {
+	// Recorders that are interrupted leave the data size unset
+	for _, size := range []string{"\x00\x00\x00\x00", "\xff\xff\xff\xff"} {
+		data := riffFile(riffChunk("fmt ", fmtBody(formatPCM, 2, 8000, 16)))
+		data = append(data, []byte("data"+size)...)
+		data = append(data, make([]byte, 10)...)
+
+		format, err := parseWAV(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("parseWAV failed: %v", err)
+		}
+		if format.dataSize != 8 {
+			t.Errorf("expected data to the end of file in 8 whole frames bytes, got %d", format.dataSize)
+		}
+	}
+}
+
+func TestWAVStreamer_StreamsOnlyAudioData(t *testing.T) {
+	audio := bytes.Repeat([]byte{7, 0}, 100)
+	path := writeFile(t, riffFile(
+		riffChunk("fmt ", fmtBody(formatPCM, 1, 8000, 16)),
+		riffChunk("bext", make([]byte, 64)),
+		riffChunk("data", audio),
+		riffChunk("LIST", make([]byte, 32)),
+	))
+
+	streamer, err := NewWAVStreamer(path, 64, 0)
+	if err != nil {
+		t.Fatalf("NewWAVStreamer failed: %v", err)
+	}
+	defer streamer.Close()
+
+	stream, err := streamer.Stream()
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	got, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if !bytes.Equal(got, audio) {
+		t.Errorf("streamed %d bytes, want the %d audio bytes", len(got), len(audio))
+	}
+	if sent := streamer.SentDuration(); sent != 12500*time.Microsecond {
+		t.Errorf("SentDuration = %s, want 12.5ms", sent)
+	}
+}
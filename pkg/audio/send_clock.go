@@ -0,0 +1,71 @@
+package audio
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Send records when a chunk of audio was handed to the provider
+type Send struct {
+	At     time.Time     // wall-clock time the chunk was handed out
+	Offset time.Duration // audio handed out so far, including this chunk
+}
+
+// sendClock tracks the audio handed out by a stream against the wall clock
+type sendClock struct {
+	mu             sync.Mutex
+	bytesPerSecond int64
+	sent           int64
+	sends          []Send
+}
+
+// reset forgets everything sent so far
+func (c *sendClock) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = 0
+	c.sends = nil
+}
+
+// record stamps n more bytes as sent now
+func (c *sendClock) record(n int) {
+	if n <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent += int64(n)
+	c.sends = append(c.sends, Send{At: time.Now(), Offset: c.duration(c.sent)})
+}
+
+// duration converts a byte count to audio duration
+func (c *sendClock) duration(bytes int64) time.Duration {
+	if c.bytesPerSecond == 0 {
+		return 0
+	}
+	return time.Duration(bytes * int64(time.Second) / c.bytesPerSecond)
+}
+
+// SentDuration returns the duration of the audio sent so far
+func (c *sendClock) SentDuration() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.duration(c.sent)
+}
+
+// Sends returns a copy of the recorded sends in order
+func (c *sendClock) Sends() []Send {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Send(nil), c.sends...)
+}
+
+// SentAt returns when the audio up to offset had been sent, searching sends in order
+func SentAt(sends []Send, offset time.Duration) (time.Time, bool) {
+	i := sort.Search(len(sends), func(i int) bool { return sends[i].Offset >= offset })
+	if i == len(sends) {
+		return time.Time{}, false
+	}
+	return sends[i].At, true
+}
@@ -0,0 +1,313 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ResampleQuality selects the interpolation filter of the resampler
+type ResampleQuality string
+
+const (
+	// QualityLow interpolates linearly between neighbouring samples
+	QualityLow ResampleQuality = "low"
+	// QualityMedium uses a 16-tap Hann windowed sinc filter
+	QualityMedium ResampleQuality = "medium"
+	// QualityHigh uses a 64-tap Blackman windowed sinc filter
+	QualityHigh ResampleQuality = "high"
+)
+
+// ParseResampleQuality validates a resampling quality
+func ParseResampleQuality(quality string) (ResampleQuality, error) {
+	switch ResampleQuality(quality) {
+	case QualityLow, QualityMedium, QualityHigh:
+		return ResampleQuality(quality), nil
+	default:
+		return "", fmt.Errorf("unknown resample quality: %s (expected low, medium or high)", quality)
+	}
+}
+
+// Transform describes the conversion applied to the audio before it is streamed
+type Transform struct {
+	SampleRate    int             // target sample rate, 0 keeps the source rate
+	Channels      int             // target channel count, 0 keeps the source channels
+	SelectChannel int             // 1-based source channel to stream alone, 0 mixes all channels
+	Quality       ResampleQuality // defaults to QualityMedium
+}
+
+// transformFormat is the format of the audio around a transform
+type transformFormat struct {
+	sampleRate int
+	channels   int
+}
+
+// output returns the format produced by the transform from source audio
+func (t Transform) output(source transformFormat) (transformFormat, error) {
+	out := source
+	if t.SelectChannel < 0 || t.SelectChannel > source.channels {
+		return out, fmt.Errorf("cannot select channel %d of %d", t.SelectChannel, source.channels)
+	}
+	if t.SelectChannel > 0 {
+		out.channels = 1
+	}
+	if t.Channels < 0 || t.SampleRate < 0 {
+		return out, fmt.Errorf("invalid target format: %d Hz, %d channels", t.SampleRate, t.Channels)
+	}
+	if t.Channels > 0 {
+		if t.SelectChannel > 0 && t.Channels != 1 {
+			return out, fmt.Errorf("selecting channel %d produces mono audio, not %d channels", t.SelectChannel, t.Channels)
+		}
+		out.channels = t.Channels
+	}
+	if t.SampleRate > 0 {
+		out.sampleRate = t.SampleRate
+	}
+	return out, nil
+}
+
+// mixChannels maps a source frame to the target channels: a selected channel is
+// streamed alone, mono is duplicated, and otherwise every source channel is
+// averaged into target channel (index mod target channels)
+func mixChannels(frame []float64, out []float64, selectChannel int) {
+	if selectChannel > 0 {
+		for i := range out {
+			out[i] = frame[selectChannel-1]
+		}
+		return
+	}
+	if len(frame) == len(out) {
+		copy(out, frame)
+		return
+	}
+	if len(frame) == 1 {
+		for i := range out {
+			out[i] = frame[0]
+		}
+		return
+	}
+
+	counts := make([]int, len(out))
+	for i := range out {
+		out[i] = 0
+	}
+	for i, v := range frame {
+		out[i%len(out)] += v
+		counts[i%len(out)]++
+	}
+	for i := range out {
+		if counts[i] > 0 {
+			out[i] /= float64(counts[i])
+		}
+	}
+}
+
+// resampler converts a stream of frames between sample rates
+type resampler struct {
+	sourceRate int64
+	targetRate int64
+	channels   int
+	halfTaps   int     // filter half-width in source samples, 0 for linear interpolation
+	cutoff     float64 // normalized low-pass cutoff, below 1 when downsampling
+	window     func(float64) float64
+
+	history  [][]float64 // buffered source frames, history[0] is source frame start
+	start    int64
+	received int64 // source frames received
+	next     int64 // next output frame
+}
+
+// newResampler creates a resampler between two rates
+func newResampler(sourceRate, targetRate, channels int, quality ResampleQuality) *resampler {
+	r := &resampler{
+		sourceRate: int64(sourceRate),
+		targetRate: int64(targetRate),
+		channels:   channels,
+		cutoff:     math.Min(1, float64(targetRate)/float64(sourceRate)),
+	}
+	switch quality {
+	case QualityLow:
+	case QualityHigh:
+		r.halfTaps = 32
+		r.window = func(x float64) float64 { return 0.42 + 0.5*math.Cos(math.Pi*x) + 0.08*math.Cos(2*math.Pi*x) }
+	default:
+		r.halfTaps = 8
+		r.window = func(x float64) float64 { return 0.5 + 0.5*math.Cos(math.Pi*x) }
+	}
+	// Widen the filter when downsampling so it keeps the same number of zero crossings
+	if r.halfTaps > 0 && r.cutoff < 1 {
+		r.halfTaps = int(math.Ceil(float64(r.halfTaps) / r.cutoff))
+	}
+	return r
+}
+
+// push buffers a source frame
+func (r *resampler) push(frame []float64) {
+	r.history = append(r.history, append([]float64(nil), frame...))
+	r.received++
+}
+
+// source returns a buffered source sample, zero outside the received audio
+func (r *resampler) source(index int64, channel int) float64 {
+	if index < r.start || index >= r.start+int64(len(r.history)) {
+		return 0
+	}
+	return r.history[index-r.start][channel]
+}
+
+// pull appends every output frame computable from the buffered audio; at the
+// end of the stream, missing lookahead is treated as silence
+func (r *resampler) pull(out [][]float64, final bool) [][]float64 {
+	lookahead := int64(max(r.halfTaps, 1))
+	for r.next*r.sourceRate < r.received*r.targetRate {
+		position := float64(r.next*r.sourceRate) / float64(r.targetRate)
+		base := int64(math.Floor(position))
+		if !final && base+lookahead >= r.received {
+			break
+		}
+
+		frame := make([]float64, r.channels)
+		if r.halfTaps == 0 {
+			frac := position - float64(base)
+			for c := range frame {
+				frame[c] = r.source(base, c)*(1-frac) + r.source(base+1, c)*frac
+			}
+		} else {
+			for j := base - int64(r.halfTaps) + 1; j <= base+int64(r.halfTaps); j++ {
+				x := position - float64(j)
+				weight := r.cutoff * sinc(r.cutoff*x) * r.window(x/float64(r.halfTaps))
+				for c := range frame {
+					frame[c] += r.source(j, c) * weight
+				}
+			}
+		}
+		out = append(out, frame)
+		r.next++
+	}
+
+	// Forget the source frames the filter will not reach anymore
+	oldest := int64(float64(r.next*r.sourceRate)/float64(r.targetRate)) - int64(r.halfTaps)
+	if drop := oldest - r.start; drop > 0 {
+		drop = min(drop, int64(len(r.history)))
+		r.history = r.history[drop:]
+		r.start += drop
+	}
+	return out
+}
+
+// sinc is the normalized sinc function
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// outputFrames returns how many frames the resampler produces from a source length
+func outputFrames(sourceFrames int64, sourceRate, targetRate int) int64 {
+	return (sourceFrames*int64(targetRate) + int64(sourceRate) - 1) / int64(sourceRate)
+}
+
+// transformReader applies a transform to interleaved 16-bit PCM audio
+type transformReader struct {
+	src           io.Reader
+	source        transformFormat
+	target        transformFormat
+	selectChannel int
+	resampler     *resampler // nil when the sample rate is kept
+
+	in      []byte
+	frame   []float64
+	mixed   []float64
+	frames  [][]float64
+	pending []byte
+	eof     bool
+}
+
+// newTransformReader wraps src, returning it unchanged when the transform is an identity
+func newTransformReader(src io.Reader, source transformFormat, t Transform) (io.Reader, error) {
+	target, err := t.output(source)
+	if err != nil {
+		return nil, err
+	}
+	if target == source && t.SelectChannel == 0 {
+		return src, nil
+	}
+
+	r := &transformReader{
+		src:           src,
+		source:        source,
+		target:        target,
+		selectChannel: t.SelectChannel,
+		frame:         make([]float64, source.channels),
+		mixed:         make([]float64, target.channels),
+	}
+	if target.sampleRate != source.sampleRate {
+		r.resampler = newResampler(source.sampleRate, target.sampleRate, target.channels, t.Quality)
+	}
+	return r, nil
+}
+
+func (r *transformReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+		if err := r.fill(max(len(p)/(2*r.target.channels), 1)); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// fill converts about the given number of target frames into pending
+func (r *transformReader) fill(frames int) error {
+	// Read the source frames the target frames are made of
+	sourceFrames := frames
+	if r.resampler != nil {
+		sourceFrames = int(outputFrames(int64(frames), r.target.sampleRate, r.source.sampleRate))
+	}
+	frameBytes := 2 * r.source.channels
+	if cap(r.in) < sourceFrames*frameBytes {
+		r.in = make([]byte, sourceFrames*frameBytes)
+	}
+	in := r.in[:sourceFrames*frameBytes]
+
+	n, err := io.ReadFull(r.src, in)
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		r.eof = true
+	default:
+		return err
+	}
+
+	r.frames = r.frames[:0]
+	for i := 0; i+frameBytes <= n; i += frameBytes {
+		for c := range r.frame {
+			r.frame[c] = float64(int16(binary.LittleEndian.Uint16(in[i+2*c:]))) / 32768
+		}
+		mixChannels(r.frame, r.mixed, r.selectChannel)
+		if r.resampler != nil {
+			r.resampler.push(r.mixed)
+		} else {
+			r.frames = append(r.frames, append([]float64(nil), r.mixed...))
+		}
+	}
+	if r.resampler != nil {
+		r.frames = r.resampler.pull(r.frames, r.eof)
+	}
+
+	out := make([]byte, 0, len(r.frames)*2*r.target.channels)
+	for _, frame := range r.frames {
+		for _, v := range frame {
+			out = binary.LittleEndian.AppendUint16(out, uint16(floatToPCM16(v)))
+		}
+	}
+	r.pending = out
+	return nil
+}
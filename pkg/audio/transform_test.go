@@ -0,0 +1,206 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+	"testing/iotest"
+)
+
+// pcm16 encodes interleaved samples as 16-bit PCM
+func pcm16(samples ...int16) []byte {
+	var b []byte
+	for _, s := range samples {
+		b = binary.LittleEndian.AppendUint16(b, uint16(s))
+	}
+	return b
+}
+
+// samples16 decodes 16-bit PCM
+func samples16(b []byte) []int16 {
+	samples := make([]int16, len(b)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(b[i*2:]))
+	}
+	return samples
+}
+
+// sine generates a mono 16-bit tone
+func sine(frequency float64, sampleRate, frames int) []int16 {
+	samples := make([]int16, frames)
+	for i := range samples {
+		samples[i] = int16(16000 * math.Sin(2*math.Pi*frequency*float64(i)/float64(sampleRate)))
+	}
+	return samples
+}
+
+func TestParseResampleQuality(t *testing.T) {
+	for _, q := range []string{"low", "medium", "high"} {
+		if _, err := ParseResampleQuality(q); err != nil {
+			t.Errorf("ParseResampleQuality(%q) failed: %v", q, err)
+		}
+	}
+	if _, err := ParseResampleQuality("best"); err == nil {
+		t.Error("expected error for unknown quality")
+	}
+}
+
+func TestTransform_Output(t *testing.T) {
+	stereo := transformFormat{sampleRate: 48000, channels: 2}
+	tests := []struct {
+		name      string
+		transform Transform
+		want      transformFormat
+		wantErr   bool
+	}{
+		{"identity", Transform{}, stereo, false},
+		{"resample", Transform{SampleRate: 16000}, transformFormat{16000, 2}, false},
+		{"downmix", Transform{Channels: 1}, transformFormat{48000, 1}, false},
+		{"select", Transform{SelectChannel: 2}, transformFormat{48000, 1}, false},
+		{"select out of range", Transform{SelectChannel: 3}, stereo, true},
+		{"select with channels", Transform{SelectChannel: 1, Channels: 2}, stereo, true},
+		{"negative rate", Transform{SampleRate: -1}, stereo, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.transform.output(stereo)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("output() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("output() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformReader_Channels(t *testing.T) {
+	tests := []struct {
+		name      string
+		channels  int
+		transform Transform
+		input     []int16
+		want      []int16
+	}{
+		{"downmix stereo", 2, Transform{Channels: 1}, []int16{100, 300, -200, 0}, []int16{200, -100}},
+		{"select right", 2, Transform{SelectChannel: 2}, []int16{100, 300, -200, 0}, []int16{300, 0}},
+		{"duplicate mono", 1, Transform{Channels: 2}, []int16{100, -50}, []int16{100, 100, -50, -50}},
+		{"fold 4 to 2", 4, Transform{Channels: 2}, []int16{100, 10, 300, 30}, []int16{200, 20}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := transformFormat{sampleRate: 16000, channels: tt.channels}
+			reader, err := newTransformReader(iotest.OneByteReader(bytes.NewReader(pcm16(tt.input...))), source, tt.transform)
+			if err != nil {
+				t.Fatalf("newTransformReader failed: %v", err)
+			}
+			got, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			if !bytes.Equal(got, pcm16(tt.want...)) {
+				t.Errorf("got %v, want %v", samples16(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformReader_Resample(t *testing.T) {
+	tests := []struct {
+		sourceRate, targetRate int
+	}{
+		{48000, 16000},
+		{44100, 16000},
+		{8000, 16000},
+	}
+
+	// Largest sample error tolerated for each filter
+	tolerances := map[ResampleQuality]float64{QualityLow: 400, QualityMedium: 100, QualityHigh: 20}
+
+	for quality, tolerance := range tolerances {
+		for _, tt := range tests {
+			source := transformFormat{sampleRate: tt.sourceRate, channels: 1}
+			input := pcm16(sine(440, tt.sourceRate, tt.sourceRate/2)...)
+			reader, err := newTransformReader(bytes.NewReader(input), source, Transform{SampleRate: tt.targetRate, Quality: quality})
+			if err != nil {
+				t.Fatalf("newTransformReader failed: %v", err)
+			}
+
+			got, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			output := samples16(got)
+			if want := tt.targetRate / 2; len(output) != want {
+				t.Errorf("%s %d -> %d: got %d frames, want %d", quality, tt.sourceRate, tt.targetRate, len(output), want)
+				continue
+			}
+
+			// Away from the edges, the tone comes out unchanged
+			want := sine(440, tt.targetRate, len(output))
+			var worst float64
+			for i := 100; i < len(output)-100; i++ {
+				worst = math.Max(worst, math.Abs(float64(output[i])-float64(want[i])))
+			}
+			if worst > tolerance {
+				t.Errorf("%s %d -> %d: max error %.0f", quality, tt.sourceRate, tt.targetRate, worst)
+			}
+		}
+	}
+}
+
+func TestTransformReader_Identity(t *testing.T) {
+	src := bytes.NewReader(pcm16(1, 2))
+	reader, err := newTransformReader(src, transformFormat{sampleRate: 16000, channels: 1}, Transform{SampleRate: 16000, Channels: 1})
+	if err != nil {
+		t.Fatalf("newTransformReader failed: %v", err)
+	}
+	if reader != io.Reader(src) {
+		t.Error("expected the source reader when nothing is converted")
+	}
+}
+
+func TestWAVStreamer_Transform(t *testing.T) {
+	path := writeFile(t, riffFile(riffChunk("fmt ", fmtBody(formatPCM, 2, 32000, 16)), riffChunk("data", pcm16(make([]int16, 2*3200)...))))
+
+	streamer, err := NewWAVStreamer(path, 1024, 0)
+	if err != nil {
+		t.Fatalf("NewWAVStreamer failed: %v", err)
+	}
+	defer streamer.Close()
+
+	if err := streamer.SetTransform(Transform{SampleRate: 16000, Channels: 1}); err != nil {
+		t.Fatalf("SetTransform failed: %v", err)
+	}
+	if rate, channels, _ := streamer.GetAudioFormat(); rate != 16000 || channels != 1 {
+		t.Errorf("expected 16000 Hz mono, got %d Hz %d channels", rate, channels)
+	}
+
+	// The file uploaded to REST APIs is a WAV file of the converted audio
+	stream, err := streamer.Stream()
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	file, err := io.ReadAll(stream.(interface{ GetFile() io.Reader }).GetFile())
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	format, err := parseWAV(bytes.NewReader(file))
+	if err != nil {
+		t.Fatalf("parseWAV failed: %v", err)
+	}
+	if format.sampleRate != 16000 || format.channels != 1 || format.dataSize != 3200 || int64(len(file)) != format.dataOffset+3200 {
+		t.Errorf("unexpected converted file: %+v, %d bytes", format, len(file))
+	}
+	if sent := streamer.SentDuration(); sent.Milliseconds() != 100 {
+		t.Errorf("expected 100ms of audio sent, got %s", sent)
+	}
+
+	if err := streamer.SetTransform(Transform{SelectChannel: 3}); err == nil {
+		t.Error("expected error selecting a missing channel")
+	}
+}
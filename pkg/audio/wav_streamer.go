@@ -1,9 +1,11 @@
 package audio
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"time"
 )
@@ -11,112 +13,317 @@ import (
 const (
 	// DefaultChunkSize is the default size of audio chunks in bytes
 	DefaultChunkSize = 4096
-	// DefaultChunkInterval is the default interval between chunks in milliseconds
-	DefaultChunkInterval = 100
 )
 
 // WAVStreamer streams a WAV file in chunks to simulate real-time audio capture
 type WAVStreamer struct {
-	file           *os.File
-	chunkSize      int
-	chunkInterval  time.Duration
-	headerSize     int
-	sampleRate     int
-	bytesPerSample int
-	channels       int
+	file                *os.File
+	chunkSize           int
+	chunkInterval       time.Duration // fixed interval between chunks, 0 paces each chunk by its audio
+	chunkDuration       time.Duration // audio per chunk, overrides chunkSize and chunkInterval when set
+	realtimeFactor      float64       // pacing speed relative to real time, 0 streams unpaced
+	pacer               *pacer
+	dataOffset          int64 // position of the audio data in the file
+	dataSize            int64 // length of the audio data in bytes
+	formatTag           int   // encoding of the audio data in the file
+	sourceBitsPerSample int
+	sourceSampleRate    int
+	sourceChannels      int
+	transform           Transform
+	sampleRate          int // sample rate of the streamed audio
+	bytesPerSample      int
+	channels            int // channel count of the streamed audio
+	clock               *sendClock
 }
 
-// NewWAVStreamer creates a new WAV file streamer
+// NewWAVStreamer creates a new WAV file streamer; a chunkInterval of 0 hands out each
+// chunk once its audio would have played
 func NewWAVStreamer(filePath string, chunkSize int, chunkInterval time.Duration) (*WAVStreamer, error) {
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("chunk size must be positive, got %d", chunkSize)
+	}
+	if chunkInterval < 0 {
+		return nil, fmt.Errorf("chunk interval must not be negative, got %s", chunkInterval)
+	}
+
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open WAV file: %w", err)
 	}
 
-	// Read WAV header
-	header := make([]byte, 44)
-	if _, err := file.Read(header); err != nil {
+	// Locate the format and audio data chunks
+	format, err := parseWAV(file)
+	if err != nil {
 		file.Close()
-		return nil, fmt.Errorf("failed to read WAV header: %w", err)
+		return nil, err
 	}
+	// Every encoding is streamed as 16-bit linear PCM
+	bytesPerSample := 2
 
-	// Verify WAV format
-	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
-		file.Close()
-		return nil, fmt.Errorf("invalid WAV file format")
+	return &WAVStreamer{
+		file:                file,
+		chunkSize:           chunkSize,
+		chunkInterval:       chunkInterval,
+		realtimeFactor:      1,
+		pacer:               &pacer{},
+		dataOffset:          format.dataOffset,
+		dataSize:            format.dataSize,
+		formatTag:           format.formatTag,
+		sourceBitsPerSample: format.bitsPerSample,
+		sourceSampleRate:    format.sampleRate,
+		sourceChannels:      format.channels,
+		sampleRate:          format.sampleRate,
+		bytesPerSample:      bytesPerSample,
+		channels:            format.channels,
+		clock:               &sendClock{bytesPerSecond: int64(format.sampleRate * format.channels * bytesPerSample)},
+	}, nil
+}
+
+// SetTransform resamples and remixes the streamed audio; the zero Transform streams the file as is
+func (w *WAVStreamer) SetTransform(t Transform) error {
+	target, err := t.output(transformFormat{sampleRate: w.sourceSampleRate, channels: w.sourceChannels})
+	if err != nil {
+		return err
 	}
 
-	// Get audio format details
-	channels := int(binary.LittleEndian.Uint16(header[22:24]))
-	sampleRate := int(binary.LittleEndian.Uint32(header[24:28]))
-	bitsPerSample := int(binary.LittleEndian.Uint16(header[34:36]))
-	bytesPerSample := bitsPerSample / 8
+	w.transform = t
+	w.sampleRate = target.sampleRate
+	w.channels = target.channels
+	w.clock = &sendClock{bytesPerSecond: int64(w.sampleRate * w.channels * w.bytesPerSample)}
+	return nil
+}
 
-	return &WAVStreamer{
-		file:           file,
-		chunkSize:      chunkSize,
-		chunkInterval:  chunkInterval,
-		headerSize:     44,
-		sampleRate:     sampleRate,
-		bytesPerSample: bytesPerSample,
-		channels:       channels,
-	}, nil
+// SetChunkDuration sizes every chunk to hold d of the streamed audio, handing one out every d
+func (w *WAVStreamer) SetChunkDuration(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("chunk duration must be positive, got %s", d)
+	}
+	w.chunkDuration = d
+	return nil
+}
+
+// SetRealtimeFactor speeds up (factor > 1) or slows down (factor < 1) the pacing of
+// the chunks; 0 hands out chunks as fast as they are read
+func (w *WAVStreamer) SetRealtimeFactor(factor float64) error {
+	if factor < 0 || math.IsNaN(factor) || math.IsInf(factor, 0) {
+		return fmt.Errorf("realtime factor must be a positive number or 0, got %g", factor)
+	}
+	w.realtimeFactor = factor
+	return nil
+}
+
+// GetChunking returns the size of the streamed chunks and the interval between them
+func (w *WAVStreamer) GetChunking() (chunkSize int, chunkInterval time.Duration) {
+	chunkSize, chunkInterval = w.chunkSize, w.chunkInterval
+	if w.chunkDuration > 0 {
+		frameSize := w.channels * w.bytesPerSample
+		frames := max(int(int64(w.sampleRate)*int64(w.chunkDuration)/int64(time.Second)), 1)
+		chunkSize, chunkInterval = frames*frameSize, w.chunkDuration
+	}
+	if chunkInterval == 0 {
+		chunkInterval = w.clock.duration(int64(chunkSize))
+	}
+	if w.realtimeFactor == 0 {
+		return chunkSize, 0
+	}
+	return chunkSize, time.Duration(float64(chunkInterval) / w.realtimeFactor)
+}
+
+// pacing returns the pacer of a new stream
+func (w *WAVStreamer) pacing() *pacer {
+	if w.chunkDuration > 0 || w.chunkInterval > 0 {
+		_, chunkInterval := w.GetChunking()
+		return &pacer{interval: chunkInterval}
+	}
+	return &pacer{rate: float64(w.clock.bytesPerSecond) * w.realtimeFactor}
 }
 
 // Stream streams the WAV file in chunks
 func (w *WAVStreamer) Stream() (io.Reader, error) {
-	// Seek to the start of audio data
-	if _, err := w.file.Seek(int64(w.headerSize), io.SeekStart); err != nil {
-		return nil, fmt.Errorf("failed to seek to audio data: %w", err)
+	// Read only the audio data, whatever chunks surround it
+	data, err := newPCM16Reader(io.NewSectionReader(w.file, w.dataOffset, w.dataSize), w.formatTag, w.sourceBitsPerSample)
+	if err != nil {
+		return nil, err
+	}
+	source := transformFormat{sampleRate: w.sourceSampleRate, channels: w.sourceChannels}
+	transformed, err := newTransformReader(data, source, w.transform)
+	if err != nil {
+		return nil, err
+	}
+
+	// Converted audio is uploaded as a new WAV file rather than the original one
+	var header []byte
+	if transformed != data {
+		header = WAVHeader(w.sampleRate, w.channels, w.bytesPerSample, w.streamedSize())
 	}
 
+	chunkSize, _ := w.GetChunking()
+	w.pacer = w.pacing()
+	w.clock.reset()
 	return &wavChunkReader{
-		file:          w.file,
-		chunkSize:     w.chunkSize,
-		chunkInterval: w.chunkInterval,
+		file:        w.file,
+		data:        transformed,
+		header:      header,
+		sampleBytes: w.sourceBitsPerSample / 8,
+		chunkSize:   chunkSize,
+		pacer:       w.pacer,
+		dataOffset:  w.dataOffset,
+		dataSize:    w.dataSize,
+		clock:       w.clock,
 	}, nil
 }
 
+// streamedSize returns the size of the streamed audio in bytes, once converted
+func (w *WAVStreamer) streamedSize() int64 {
+	frames := w.dataSize / int64(w.sourceChannels*w.sourceBitsPerSample/8)
+	if w.sampleRate != w.sourceSampleRate {
+		frames = outputFrames(frames, w.sourceSampleRate, w.sampleRate)
+	}
+	return frames * int64(w.channels*w.bytesPerSample)
+}
+
+// StreamDuration returns how long streaming the audio takes as paced, 0 when unpaced
+func (w *WAVStreamer) StreamDuration() time.Duration {
+	p := w.pacing()
+	if p.interval <= 0 {
+		if p.rate <= 0 {
+			return 0
+		}
+		return time.Duration(float64(w.streamedSize()) / p.rate * float64(time.Second))
+	}
+	chunkSize, _ := w.GetChunking()
+	chunks := (w.streamedSize() + int64(chunkSize) - 1) / int64(chunkSize)
+	return time.Duration(chunks) * p.interval
+}
+
 // Close closes the WAV file
 func (w *WAVStreamer) Close() error {
 	return w.file.Close()
 }
 
-// GetAudioFormat returns the audio format details
+// Encoding returns the encoding of the streamed audio
+func (w *WAVStreamer) Encoding() string {
+	return EncodingLinear16
+}
+
+// SourceFormat describes the encoding of the audio in the file
+func (w *WAVStreamer) SourceFormat() string {
+	return fmt.Sprintf("%d-bit %s", w.sourceBitsPerSample, formatName(w.formatTag))
+}
+
+// GetAudioFormat returns the format of the streamed audio
 func (w *WAVStreamer) GetAudioFormat() (sampleRate, channels, bytesPerSample int) {
 	return w.sampleRate, w.channels, w.bytesPerSample
 }
 
+// SentDuration returns the duration of the audio read from the stream so far
+func (w *WAVStreamer) SentDuration() time.Duration {
+	return w.clock.SentDuration()
+}
+
+// Sends returns when each chunk of the stream was handed out
+func (w *WAVStreamer) Sends() []Send {
+	return w.clock.Sends()
+}
+
+// PacingErrors returns how late each chunk was handed out compared to its schedule
+func (w *WAVStreamer) PacingErrors() []time.Duration {
+	return w.pacer.Errors()
+}
+
 // wavChunkReader implements io.Reader to stream WAV data in chunks
 type wavChunkReader struct {
-	file          *os.File
-	chunkSize     int
-	chunkInterval time.Duration
+	file        *os.File
+	data        io.Reader // audio data decoded to 16-bit PCM
+	header      []byte    // WAV header of the converted audio, nil when the file is not converted
+	sampleBytes int       // size of a sample in the file
+	chunkSize   int
+	chunk       []byte
+	pending     []byte // part of the current chunk not returned yet
+	pacer       *pacer
+	dataOffset  int64
+	dataSize    int64
+	clock       *sendClock
 }
 
 func (r *wavChunkReader) Read(p []byte) (n int, err error) {
-	// Read a chunk of audio data, but don't exceed the buffer size
-	chunkSize := r.chunkSize
-	if chunkSize > len(p) {
-		chunkSize = len(p)
-	}
-	
-	n, err = r.file.Read(p[:chunkSize])
-	if err != nil && err != io.EOF {
-		return n, err
-	}
+	if len(r.pending) == 0 {
+		// Read a whole chunk so each one holds the audio of a chunk interval,
+		// whatever the size of the caller's buffer
+		if r.chunk == nil {
+			r.chunk = make([]byte, r.chunkSize)
+		}
+		n, err := io.ReadFull(r.data, r.chunk)
+		if n == 0 {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return 0, err
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
 
-	// If we read any data, wait for the chunk interval
-	if n > 0 {
-		time.Sleep(r.chunkInterval)
+		// Hand the chunk out once it is due
+		r.pacer.wait(n)
+		r.clock.record(n)
+		r.pending = r.chunk[:n]
 	}
 
-	return n, err
+	n = copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
 }
 
 // GetFile returns the underlying file reader for direct access
 func (r *wavChunkReader) GetFile() io.Reader {
-	// Reset file to beginning and return it
-	r.file.Seek(0, 0)
-	return r.file
+	r.clock.reset()
+	if r.header != nil {
+		return io.MultiReader(bytes.NewReader(r.header), &clockedReader{reader: r.data, clock: r.clock, sampleBytes: 2})
+	}
+
+	// Return the whole file, counting only the audio data as sent
+	dataEnd := r.dataOffset + r.dataSize
+	return io.MultiReader(
+		io.NewSectionReader(r.file, 0, r.dataOffset),
+		&clockedReader{reader: io.NewSectionReader(r.file, r.dataOffset, r.dataSize), clock: r.clock, sampleBytes: r.sampleBytes},
+		io.NewSectionReader(r.file, dataEnd, math.MaxInt64-dataEnd),
+	)
+}
+
+// clockedReader records every read of encoded audio on the send clock
+type clockedReader struct {
+	reader      io.Reader
+	clock       *sendClock
+	sampleBytes int
+	read        int64 // encoded bytes read
+	recorded    int64 // 16-bit PCM equivalent recorded on the clock
+}
+
+func (r *clockedReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.read += int64(n)
+	converted := r.read / int64(r.sampleBytes) * 2
+	r.clock.record(int(converted - r.recorded))
+	r.recorded = converted
+	return n, err
+}
+
+// WAVHeader builds the header of a PCM WAV file holding dataSize bytes of audio
+func WAVHeader(sampleRate, channels, bytesPerSample int, dataSize int64) []byte {
+	blockAlign := channels * bytesPerSample
+	header := make([]byte, 0, 44)
+	header = append(header, "RIFF"...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(36+dataSize))
+	header = append(header, "WAVEfmt "...)
+	header = binary.LittleEndian.AppendUint32(header, 16)
+	header = binary.LittleEndian.AppendUint16(header, formatPCM)
+	header = binary.LittleEndian.AppendUint16(header, uint16(channels))
+	header = binary.LittleEndian.AppendUint32(header, uint32(sampleRate))
+	header = binary.LittleEndian.AppendUint32(header, uint32(sampleRate*blockAlign))
+	header = binary.LittleEndian.AppendUint16(header, uint16(blockAlign))
+	header = binary.LittleEndian.AppendUint16(header, uint16(bytesPerSample*8))
+	header = append(header, "data"...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(dataSize))
+	return header
 }
@@ -0,0 +1,204 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/audio"
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/metrics"
+	"github.com/elishowk/speech_latency/pkg/providers"
+	"github.com/elishowk/speech_latency/pkg/session"
+)
+
+// DefaultTimeout bounds the time a run takes past the streaming of its audio
+const DefaultTimeout = 30 * time.Second
+
+// Options describes a single benchmark run
+type Options struct {
+	Provider       string
+	AudioPath      string
+	ChunkSize      int
+	ChunkInterval  time.Duration    // fixed time between chunks, 0 paces each chunk by its audio
+	ChunkDuration  time.Duration    // audio per chunk, overrides ChunkSize and ChunkInterval when set
+	RealtimeFactor float64          // pacing speed relative to real time, defaults to 1
+	Unpaced        bool             // stream chunks as fast as the provider reads them
+	Transform      audio.Transform  // conversion applied to the audio before streaming
+	Config         providers.Config // audio format fields are filled from the WAV file
+	APIKey         string
+	Timeout        time.Duration   // defaults to the time the audio takes to stream plus DefaultTimeout
+	Record         *session.Writer // records every run when set
+}
+
+// Run is the outcome of a single benchmark run
+type Run struct {
+	SampleRate    int
+	Channels      int
+	ChunkSize     int           // bytes per chunk as streamed
+	ChunkInterval time.Duration // time between chunks as paced, 0 when unpaced
+	Started       time.Time
+	Events        []events.Event
+	Summary       *metrics.Summary
+	Words         *metrics.WordSummary
+	Pacing        metrics.Distribution // how late chunks were handed out, in milliseconds
+}
+
+// RunOnce streams the audio file through a fresh provider and computes its metrics
+func RunOnce(ctx context.Context, factory *providers.Factory, opts Options) (*Run, error) {
+	streamer, err := audio.NewWAVStreamer(opts.AudioPath, opts.ChunkSize, opts.ChunkInterval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create WAV streamer: %w", err)
+	}
+	defer streamer.Close()
+	if err := streamer.SetTransform(opts.Transform); err != nil {
+		return nil, fmt.Errorf("failed to convert audio: %w", err)
+	}
+	factor, err := configurePacing(streamer, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	sampleRate, channels, _ := streamer.GetAudioFormat()
+	providerConfig := opts.Config
+	providerConfig.SampleRate = sampleRate
+	providerConfig.Channels = channels
+	providerConfig.Encoding = streamer.Encoding()
+	providerConfig.RealtimeFactor = factor
+
+	provider, err := factory.CreateProvider(opts.Provider, &providerConfig, opts.APIKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create provider: %w", err)
+	}
+
+	// Paced streams of long audio or at low factors take longer than any fixed bound
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = streamer.StreamDuration() + DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	audioStream, err := streamer.Stream()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audio stream: %w", err)
+	}
+
+	eventLog := events.NewLog(streamer.SentDuration)
+	chunkSize, chunkInterval := streamer.GetChunking()
+	run := &Run{
+		SampleRate:    sampleRate,
+		Channels:      channels,
+		ChunkSize:     chunkSize,
+		ChunkInterval: chunkInterval,
+		Started:       eventLog.Start(),
+	}
+	streamErr := provider.StreamAudio(ctx, audioStream, eventLog)
+	run.Events = eventLog.Events()
+	run.Pacing = pacingErrors(streamer.PacingErrors())
+	if opts.Record != nil {
+		if err := record(opts, providerConfig, run, eventLog, streamer.Sends(), streamErr); err != nil {
+			return run, err
+		}
+	}
+	if streamErr != nil {
+		return run, fmt.Errorf("failed to stream audio: %w", streamErr)
+	}
+
+	// Compute metrics from the event log
+	run.Summary, err = metrics.Compute(run.Started, run.Events)
+	if err != nil {
+		return run, err
+	}
+	run.Words = metrics.ComputeWordLatencies(run.Events, streamer.Sends())
+	return run, nil
+}
+
+// record writes the requests, the audio sends, the raw messages and the events of a run,
+// failed or not
+func record(opts Options, config providers.Config, run *Run, eventLog *events.Log, sends []audio.Send, streamErr error) error {
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to record provider configuration: %w", err)
+	}
+	return opts.Record.Write(&session.Session{
+		Header: session.Header{
+			Started:        run.Started,
+			Provider:       opts.Provider,
+			AudioPath:      opts.AudioPath,
+			SampleRate:     run.SampleRate,
+			Channels:       run.Channels,
+			Encoding:       config.Encoding,
+			ChunkSize:      run.ChunkSize,
+			ChunkInterval:  metrics.Milliseconds(run.ChunkInterval),
+			RealtimeFactor: config.RealtimeFactor,
+			Config:         configJSON,
+		},
+		Requests: eventLog.Requests(),
+		Sends:    sends,
+		Messages: eventLog.Messages(),
+		Events:   run.Events,
+		Err:      streamErr,
+	})
+}
+
+// configurePacing applies the chunk duration and realtime factor of the options and
+// returns the realtime factor, 0 when unpaced
+func configurePacing(streamer *audio.WAVStreamer, opts Options) (float64, error) {
+	if opts.ChunkDuration != 0 {
+		if err := streamer.SetChunkDuration(opts.ChunkDuration); err != nil {
+			return 0, err
+		}
+	}
+
+	factor := opts.RealtimeFactor
+	switch {
+	case opts.Unpaced:
+		factor = 0
+	case factor == 0:
+		factor = 1
+	}
+	return factor, streamer.SetRealtimeFactor(factor)
+}
+
+// pacingErrors describes pacing errors in milliseconds
+func pacingErrors(errors []time.Duration) metrics.Distribution {
+	values := make([]float64, len(errors))
+	for i, e := range errors {
+		values[i] = metrics.Milliseconds(e)
+	}
+	return metrics.Describe(values)
+}
+
+// Series summarizes the measured runs of a repeated benchmark
+type Series struct {
+	Runs              int
+	Failures          int
+	FirstWordLatency  metrics.Distribution // in milliseconds
+	FirstFinalLatency metrics.Distribution // in milliseconds
+	FinalLatency      metrics.Distribution // in milliseconds
+	Throughput        metrics.Distribution // in words per second
+}
+
+// Summarize computes the statistics of successful runs; failed runs are nil
+func Summarize(runs []*Run) Series {
+	series := Series{Runs: len(runs)}
+	var firstWord, firstFinal, final, throughput []float64
+	for _, run := range runs {
+		if run == nil || run.Summary == nil {
+			series.Failures++
+			continue
+		}
+		firstWord = append(firstWord, metrics.Milliseconds(run.Summary.FirstWordLatency))
+		firstFinal = append(firstFinal, metrics.Milliseconds(run.Summary.FirstFinalLatency))
+		final = append(final, metrics.Milliseconds(run.Summary.FinalLatency))
+		throughput = append(throughput, run.Summary.Throughput)
+	}
+
+	series.FirstWordLatency = metrics.Describe(firstWord)
+	series.FirstFinalLatency = metrics.Describe(firstFinal)
+	series.FinalLatency = metrics.Describe(final)
+	series.Throughput = metrics.Describe(throughput)
+	return series
+}
@@ -0,0 +1,229 @@
+package bench
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers"
+	"github.com/elishowk/speech_latency/pkg/session"
+)
+
+// writeWAV writes a 16-bit mono PCM WAV file of silence
+func writeWAV(t *testing.T, sampleRate int, duration time.Duration) string {
+	t.Helper()
+	dataSize := int(duration.Seconds()*float64(sampleRate)) * 2
+	header := make([]byte, 44)
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(36+dataSize))
+	copy(header[8:16], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(header[16:20], 16)
+	binary.LittleEndian.PutUint16(header[20:22], 1)
+	binary.LittleEndian.PutUint16(header[22:24], 1)
+	binary.LittleEndian.PutUint32(header[24:28], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(header[28:32], uint32(sampleRate*2))
+	binary.LittleEndian.PutUint16(header[32:34], 2)
+	binary.LittleEndian.PutUint16(header[34:36], 16)
+	copy(header[36:40], "data")
+	binary.LittleEndian.PutUint32(header[40:44], uint32(dataSize))
+
+	path := filepath.Join(t.TempDir(), "audio.wav")
+	if err := os.WriteFile(path, append(header, make([]byte, dataSize)...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// echoProvider emits a final word for every chunk it reads
+type echoProvider struct {
+	config *providers.Config
+}
+
+func (p *echoProvider) StreamAudio(ctx context.Context, audioReader io.Reader, sink events.Sink) error {
+	sink.Emit(events.Event{Type: events.Opened})
+	defer sink.Emit(events.Event{Type: events.Closed})
+
+	buf := make([]byte, 1024)
+	var sent int
+	for {
+		n, err := audioReader.Read(buf)
+		sent += n
+		if n > 0 {
+			end := float64(sent) / float64(p.config.SampleRate*2)
+			sink.Emit(events.Event{Type: events.Final, Transcript: "word", Words: []events.Word{{Word: "word", End: end}}})
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestRunOnce(t *testing.T) {
+	path := writeWAV(t, 8000, 200*time.Millisecond)
+	factory := providers.NewFactory()
+	factory.RegisterProvider("echo", func(config *providers.Config, apiKey string) (providers.Provider, error) {
+		return &echoProvider{config: config}, nil
+	})
+
+	run, err := RunOnce(context.Background(), factory, Options{
+		Provider:      "echo",
+		AudioPath:     path,
+		ChunkSize:     800,
+		ChunkInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+
+	if run.SampleRate != 8000 || run.Channels != 1 {
+		t.Errorf("unexpected audio format %d Hz, %d channels", run.SampleRate, run.Channels)
+	}
+	if run.ChunkSize != 800 || run.ChunkInterval != 10*time.Millisecond || run.Pacing.Count != 4 {
+		t.Errorf("expected 4 paced chunks of 800 bytes every 10ms, got %d of %d bytes every %s", run.Pacing.Count, run.ChunkSize, run.ChunkInterval)
+	}
+	if run.Summary.WordCount != 4 {
+		t.Errorf("expected 4 words, got %d", run.Summary.WordCount)
+	}
+	if run.Words.Finalized.Count != 4 {
+		t.Errorf("expected 4 word latencies, got %d", run.Words.Finalized.Count)
+	}
+	if last := run.Events[len(run.Events)-2]; last.AudioOffset != 200*time.Millisecond {
+		t.Errorf("expected last result at 200ms of audio, got %s", last.AudioOffset)
+	}
+}
+
+func TestRunOnce_ChunkDuration(t *testing.T) {
+	path := writeWAV(t, 8000, 200*time.Millisecond)
+	factory := providers.NewFactory()
+	factory.RegisterProvider("echo", func(config *providers.Config, apiKey string) (providers.Provider, error) {
+		return &echoProvider{config: config}, nil
+	})
+
+	run, err := RunOnce(context.Background(), factory, Options{
+		Provider:      "echo",
+		AudioPath:     path,
+		ChunkSize:     4096,
+		ChunkDuration: 50 * time.Millisecond,
+		Unpaced:       true,
+	})
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if run.ChunkSize != 800 || run.ChunkInterval != 0 || run.Pacing.Count != 0 {
+		t.Errorf("expected unpaced chunks of 800 bytes, got %d bytes every %s with %d paced", run.ChunkSize, run.ChunkInterval, run.Pacing.Count)
+	}
+	if run.Summary.WordCount != 4 {
+		t.Errorf("expected 4 words, got %d", run.Summary.WordCount)
+	}
+}
+
+func TestRunOnce_RecordReplay(t *testing.T) {
+	path := writeWAV(t, 8000, 200*time.Millisecond)
+	factory := providers.NewFactory()
+	factory.RegisterProvider("echo", func(config *providers.Config, apiKey string) (providers.Provider, error) {
+		return &echoProvider{config: config}, nil
+	})
+
+	sessionPath := filepath.Join(t.TempDir(), "session.jsonl")
+	sessionFile, err := os.Create(sessionPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sessionFile.Close()
+	record := session.NewWriter(sessionFile)
+	opts := Options{
+		Provider:      "echo",
+		AudioPath:     path,
+		ChunkSize:     800,
+		ChunkInterval: 10 * time.Millisecond,
+		Record:        record,
+	}
+	recorded, err := RunOnce(context.Background(), factory, opts)
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+
+	sessions, err := session.Load(sessionPath)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].Provider != "echo" || len(sessions[0].Sends) != 4 || len(sessions[0].Events) != len(recorded.Events) {
+		t.Fatalf("unexpected session %+v", sessions)
+	}
+
+	// The replay emits the recorded events in order; their timing is left to the replay tests
+	opts.Provider, opts.Record = "replay", nil
+	opts.Config.Session = sessionPath
+	replayed, err := RunOnce(context.Background(), factory, opts)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if len(replayed.Events) != len(recorded.Events) {
+		t.Fatalf("replayed %d events, recorded %d", len(replayed.Events), len(recorded.Events))
+	}
+	for i, e := range replayed.Events {
+		want := recorded.Events[i]
+		if e.Type != want.Type || e.Transcript != want.Transcript || !reflect.DeepEqual(e.Words, want.Words) {
+			t.Errorf("event %d: replayed %+v, recorded %+v", i, e, want)
+		}
+	}
+	if replayed.Summary.Transcript != recorded.Summary.Transcript {
+		t.Errorf("replayed %q, recorded %q", replayed.Summary.Transcript, recorded.Summary.Transcript)
+	}
+
+	// A run streamed unpaced has no timing to replay paced
+	opts.Provider, opts.Unpaced = "echo", true
+	opts.Record = record
+	if _, err := RunOnce(context.Background(), factory, opts); err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	opts.Provider, opts.Unpaced, opts.Record = "replay", false, nil
+	opts.Config.SessionRun = 2
+	if _, err := RunOnce(context.Background(), factory, opts); err == nil || !strings.Contains(err.Error(), "streamed unpaced") {
+		t.Errorf("expected an error replaying an unpaced run paced, got %v", err)
+	}
+}
+
+func TestRunOnce_UnknownProvider(t *testing.T) {
+	path := writeWAV(t, 8000, 10*time.Millisecond)
+	_, err := RunOnce(context.Background(), providers.NewFactory(), Options{Provider: "nope", AudioPath: path, ChunkSize: 800})
+	if err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	factory := providers.NewFactory()
+	factory.RegisterProvider("echo", func(config *providers.Config, apiKey string) (providers.Provider, error) {
+		return &echoProvider{config: config}, nil
+	})
+	path := writeWAV(t, 8000, 100*time.Millisecond)
+
+	var runs []*Run
+	for i := 0; i < 3; i++ {
+		run, err := RunOnce(context.Background(), factory, Options{Provider: "echo", AudioPath: path, ChunkSize: 800})
+		if err != nil {
+			t.Fatalf("RunOnce failed: %v", err)
+		}
+		runs = append(runs, run)
+	}
+	runs = append(runs, nil)
+
+	series := Summarize(runs)
+	if series.Runs != 4 || series.Failures != 1 {
+		t.Errorf("expected 4 runs with 1 failure, got %d with %d", series.Runs, series.Failures)
+	}
+	if series.FirstWordLatency.Count != 3 || series.Throughput.Min <= 0 {
+		t.Errorf("unexpected statistics %+v", series)
+	}
+}
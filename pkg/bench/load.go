@@ -0,0 +1,229 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/metrics"
+	"github.com/elishowk/speech_latency/pkg/providers"
+)
+
+// LoadOptions describes a load test of many simultaneous streams
+type LoadOptions struct {
+	Run         Options       // options of every stream
+	Concurrency int           // maximum number of simultaneous streams
+	RampUp      time.Duration // time over which streams start, spreading the initial load
+	Duration    time.Duration // keep starting streams until then, 0 runs Concurrency streams once
+	ArrivalRate float64       // new streams per second, 0 keeps Concurrency streams busy
+}
+
+// StreamResult is the outcome of one stream of a load test
+type StreamResult struct {
+	Stream      int           // 1-based index in start order
+	Offset      time.Duration // start time relative to the load test start
+	Concurrency int           // streams in flight when this one started, itself included
+	Run         *Run          // nil or partial when Err is set
+	Err         error
+}
+
+// LoadResult summarizes a load test
+type LoadResult struct {
+	Streams         []StreamResult // in start order
+	Skipped         int            // arrivals that found all streams busy
+	Elapsed         time.Duration
+	PeakConcurrency int
+	Series          Series               // latencies of successful streams
+	WordFirstSeen   metrics.Distribution // word latencies of all streams, in milliseconds
+	WordFinalized   metrics.Distribution // word latencies of all streams, in milliseconds
+	Errors          map[string]int       // failed streams by error message
+}
+
+// ErrorRate returns the fraction of streams that failed
+func (r *LoadResult) ErrorRate() float64 {
+	if len(r.Streams) == 0 {
+		return 0
+	}
+	return float64(r.Series.Failures) / float64(len(r.Streams))
+}
+
+// Validate checks the load test options
+func (o LoadOptions) Validate() error {
+	if o.Concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1, got %d", o.Concurrency)
+	}
+	if o.RampUp < 0 || o.Duration < 0 {
+		return fmt.Errorf("ramp-up and duration must not be negative")
+	}
+	if o.ArrivalRate < 0 || math.IsNaN(o.ArrivalRate) || math.IsInf(o.ArrivalRate, 0) {
+		return fmt.Errorf("arrival rate must be a positive number or 0, got %g", o.ArrivalRate)
+	}
+	if o.ArrivalRate > 0 && o.Duration == 0 {
+		return fmt.Errorf("an arrival rate requires a duration")
+	}
+	return nil
+}
+
+// arrivalTime returns when the k-th (0-based) stream arrives: the rate grows
+// linearly from 0 to ArrivalRate over the ramp-up, then stays constant
+func (o LoadOptions) arrivalTime(k int) time.Duration {
+	rate := o.ArrivalRate
+	rampUp := o.RampUp.Seconds()
+	var seconds float64
+	if float64(k) <= rate*rampUp/2 {
+		seconds = math.Sqrt(2 * float64(k) * rampUp / rate)
+	} else {
+		seconds = float64(k)/rate + rampUp/2
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// loadTest tracks the streams of a running load test
+type loadTest struct {
+	factory  *providers.Factory
+	opts     LoadOptions
+	start    time.Time
+	onResult func(StreamResult)
+
+	mu       sync.Mutex
+	started  int
+	inFlight int
+	peak     int
+	results  []StreamResult
+}
+
+// RunLoad runs streams concurrently, each with its own WAV streamer and provider,
+// calling onResult (when not nil) as each stream completes, one call at a time
+func RunLoad(ctx context.Context, factory *providers.Factory, opts LoadOptions, onResult func(StreamResult)) (*LoadResult, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	t := &loadTest{factory: factory, opts: opts, start: time.Now(), onResult: onResult}
+	skipped := 0
+	if opts.ArrivalRate > 0 {
+		skipped = t.openLoop(ctx)
+	} else {
+		t.closedLoop(ctx)
+	}
+
+	result := &LoadResult{
+		Streams:         t.results,
+		Skipped:         skipped,
+		Elapsed:         time.Since(t.start),
+		PeakConcurrency: t.peak,
+		Errors:          make(map[string]int),
+	}
+	sort.Slice(result.Streams, func(i, j int) bool { return result.Streams[i].Stream < result.Streams[j].Stream })
+
+	runs := make([]*Run, len(result.Streams))
+	var firstSeen, finalized []float64
+	for i, s := range result.Streams {
+		if s.Err != nil {
+			result.Errors[s.Err.Error()]++
+			continue
+		}
+		runs[i] = s.Run
+		for _, w := range s.Run.Words.Words {
+			firstSeen = append(firstSeen, metrics.Milliseconds(w.FirstSeen))
+			finalized = append(finalized, metrics.Milliseconds(w.Finalized))
+		}
+	}
+	result.Series = Summarize(runs)
+	result.WordFirstSeen = metrics.Describe(firstSeen)
+	result.WordFinalized = metrics.Describe(finalized)
+	return result, nil
+}
+
+// closedLoop runs Concurrency workers, each starting a new stream as soon as
+// its previous one completes, until the duration has elapsed
+func (t *loadTest) closedLoop(ctx context.Context) {
+	var wg sync.WaitGroup
+	for worker := 0; worker < t.opts.Concurrency; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+
+			// Workers start evenly spread over the ramp-up
+			delay := t.opts.RampUp * time.Duration(worker) / time.Duration(t.opts.Concurrency)
+			if !sleepContext(ctx, delay) {
+				return
+			}
+			for {
+				t.stream(ctx)
+				if t.opts.Duration == 0 || time.Since(t.start) >= t.opts.Duration || ctx.Err() != nil {
+					return
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+}
+
+// openLoop starts streams at the arrival rate regardless of how long they take,
+// skipping arrivals while Concurrency streams are in flight; it returns the
+// number of skipped arrivals
+func (t *loadTest) openLoop(ctx context.Context) int {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, t.opts.Concurrency)
+	skipped := 0
+	for k := 0; ; k++ {
+		at := t.opts.arrivalTime(k)
+		if at >= t.opts.Duration || !sleepContext(ctx, time.Until(t.start.Add(at))) {
+			break
+		}
+
+		select {
+		case slots <- struct{}{}:
+		default:
+			skipped++
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			t.stream(ctx)
+		}()
+	}
+	wg.Wait()
+	return skipped
+}
+
+// stream runs one stream and records its result
+func (t *loadTest) stream(ctx context.Context) {
+	t.mu.Lock()
+	t.started++
+	t.inFlight++
+	t.peak = max(t.peak, t.inFlight)
+	result := StreamResult{Stream: t.started, Offset: time.Since(t.start), Concurrency: t.inFlight}
+	t.mu.Unlock()
+
+	result.Run, result.Err = RunOnce(ctx, t.factory, t.opts.Run)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inFlight--
+	t.results = append(t.results, result)
+	if t.onResult != nil {
+		t.onResult(result)
+	}
+}
+
+// sleepContext sleeps for d, returning false if the context is done first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,134 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers"
+)
+
+// failingProvider fails every other stream
+type failingProvider struct {
+	calls *atomic.Int32
+	echo  *echoProvider
+}
+
+func (p *failingProvider) StreamAudio(ctx context.Context, audioReader io.Reader, sink events.Sink) error {
+	if p.calls.Add(1)%2 == 0 {
+		return errors.New("rate limited")
+	}
+	return p.echo.StreamAudio(ctx, audioReader, sink)
+}
+
+func loadFactory() *providers.Factory {
+	calls := &atomic.Int32{}
+	factory := providers.NewFactory()
+	factory.RegisterProvider("echo", func(config *providers.Config, apiKey string) (providers.Provider, error) {
+		return &echoProvider{config: config}, nil
+	})
+	factory.RegisterProvider("flaky", func(config *providers.Config, apiKey string) (providers.Provider, error) {
+		return &failingProvider{calls: calls, echo: &echoProvider{config: config}}, nil
+	})
+	return factory
+}
+
+func TestRunLoad_ClosedLoop(t *testing.T) {
+	path := writeWAV(t, 8000, 100*time.Millisecond)
+	opts := LoadOptions{
+		Run:         Options{Provider: "echo", AudioPath: path, ChunkSize: 800, ChunkInterval: 20 * time.Millisecond},
+		Concurrency: 4,
+	}
+
+	var completed atomic.Int32
+	result, err := RunLoad(context.Background(), loadFactory(), opts, func(StreamResult) { completed.Add(1) })
+	if err != nil {
+		t.Fatalf("RunLoad failed: %v", err)
+	}
+
+	if len(result.Streams) != 4 || completed.Load() != 4 {
+		t.Fatalf("expected 4 streams reported, got %d (%d callbacks)", len(result.Streams), completed.Load())
+	}
+	if result.PeakConcurrency != 4 {
+		t.Errorf("expected 4 simultaneous streams, got %d", result.PeakConcurrency)
+	}
+	for i, s := range result.Streams {
+		if s.Stream != i+1 || s.Err != nil || s.Run.Summary.WordCount != 2 {
+			t.Errorf("unexpected stream %d: %+v", i+1, s)
+		}
+	}
+	if result.Series.FirstWordLatency.Count != 4 || result.WordFinalized.Count != 8 || result.ErrorRate() != 0 {
+		t.Errorf("unexpected aggregate %+v", result)
+	}
+}
+
+func TestRunLoad_Errors(t *testing.T) {
+	path := writeWAV(t, 8000, 20*time.Millisecond)
+	result, err := RunLoad(context.Background(), loadFactory(), LoadOptions{
+		Run:         Options{Provider: "flaky", AudioPath: path, ChunkSize: 800, Unpaced: true},
+		Concurrency: 1,
+		Duration:    50 * time.Millisecond,
+	}, nil)
+	if err != nil {
+		t.Fatalf("RunLoad failed: %v", err)
+	}
+
+	if len(result.Streams) < 2 {
+		t.Fatalf("expected streams to restart until the duration, got %d", len(result.Streams))
+	}
+	if rate := result.ErrorRate(); rate < 0.4 || rate > 0.6 {
+		t.Errorf("expected about half the streams to fail, got %.2f", rate)
+	}
+	if result.Errors["failed to stream audio: rate limited"] != result.Series.Failures {
+		t.Errorf("unexpected error counts %v for %d failures", result.Errors, result.Series.Failures)
+	}
+}
+
+func TestRunLoad_OpenLoop(t *testing.T) {
+	path := writeWAV(t, 8000, 100*time.Millisecond)
+	result, err := RunLoad(context.Background(), loadFactory(), LoadOptions{
+		Run:         Options{Provider: "echo", AudioPath: path, ChunkSize: 800, ChunkInterval: 50 * time.Millisecond},
+		Concurrency: 2,
+		Duration:    100 * time.Millisecond,
+		ArrivalRate: 40,
+	}, nil)
+	if err != nil {
+		t.Fatalf("RunLoad failed: %v", err)
+	}
+
+	// 4 arrivals in 100ms, each stream lasting 100ms, so only 2 fit at once
+	if got := len(result.Streams) + result.Skipped; got != 4 {
+		t.Errorf("expected 4 arrivals, got %d streams and %d skipped", len(result.Streams), result.Skipped)
+	}
+	if result.Skipped == 0 || result.PeakConcurrency != 2 {
+		t.Errorf("expected arrivals to be skipped at 2 streams, got %d skipped with peak %d", result.Skipped, result.PeakConcurrency)
+	}
+}
+
+func TestLoadOptions_ArrivalTime(t *testing.T) {
+	opts := LoadOptions{ArrivalRate: 10, RampUp: 2 * time.Second}
+	tests := []struct {
+		k    int
+		want time.Duration
+	}{
+		{0, 0},
+		{10, 2 * time.Second}, // the ramp-up admits half the steady rate
+		{20, 3 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := opts.arrivalTime(tt.k); (got - tt.want).Abs() > time.Millisecond {
+			t.Errorf("arrivalTime(%d) = %s, want %s", tt.k, got, tt.want)
+		}
+	}
+
+	if err := (LoadOptions{Concurrency: 1, ArrivalRate: 5}).Validate(); err == nil {
+		t.Error("expected error for an arrival rate without duration")
+	}
+	if err := (LoadOptions{}).Validate(); err == nil {
+		t.Error("expected error for zero concurrency")
+	}
+}
@@ -0,0 +1,172 @@
+package events
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Type identifies the kind of event emitted by a provider
+type Type string
+
+const (
+	// Opened is emitted once the provider connection is established
+	Opened Type = "opened"
+	// Interim carries a hypothesis that may still change
+	Interim Type = "interim"
+	// Final carries a transcript segment that will not change anymore
+	Final Type = "final"
+	// UtteranceEnd is emitted when the provider detects the end of an utterance
+	UtteranceEnd Type = "utterance_end"
+	// Error carries an error reported by the provider or the transport
+	Error Type = "error"
+	// Closed is emitted once the provider connection is closed
+	Closed Type = "closed"
+)
+
+// Word is a recognized word positioned on the audio timeline
+type Word struct {
+	Word       string
+	Start      float64 // in seconds from the beginning of the audio
+	End        float64 // in seconds from the beginning of the audio
+	Confidence float64
+}
+
+// Event is a single provider event
+type Event struct {
+	Type        Type
+	ReceivedAt  time.Time     // wall-clock time the event was received
+	AudioOffset time.Duration // audio sent to the provider when the event was received
+	Transcript  string
+	Words       []Word
+	Err         error
+}
+
+// Sink receives the events emitted by a provider
+type Sink interface {
+	Emit(Event)
+}
+
+// Request is a request opening a stream or asking for a transcript, as sent to the provider
+type Request struct {
+	SentAt time.Time
+	URL    string // endpoint of the provider, or command line of a local process
+	Header http.Header
+	Body   []byte // nil when the body is the audio
+}
+
+// Message is a raw message received from the provider
+type Message struct {
+	ReceivedAt time.Time
+	Data       []byte
+}
+
+// Wire is implemented by the sinks recording the raw exchange with the provider
+type Wire interface {
+	Request(Request)
+	Message(Message)
+}
+
+// RecordRequest passes a request to sink if it records the raw exchange
+func RecordRequest(sink Sink, r Request) {
+	if w, ok := sink.(Wire); ok {
+		w.Request(r)
+	}
+}
+
+// RecordMessage passes a received message to sink if it records the raw exchange
+func RecordMessage(sink Sink, data []byte) {
+	if w, ok := sink.(Wire); ok {
+		w.Message(Message{Data: data})
+	}
+}
+
+// EmitError reports err to sink as an error event and returns it
+func EmitError(sink Sink, err error) error {
+	sink.Emit(Event{Type: Error, Err: err})
+	return err
+}
+
+// Log is a Sink recording every event in arrival order, and the raw exchange with the provider
+type Log struct {
+	mu       sync.Mutex
+	start    time.Time
+	offset   func() time.Duration
+	entries  []Event
+	requests []Request
+	messages []Message
+}
+
+// NewLog creates an event log started now; offset reports the audio sent so far and may be nil
+func NewLog(offset func() time.Duration) *Log {
+	return &Log{
+		start:  time.Now(),
+		offset: offset,
+	}
+}
+
+// Emit records an event, stamping its receive time and audio offset when unset
+func (l *Log) Emit(e Event) {
+	if e.ReceivedAt.IsZero() {
+		e.ReceivedAt = time.Now()
+	}
+	if e.AudioOffset == 0 && l.offset != nil {
+		e.AudioOffset = l.offset()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, e)
+}
+
+// Request records a request, stamping its send time when unset
+func (l *Log) Request(r Request) {
+	if r.SentAt.IsZero() {
+		r.SentAt = time.Now()
+	}
+	r.Header = r.Header.Clone()
+	r.Body = append([]byte(nil), r.Body...)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.requests = append(l.requests, r)
+}
+
+// Message records a received message, stamping its receive time when unset; the
+// data is copied, so readers may reuse their buffer
+func (l *Log) Message(m Message) {
+	if m.ReceivedAt.IsZero() {
+		m.ReceivedAt = time.Now()
+	}
+	m.Data = append([]byte(nil), m.Data...)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, m)
+}
+
+// Start returns the time the log was started
+func (l *Log) Start() time.Time {
+	return l.start
+}
+
+// Events returns a copy of the recorded events
+func (l *Log) Events() []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Event(nil), l.entries...)
+}
+
+// Requests returns a copy of the recorded requests
+func (l *Log) Requests() []Request {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Request(nil), l.requests...)
+}
+
+// Messages returns a copy of the recorded messages
+func (l *Log) Messages() []Message {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Message(nil), l.messages...)
+}
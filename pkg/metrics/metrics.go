@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+)
+
+// Summary contains the latency metrics derived from an event log
+type Summary struct {
+	FirstWord         string
+	FirstWordFinal    bool          // whether the first word arrived in a final event
+	FirstWordLatency  time.Duration // from start to the first event carrying a word
+	FirstFinalLatency time.Duration // from start to the first final event carrying a word
+	FinalLatency      time.Duration // from start to the last final event
+	Throughput        float64       // final words per second
+	WordCount         int
+	Transcript        string
+}
+
+// Compute derives the latency metrics from the events recorded since start
+func Compute(start time.Time, log []events.Event) (*Summary, error) {
+	summary := &Summary{}
+	var segments []string
+	var lastFinal time.Time
+	var errs []string
+
+	for _, e := range log {
+		switch e.Type {
+		case events.Interim, events.Final:
+			if len(e.Words) > 0 && summary.FirstWord == "" {
+				summary.FirstWord = e.Words[0].Word
+				summary.FirstWordFinal = e.Type == events.Final
+				summary.FirstWordLatency = e.ReceivedAt.Sub(start)
+			}
+			if e.Type != events.Final {
+				continue
+			}
+			if len(e.Words) > 0 && summary.FirstFinalLatency == 0 {
+				summary.FirstFinalLatency = e.ReceivedAt.Sub(start)
+			}
+			if e.Transcript != "" {
+				segments = append(segments, e.Transcript)
+			}
+			summary.WordCount += len(e.Words)
+			lastFinal = e.ReceivedAt
+		case events.Error:
+			if e.Err != nil {
+				errs = append(errs, e.Err.Error())
+			}
+		}
+	}
+
+	if summary.FirstWord == "" {
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("no words received: %s", strings.Join(errs, "; "))
+		}
+		return nil, fmt.Errorf("no words received")
+	}
+
+	summary.Transcript = strings.Join(segments, " ")
+	if !lastFinal.IsZero() {
+		summary.FinalLatency = lastFinal.Sub(start)
+		summary.Throughput = float64(summary.WordCount) / summary.FinalLatency.Seconds()
+	}
+	return summary, nil
+}
+
+// Milliseconds converts a duration to fractional milliseconds for reporting
+func Milliseconds(d time.Duration) float64 {
+	return float64(d.Nanoseconds()) / 1e6
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+)
+
+func TestCompute(t *testing.T) {
+	start := time.Now()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	log := []events.Event{
+		{Type: events.Opened, ReceivedAt: at(10)},
+		{Type: events.Interim, ReceivedAt: at(100)},
+		{Type: events.Interim, ReceivedAt: at(200), Transcript: "hello", Words: []events.Word{{Word: "hello"}}},
+		{Type: events.Final, ReceivedAt: at(300), Transcript: "hello world", Words: []events.Word{{Word: "hello"}, {Word: "world"}}},
+		{Type: events.UtteranceEnd, ReceivedAt: at(350)},
+		{Type: events.Final, ReceivedAt: at(500), Transcript: "again", Words: []events.Word{{Word: "again"}}},
+		{Type: events.Closed, ReceivedAt: at(600)},
+	}
+
+	summary, err := Compute(start, log)
+	if err != nil {
+		t.Fatalf("Compute failed: %v", err)
+	}
+	if summary.FirstWord != "hello" || summary.FirstWordFinal {
+		t.Errorf("unexpected first word %q (final %t)", summary.FirstWord, summary.FirstWordFinal)
+	}
+	if summary.FirstWordLatency != 200*time.Millisecond {
+		t.Errorf("first word latency = %s, want 200ms", summary.FirstWordLatency)
+	}
+	if summary.FirstFinalLatency != 300*time.Millisecond {
+		t.Errorf("first final latency = %s, want 300ms", summary.FirstFinalLatency)
+	}
+	if summary.FinalLatency != 500*time.Millisecond {
+		t.Errorf("final latency = %s, want 500ms", summary.FinalLatency)
+	}
+	if summary.WordCount != 3 || summary.Throughput != 6 {
+		t.Errorf("expected 3 words at 6 words/second, got %d at %f", summary.WordCount, summary.Throughput)
+	}
+	if summary.Transcript != "hello world again" {
+		t.Errorf("unexpected transcript %q", summary.Transcript)
+	}
+}
+
+func TestCompute_NoWords(t *testing.T) {
+	log := []events.Event{
+		{Type: events.Opened, ReceivedAt: time.Now()},
+		{Type: events.Error, ReceivedAt: time.Now(), Err: errors.New("API error 401")},
+	}
+	if _, err := Compute(time.Now(), log); err == nil {
+		t.Error("expected error when no words were received")
+	}
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+)
+
+// Distribution summarizes a set of samples
+type Distribution struct {
+	Count  int
+	Min    float64
+	Max    float64
+	Mean   float64
+	StdDev float64 // sample standard deviation
+	P50    float64
+	P90    float64
+	P95    float64
+	P99    float64
+}
+
+// Describe computes the distribution of samples
+func Describe(samples []float64) Distribution {
+	if len(samples) == 0 {
+		return Distribution{}
+	}
+
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	mean := sum / float64(len(sorted))
+
+	var squares float64
+	for _, v := range sorted {
+		squares += (v - mean) * (v - mean)
+	}
+	var stdDev float64
+	if len(sorted) > 1 {
+		stdDev = math.Sqrt(squares / float64(len(sorted)-1))
+	}
+
+	return Distribution{
+		Count:  len(sorted),
+		Min:    sorted[0],
+		Max:    sorted[len(sorted)-1],
+		Mean:   mean,
+		StdDev: stdDev,
+		P50:    Percentile(sorted, 50),
+		P90:    Percentile(sorted, 90),
+		P95:    Percentile(sorted, 95),
+		P99:    Percentile(sorted, 99),
+	}
+}
+
+// Percentile returns the p-th percentile of sorted samples, interpolating between closest ranks
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (rank-float64(lower))*(sorted[upper]-sorted[lower])
+}
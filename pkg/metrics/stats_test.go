@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{10, 20, 30, 40, 50}
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{0, 10},
+		{50, 30},
+		{90, 46},
+		{100, 50},
+	}
+	for _, tt := range tests {
+		if got := Percentile(sorted, tt.p); got != tt.want {
+			t.Errorf("Percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	d := Describe([]float64{4, 2, 8, 6})
+	if d.Count != 4 || d.Min != 2 || d.Max != 8 || d.Mean != 5 || d.P50 != 5 {
+		t.Errorf("unexpected distribution %+v", d)
+	}
+	if math.Abs(d.StdDev-2.581988897) > 1e-6 {
+		t.Errorf("StdDev = %v, want 2.582", d.StdDev)
+	}
+	if empty := Describe(nil); empty.Count != 0 {
+		t.Errorf("expected empty distribution, got %+v", empty)
+	}
+}
@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/audio"
+	"github.com/elishowk/speech_latency/pkg/events"
+)
+
+// wordStartTolerance is how far apart, in seconds, two hypotheses of the same word may start
+const wordStartTolerance = 0.25
+
+// WordLatency is the latency of a single final word measured against the audio timeline
+type WordLatency struct {
+	Word      string
+	Start     float64       // in seconds from the beginning of the audio
+	End       float64       // in seconds from the beginning of the audio
+	SentAt    time.Time     // when the audio up to End was sent
+	FirstSeen time.Duration // from SentAt to the first event carrying the word
+	Finalized time.Duration // from SentAt to the final event carrying the word
+}
+
+// WordSummary aggregates word latencies in milliseconds
+type WordSummary struct {
+	Words     []WordLatency
+	FirstSeen Distribution
+	Finalized Distribution
+}
+
+// ComputeWordLatencies matches each final word with its first hypothesis and with the time its audio was sent
+func ComputeWordLatencies(log []events.Event, sends []audio.Send) *WordSummary {
+	summary := &WordSummary{}
+	var firstSeen, finalized []float64
+
+	for i, e := range log {
+		if e.Type != events.Final {
+			continue
+		}
+		for j, w := range e.Words {
+			end := time.Duration(w.End * float64(time.Second))
+			sentAt, ok := audio.SentAt(sends, end)
+			if !ok {
+				continue
+			}
+
+			seenAt := e.ReceivedAt
+			if earlier, found := firstHypothesis(log[:i], w, j); found {
+				seenAt = earlier
+			}
+
+			latency := WordLatency{
+				Word:      w.Word,
+				Start:     w.Start,
+				End:       w.End,
+				SentAt:    sentAt,
+				FirstSeen: seenAt.Sub(sentAt),
+				Finalized: e.ReceivedAt.Sub(sentAt),
+			}
+			summary.Words = append(summary.Words, latency)
+			firstSeen = append(firstSeen, Milliseconds(latency.FirstSeen))
+			finalized = append(finalized, Milliseconds(latency.Finalized))
+		}
+	}
+
+	summary.FirstSeen = Describe(firstSeen)
+	summary.Finalized = Describe(finalized)
+	return summary
+}
+
+// firstHypothesis returns when the word, at index in its final event, first appeared
+// in an interim event. Untimed interim words, which some providers send without
+// offsets, match by text and position in the interim events since the previous final
+func firstHypothesis(log []events.Event, word events.Word, index int) (time.Time, bool) {
+	since := 0
+	for k := len(log) - 1; k >= 0; k-- {
+		if log[k].Type == events.Final {
+			since = k + 1
+			break
+		}
+	}
+
+	for k, e := range log {
+		if e.Type != events.Interim {
+			continue
+		}
+		for pos, w := range e.Words {
+			if untimed(w) {
+				if k >= since && pos == index && sameText(w, word) {
+					return e.ReceivedAt, true
+				}
+				continue
+			}
+			if sameWord(w, word) {
+				return e.ReceivedAt, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// sameWord reports whether two hypotheses refer to the same spoken word
+func sameWord(a, b events.Word) bool {
+	return sameText(a, b) && math.Abs(a.Start-b.Start) <= wordStartTolerance
+}
+
+// sameText reports whether two hypotheses have the same text, ignoring case and punctuation
+func sameText(a, b events.Word) bool {
+	return strings.EqualFold(strings.Trim(a.Word, ".,!?;:"), strings.Trim(b.Word, ".,!?;:"))
+}
+
+// untimed reports whether a word has no position on the audio timeline
+func untimed(w events.Word) bool {
+	return w.Start == 0 && w.End == 0
+}
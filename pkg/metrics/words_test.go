@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/audio"
+	"github.com/elishowk/speech_latency/pkg/events"
+)
+
+func TestComputeWordLatencies(t *testing.T) {
+	start := time.Now()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	// One 500ms chunk of audio is sent every 500ms
+	sends := []audio.Send{
+		{At: at(500), Offset: 500 * time.Millisecond},
+		{At: at(1000), Offset: 1000 * time.Millisecond},
+		{At: at(1500), Offset: 1500 * time.Millisecond},
+	}
+	log := []events.Event{
+		{Type: events.Interim, ReceivedAt: at(700), Words: []events.Word{{Word: "hello", Start: 0.1, End: 0.4}}},
+		{Type: events.Interim, ReceivedAt: at(1200), Words: []events.Word{{Word: "hello", Start: 0.1, End: 0.45}, {Word: "world", Start: 0.6, End: 0.9}}},
+		{Type: events.Final, ReceivedAt: at(1300), Words: []events.Word{{Word: "Hello,", Start: 0.12, End: 0.45}, {Word: "world", Start: 0.6, End: 0.9}}},
+		{Type: events.Final, ReceivedAt: at(1800), Words: []events.Word{{Word: "again", Start: 1.1, End: 1.4}}},
+		{Type: events.Final, ReceivedAt: at(1900), Words: []events.Word{{Word: "unsent", Start: 1.6, End: 1.9}}},
+	}
+
+	summary := ComputeWordLatencies(log, sends)
+	if len(summary.Words) != 3 {
+		t.Fatalf("expected 3 words with sent audio, got %d", len(summary.Words))
+	}
+
+	want := []struct {
+		word                 string
+		firstSeen, finalized time.Duration
+	}{
+		{"Hello,", 200 * time.Millisecond, 800 * time.Millisecond},
+		{"world", 200 * time.Millisecond, 300 * time.Millisecond},
+		{"again", 300 * time.Millisecond, 300 * time.Millisecond},
+	}
+	for i, w := range want {
+		got := summary.Words[i]
+		if got.Word != w.word || got.FirstSeen != w.firstSeen || got.Finalized != w.finalized {
+			t.Errorf("word %d: got %s first seen %s finalized %s, want %s %s %s",
+				i, got.Word, got.FirstSeen, got.Finalized, w.word, w.firstSeen, w.finalized)
+		}
+	}
+
+	if summary.Finalized.Count != 3 || summary.Finalized.P50 != 300 {
+		t.Errorf("unexpected finalized distribution %+v", summary.Finalized)
+	}
+}
+
+func TestComputeWordLatencies_UntimedHypotheses(t *testing.T) {
+	start := time.Now()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+
+	sends := []audio.Send{
+		{At: at(1000), Offset: time.Second},
+		{At: at(2000), Offset: 2 * time.Second},
+	}
+	// Hypotheses carry words without offsets; those of the first utterance do not
+	// match the words of the second
+	untimed := func(words ...string) []events.Word {
+		var ws []events.Word
+		for _, w := range words {
+			ws = append(ws, events.Word{Word: w})
+		}
+		return ws
+	}
+	log := []events.Event{
+		{Type: events.Interim, ReceivedAt: at(1100), Words: untimed("hello")},
+		{Type: events.Interim, ReceivedAt: at(1200), Words: untimed("hello", "word")},
+		{Type: events.Interim, ReceivedAt: at(1300), Words: untimed("hello", "world")},
+		{Type: events.Final, ReceivedAt: at(1500), Words: []events.Word{{Word: "Hello", Start: 0.1, End: 0.4}, {Word: "world.", Start: 0.5, End: 0.9}}},
+		{Type: events.Interim, ReceivedAt: at(2100), Words: untimed("world")},
+		{Type: events.Final, ReceivedAt: at(2300), Words: []events.Word{{Word: "hello", Start: 1.2, End: 1.5}, {Word: "world", Start: 1.6, End: 1.9}}},
+	}
+
+	summary := ComputeWordLatencies(log, sends)
+	want := []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	if len(summary.Words) != len(want) {
+		t.Fatalf("expected %d words, got %+v", len(want), summary.Words)
+	}
+	for i, firstSeen := range want {
+		if got := summary.Words[i]; got.FirstSeen != firstSeen {
+			t.Errorf("word %d %s: first seen %s, want %s", i, got.Word, got.FirstSeen, firstSeen)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package mockserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/audio"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// DefaultTranscript is returned when Config.Transcript is empty
+	DefaultTranscript = "the quick brown fox jumps over the lazy dog"
+	// DefaultWordDuration is the audio each scripted word lasts on the live API
+	DefaultWordDuration = 300 * time.Millisecond
+	// DefaultInterimEvery is the audio between two interim results on the live API
+	DefaultInterimEvery = 250 * time.Millisecond
+	// DefaultFinalEvery is the audio between two final results on the live API
+	DefaultFinalEvery = 2 * time.Second
+)
+
+// Config scripts the behaviour of the mock server
+type Config struct {
+	Transcript   string        // words returned for any audio, defaults to DefaultTranscript
+	Latency      time.Duration // delay of every response after the audio it covers
+	Jitter       time.Duration // random extra delay, up to Jitter
+	ErrorCode    int           // HTTP status of failed requests, 0 never fails
+	ErrorRate    float64       // fraction of requests failing with ErrorCode, 0 never fails
+	APIKey       string        // token required in the Authorization header, empty accepts any
+	WordDuration time.Duration // defaults to DefaultWordDuration
+	InterimEvery time.Duration // defaults to DefaultInterimEvery
+	FinalEvery   time.Duration // defaults to DefaultFinalEvery
+	Seed         int64         // seed of the jitter and error draws, for reproducible runs
+}
+
+// Server emulates Deepgram's pre-recorded REST API and live WebSocket API on /v1/listen
+type Server struct {
+	config   Config
+	words    []string
+	upgrader websocket.Upgrader
+
+	mu       sync.Mutex
+	rand     *rand.Rand
+	requests int
+}
+
+// New creates a mock server
+func New(config Config) *Server {
+	if config.Transcript == "" {
+		config.Transcript = DefaultTranscript
+	}
+	if config.WordDuration <= 0 {
+		config.WordDuration = DefaultWordDuration
+	}
+	if config.InterimEvery <= 0 {
+		config.InterimEvery = DefaultInterimEvery
+	}
+	if config.FinalEvery <= 0 {
+		config.FinalEvery = DefaultFinalEvery
+	}
+	return &Server{
+		config: config,
+		words:  strings.Fields(config.Transcript),
+		rand:   rand.New(rand.NewSource(config.Seed)),
+	}
+}
+
+// Requests returns the number of requests served so far
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/listen" {
+		http.NotFound(w, r)
+		return
+	}
+	if s.config.APIKey != "" && r.Header.Get("Authorization") != "Token "+s.config.APIKey {
+		writeError(w, http.StatusUnauthorized, "INVALID_AUTH", "Invalid credentials.")
+		return
+	}
+
+	s.mu.Lock()
+	s.requests++
+	fail := s.config.ErrorCode != 0 && s.config.ErrorRate > 0 && s.rand.Float64() < s.config.ErrorRate
+	s.mu.Unlock()
+	if fail {
+		writeError(w, s.config.ErrorCode, "MOCK_ERROR", "Scripted mock server error.")
+		return
+	}
+
+	switch {
+	case websocket.IsWebSocketUpgrade(r):
+		s.serveLive(w, r)
+	case r.Method == http.MethodPost:
+		s.serveREST(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeError writes an error body shaped like Deepgram's
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"err_code": code, "err_msg": message})
+}
+
+// delay returns the latency of a response, jitter included
+func (s *Server) delay() time.Duration {
+	d := s.config.Latency
+	if s.config.Jitter > 0 {
+		s.mu.Lock()
+		d += time.Duration(s.rand.Int63n(int64(s.config.Jitter) + 1))
+		s.mu.Unlock()
+	}
+	return d
+}
+
+// word is a scripted word with its timing
+type word struct {
+	Word           string  `json:"word"`
+	Start          float64 `json:"start"`
+	End            float64 `json:"end"`
+	Confidence     float64 `json:"confidence"`
+	PunctuatedWord string  `json:"punctuated_word"`
+}
+
+// alternative is a transcript hypothesis
+type alternative struct {
+	Transcript string  `json:"transcript"`
+	Confidence float64 `json:"confidence"`
+	Words      []word  `json:"words"`
+}
+
+// newAlternative builds the hypothesis made of words
+func newAlternative(words []word) alternative {
+	texts := make([]string, len(words))
+	for i, w := range words {
+		texts[i] = w.Word
+	}
+	if words == nil {
+		words = []word{}
+	}
+	return alternative{Transcript: strings.Join(texts, " "), Confidence: 0.99, Words: words}
+}
+
+// serveREST answers a pre-recorded request with the whole transcript, its words
+// spread evenly over the uploaded audio
+func (s *Server) serveREST(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", "failed to read audio")
+		return
+	}
+	duration, err := audio.WAVDuration(bytes.NewReader(body))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BAD_REQUEST", fmt.Sprintf("failed to read audio: %v", err))
+		return
+	}
+	time.Sleep(s.delay())
+
+	words := make([]word, len(s.words))
+	step := duration.Seconds() / float64(max(len(s.words), 1))
+	for i, text := range s.words {
+		words[i] = word{Word: text, PunctuatedWord: text, Start: float64(i) * step, End: float64(i+1) * step, Confidence: 0.99}
+	}
+
+	response := map[string]any{
+		"metadata": map[string]any{"request_id": s.requestID(), "duration": duration.Seconds(), "channels": 1},
+		"results": map[string]any{
+			"channels": []any{map[string]any{"alternatives": []alternative{newAlternative(words)}}},
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// requestID identifies the current request in responses
+func (s *Server) requestID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fmt.Sprintf("mock-%d", s.requests)
+}
+
+// liveMessage is a message scheduled on a live connection
+type liveMessage struct {
+	due     time.Time
+	payload []byte
+	close   bool
+}
+
+// liveSession tracks the audio heard on a live connection
+type liveSession struct {
+	server         *Server
+	words          []word
+	bytesPerSecond float64
+	interim        bool
+	utteranceEnd   bool
+	out            chan liveMessage
+	lastDue        time.Time
+
+	received    int64
+	finalized   int           // words already sent in a final result
+	lastFinal   time.Duration // audio offset of the last final result
+	nextInterim time.Duration
+	nextFinal   time.Duration
+}
+
+// serveLive streams scripted results as the audio arrives: interim results every
+// InterimEvery of audio, final ones every FinalEvery, and the rest on CloseStream
+func (s *Server) serveLive(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	sampleRate, _ := strconv.Atoi(q.Get("sample_rate"))
+	if sampleRate <= 0 {
+		sampleRate = 16000
+	}
+	channels, _ := strconv.Atoi(q.Get("channels"))
+	if channels <= 0 {
+		channels = 1
+	}
+	bytesPerSample := 2
+	if encoding := q.Get("encoding"); encoding == "mulaw" || encoding == "alaw" {
+		bytesPerSample = 1
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	words := make([]word, len(s.words))
+	step := s.config.WordDuration.Seconds()
+	for i, text := range s.words {
+		words[i] = word{Word: text, PunctuatedWord: text, Start: float64(i) * step, End: float64(i+1) * step, Confidence: 0.99}
+	}
+	session := &liveSession{
+		server:         s,
+		words:          words,
+		bytesPerSecond: float64(sampleRate * channels * bytesPerSample),
+		interim:        q.Get("interim_results") == "true",
+		utteranceEnd:   q.Get("utterance_end_ms") != "",
+		out:            make(chan liveMessage, 64),
+		nextInterim:    s.config.InterimEvery,
+		nextFinal:      s.config.FinalEvery,
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		session.send(conn)
+	}()
+	defer func() {
+		close(session.out)
+		<-done
+	}()
+
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if msgType == websocket.BinaryMessage {
+			session.hear(len(data))
+			continue
+		}
+
+		var control struct {
+			Type string `json:"type"`
+		}
+		json.Unmarshal(data, &control)
+		switch control.Type {
+		case "Finalize":
+			session.final(session.heard(), false)
+		case "CloseStream":
+			// Whatever was not recognized yet comes with the last result
+			session.final(time.Duration(float64(len(words))*step*float64(time.Second)), true)
+			session.schedule(map[string]any{"type": "Metadata", "request_id": s.requestID(), "duration": session.heard().Seconds()})
+			session.out <- liveMessage{due: session.lastDue, close: true}
+			return
+		}
+	}
+}
+
+// heard returns the duration of the audio received so far
+func (l *liveSession) heard() time.Duration {
+	return time.Duration(float64(l.received) / l.bytesPerSecond * float64(time.Second))
+}
+
+// hear accounts for n more bytes of audio, sending the results they complete
+func (l *liveSession) hear(n int) {
+	l.received += int64(n)
+	heard := l.heard()
+	for {
+		switch {
+		case l.nextFinal <= heard && l.nextFinal <= l.nextInterim:
+			l.final(l.nextFinal, false)
+		case l.nextInterim <= heard:
+			if l.interim {
+				if words := l.wordsUntil(l.nextInterim); len(words) > 0 {
+					l.result(words, l.nextInterim, false)
+				}
+			}
+			l.nextInterim += l.server.config.InterimEvery
+		default:
+			return
+		}
+	}
+}
+
+// wordsUntil returns the words not finalized yet that end by offset
+func (l *liveSession) wordsUntil(offset time.Duration) []word {
+	end := l.finalized
+	for end < len(l.words) && l.words[end].End <= offset.Seconds() {
+		end++
+	}
+	return l.words[l.finalized:end]
+}
+
+// final sends the words ending by offset as a final result and restarts the cadence there
+func (l *liveSession) final(offset time.Duration, last bool) {
+	words := l.wordsUntil(offset)
+	if len(words) > 0 || last {
+		l.result(words, offset, true)
+		if l.utteranceEnd && len(words) > 0 {
+			l.schedule(map[string]any{"type": "UtteranceEnd", "channel": []int{0, 1}, "last_word_end": words[len(words)-1].End})
+		}
+	}
+	l.finalized += len(words)
+	l.lastFinal = offset
+	l.nextFinal = offset + l.server.config.FinalEvery
+	l.nextInterim = offset + l.server.config.InterimEvery
+}
+
+// result schedules a Results message covering the audio since the last final result
+func (l *liveSession) result(words []word, offset time.Duration, final bool) {
+	l.schedule(map[string]any{
+		"type":          "Results",
+		"channel_index": []int{0, 1},
+		"start":         l.lastFinal.Seconds(),
+		"duration":      (offset - l.lastFinal).Seconds(),
+		"is_final":      final,
+		"speech_final":  final,
+		"channel":       map[string]any{"alternatives": []alternative{newAlternative(words)}},
+	})
+}
+
+// schedule queues a message after the response latency, keeping messages in order
+func (l *liveSession) schedule(message any) {
+	payload, _ := json.Marshal(message)
+	due := time.Now().Add(l.server.delay())
+	if due.Before(l.lastDue) {
+		due = l.lastDue
+	}
+	l.lastDue = due
+	l.out <- liveMessage{due: due, payload: payload}
+}
+
+// send writes the queued messages when they are due
+func (l *liveSession) send(conn *websocket.Conn) {
+	for message := range l.out {
+		time.Sleep(time.Until(message.due))
+		if message.close {
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			continue
+		}
+		conn.WriteMessage(websocket.TextMessage, message.payload)
+	}
+}
@@ -0,0 +1,147 @@
+package mockserver
+
+import (
+	"context"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/bench"
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers"
+)
+
+// writeWAV writes a silent 16-bit mono WAV file
+func writeWAV(t *testing.T, sampleRate int, duration time.Duration) string {
+	t.Helper()
+	dataSize := int(duration.Seconds()*float64(sampleRate)) * 2
+	header := make([]byte, 44)
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(36+dataSize))
+	copy(header[8:16], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(header[16:20], 16)
+	binary.LittleEndian.PutUint16(header[20:22], 1)
+	binary.LittleEndian.PutUint16(header[22:24], 1)
+	binary.LittleEndian.PutUint32(header[24:28], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(header[28:32], uint32(sampleRate*2))
+	binary.LittleEndian.PutUint16(header[32:34], 2)
+	binary.LittleEndian.PutUint16(header[34:36], 16)
+	copy(header[36:40], "data")
+	binary.LittleEndian.PutUint32(header[40:44], uint32(dataSize))
+
+	path := filepath.Join(t.TempDir(), "audio.wav")
+	if err := os.WriteFile(path, append(header, make([]byte, dataSize)...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// runAgainst benchmarks the deepgram provider against the mock server
+func runAgainst(t *testing.T, srv *httptest.Server, path string, live bool) (*bench.Run, error) {
+	t.Helper()
+	return bench.RunOnce(context.Background(), providers.NewFactory(), bench.Options{
+		Provider:      "deepgram",
+		AudioPath:     path,
+		ChunkSize:     640,
+		ChunkDuration: 20 * time.Millisecond,
+		Unpaced:       true,
+		APIKey:        "test-key",
+		Config:        providers.Config{Language: "en-US", Interim: true, Live: live, BaseURL: srv.URL},
+	})
+}
+
+func TestServer_REST(t *testing.T) {
+	srv := httptest.NewServer(New(Config{Transcript: "hello mock world", Latency: 50 * time.Millisecond, APIKey: "test-key"}))
+	defer srv.Close()
+
+	run, err := runAgainst(t, srv, writeWAV(t, 16000, 600*time.Millisecond), false)
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if run.Summary.Transcript != "hello mock world" || run.Summary.WordCount != 3 {
+		t.Errorf("unexpected summary %+v", run.Summary)
+	}
+	if run.Summary.FinalLatency < 50*time.Millisecond {
+		t.Errorf("expected the scripted latency, got %s", run.Summary.FinalLatency)
+	}
+	if last := run.Words.Words[2]; last.End < 0.59 || last.End > 0.61 {
+		t.Errorf("expected the words to span the audio, last ends at %g", last.End)
+	}
+}
+
+func TestServer_Live(t *testing.T) {
+	srv := httptest.NewServer(New(Config{
+		Transcript:   "one two three four five",
+		WordDuration: 200 * time.Millisecond,
+		InterimEvery: 100 * time.Millisecond,
+		FinalEvery:   400 * time.Millisecond,
+	}))
+	defer srv.Close()
+
+	// One second of audio hears all five words: finals at 400 and 800ms, the last word on close
+	run, err := runAgainst(t, srv, writeWAV(t, 16000, time.Second), true)
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if run.Summary.Transcript != "one two three four five" {
+		t.Errorf("unexpected transcript %q", run.Summary.Transcript)
+	}
+
+	var interims, finals int
+	for _, e := range run.Events {
+		switch e.Type {
+		case events.Interim:
+			interims++
+		case events.Final:
+			finals++
+		}
+	}
+	if finals != 3 || interims == 0 {
+		t.Errorf("expected 3 finals and some interims, got %d and %d", finals, interims)
+	}
+}
+
+func TestServer_Errors(t *testing.T) {
+	srv := httptest.NewServer(New(Config{ErrorCode: http.StatusTooManyRequests, ErrorRate: 1}))
+	defer srv.Close()
+	path := writeWAV(t, 16000, 100*time.Millisecond)
+
+	for _, live := range []bool{false, true} {
+		if _, err := runAgainst(t, srv, path, live); err == nil || !strings.Contains(err.Error(), "429") {
+			t.Errorf("live=%v: expected a 429 error, got %v", live, err)
+		}
+	}
+
+	// Half the requests fail, reproducibly for a given seed
+	flaky := New(Config{ErrorCode: http.StatusServiceUnavailable, ErrorRate: 0.5, Seed: 7})
+	failures := 0
+	for i := 0; i < 200; i++ {
+		rec := httptest.NewRecorder()
+		flaky.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/listen", nil))
+		if rec.Code == http.StatusServiceUnavailable {
+			failures++
+		}
+	}
+	if failures < 70 || failures > 130 {
+		t.Errorf("expected about 100 failures, got %d", failures)
+	}
+
+	// No request fails without an error rate
+	never := New(Config{ErrorCode: http.StatusServiceUnavailable})
+	rec := httptest.NewRecorder()
+	never.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/listen", nil))
+	if rec.Code == http.StatusServiceUnavailable {
+		t.Error("expected no failure with an error rate of 0")
+	}
+
+	unauthorized := httptest.NewServer(New(Config{APIKey: "other-key"}))
+	defer unauthorized.Close()
+	if _, err := runAgainst(t, unauthorized, path, false); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected a 401 error, got %v", err)
+	}
+}
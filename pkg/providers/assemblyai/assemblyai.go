@@ -0,0 +1,215 @@
+package assemblyai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers/internal/wsstream"
+	"github.com/gorilla/websocket"
+)
+
+// Config holds the provider configuration
+type Config struct {
+	SampleRate       int
+	Channels         int
+	Encoding         string     // encoding of the audio, linear16 or mulaw, defaults to linear16
+	BaseURL          string     // defaults to DefaultBaseURL
+	Model            string     // speech model, empty selects the service default
+	FormatTurns      bool       // finalize turns with punctuation and casing
+	Partials         bool       // report partial turns as interim events; the service always sends them
+	EndOfTurnSilence int        // silence in milliseconds ending a confident turn, 0 keeps the default
+	MaxTurnSilence   int        // silence in milliseconds always ending a turn, 0 keeps the default
+	Keyterms         []string   // terms to boost
+	Options          url.Values // passthrough query parameters, replacing those of the same name
+}
+
+// DefaultBaseURL is the Universal-Streaming endpoint used when Config.BaseURL is empty
+const DefaultBaseURL = "wss://streaming.assemblyai.com"
+
+// Provider implements the speech recognition provider using AssemblyAI Universal-Streaming
+type Provider struct {
+	apiKey string
+	config *Config
+}
+
+// NewProvider creates a new AssemblyAI provider
+func NewProvider(config *Config, apiKey string) (*Provider, error) {
+	if config.Channels > 1 {
+		return nil, fmt.Errorf("AssemblyAI streams mono audio only, got %d channels", config.Channels)
+	}
+	return &Provider{
+		apiKey: apiKey,
+		config: config,
+	}, nil
+}
+
+// message is a message received on the streaming API
+type message struct {
+	Type            string `json:"type"`
+	Transcript      string `json:"transcript"`
+	EndOfTurn       bool   `json:"end_of_turn"`
+	TurnIsFormatted bool   `json:"turn_is_formatted"`
+	Words           []word `json:"words"`
+	Error           string `json:"error"`
+}
+
+// word is a word of a turn, with times in milliseconds
+type word struct {
+	Text       string  `json:"text"`
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Confidence float64 `json:"confidence"`
+}
+
+// streamURL builds the WebSocket URL with the session parameters
+func (p *Provider) streamURL() (string, error) {
+	base := DefaultBaseURL
+	if p.config.BaseURL != "" {
+		base = strings.TrimRight(p.config.BaseURL, "/")
+	}
+	u, err := url.Parse(base + "/v3/ws")
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %w", err)
+	}
+	if err := wsstream.NormalizeScheme(u); err != nil {
+		return "", err
+	}
+
+	encoding := "pcm_s16le"
+	if p.config.Encoding == "mulaw" {
+		encoding = "pcm_mulaw"
+	}
+	q := url.Values{}
+	q.Set("sample_rate", strconv.Itoa(p.config.SampleRate))
+	q.Set("encoding", encoding)
+	q.Set("format_turns", strconv.FormatBool(p.config.FormatTurns))
+	if p.config.Model != "" {
+		q.Set("speech_model", p.config.Model)
+	}
+	if p.config.EndOfTurnSilence > 0 {
+		q.Set("min_end_of_turn_silence_when_confident", strconv.Itoa(p.config.EndOfTurnSilence))
+	}
+	if p.config.MaxTurnSilence > 0 {
+		q.Set("max_turn_silence", strconv.Itoa(p.config.MaxTurnSilence))
+	}
+	if len(p.config.Keyterms) > 0 {
+		keyterms, _ := json.Marshal(p.config.Keyterms)
+		q.Set("keyterms_prompt", string(keyterms))
+	}
+	for key, values := range p.config.Options {
+		q[key] = values
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// StreamAudio sends the audio chunk by chunk as it is paced by the reader and
+// emits partial turns as interim events and completed turns as final events
+func (p *Provider) StreamAudio(ctx context.Context, audioReader io.Reader, sink events.Sink) error {
+	wsURL, err := p.streamURL()
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", p.apiKey)
+
+	conn, err := wsstream.Dial(ctx, sink, wsURL, header, nil)
+	if err != nil {
+		return events.EmitError(sink, err)
+	}
+	defer conn.Close()
+	sink.Emit(events.Event{Type: events.Opened})
+	defer sink.Emit(events.Event{Type: events.Closed})
+
+	// Audio starts once the session has begun
+	var begin message
+	_, data, err := conn.Receive()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &begin)
+	}
+	if err != nil {
+		return events.EmitError(sink, fmt.Errorf("failed to begin session: %w", err))
+	}
+	if begin.Type != "Begin" {
+		return events.EmitError(sink, fmt.Errorf("failed to begin session: unexpected %s message %s", begin.Type, begin.Error))
+	}
+
+	sendErr := make(chan error, 1)
+	go func() {
+		sendErr <- sendAudio(conn, audioReader)
+	}()
+
+	for {
+		_, data, err := conn.Receive()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return events.EmitError(sink, err)
+		}
+		receivedAt := time.Now()
+
+		var msg message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return events.EmitError(sink, fmt.Errorf("failed to decode message: %w", err))
+		}
+		if msg.Error != "" {
+			sink.Emit(events.Event{Type: events.Error, ReceivedAt: receivedAt, Err: fmt.Errorf("provider error: %s", msg.Error)})
+			continue
+		}
+		if msg.Type == "Termination" {
+			break
+		}
+		if msg.Type != "Turn" {
+			continue
+		}
+
+		// With formatting, a turn ends twice: unformatted, then formatted
+		final := msg.EndOfTurn && (msg.TurnIsFormatted || !p.config.FormatTurns)
+		eventType := events.Interim
+		if final {
+			eventType = events.Final
+		} else if !p.config.Partials {
+			continue
+		}
+		words := make([]events.Word, len(msg.Words))
+		for i, w := range msg.Words {
+			words[i] = events.Word{Word: w.Text, Start: w.Start / 1000, End: w.End / 1000, Confidence: w.Confidence}
+		}
+		sink.Emit(events.Event{
+			Type:       eventType,
+			ReceivedAt: receivedAt,
+			Transcript: msg.Transcript,
+			Words:      words,
+		})
+	}
+
+	if err := <-sendErr; err != nil {
+		return events.EmitError(sink, err)
+	}
+	return nil
+}
+
+// sendAudio writes each chunk as it is paced by the reader, then terminates the session
+func sendAudio(conn *wsstream.Conn, audioReader io.Reader) error {
+	if err := wsstream.SendChunks(audioReader, conn.SendBinary); err != nil {
+		return err
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"Terminate"}`)); err != nil {
+		return fmt.Errorf("failed to terminate session: %w", err)
+	}
+	return nil
+}
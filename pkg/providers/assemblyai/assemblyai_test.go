@@ -0,0 +1,160 @@
+package assemblyai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers/internal/providertest"
+	"github.com/gorilla/websocket"
+)
+
+// streamingStandIn emulates Universal-Streaming: a partial turn per audio chunk,
+// then the unformatted and formatted end of turn and the termination
+func streamingStandIn(t *testing.T, received *bytes.Buffer) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/ws" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "test-key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("sample_rate") != "16000" || q.Get("encoding") != "pcm_s16le" || q.Get("keyterms_prompt") != `["AssemblyAI"]` {
+			http.Error(w, "bad parameters", http.StatusBadRequest)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+		send := func(msg map[string]any) {
+			payload, _ := json.Marshal(msg)
+			conn.WriteMessage(websocket.TextMessage, payload)
+		}
+		turn := func(words []map[string]any, endOfTurn, formatted bool) map[string]any {
+			text := make([]string, len(words))
+			for i, w := range words {
+				text[i] = w["text"].(string)
+			}
+			return map[string]any{"type": "Turn", "turn_order": 0, "end_of_turn": endOfTurn, "turn_is_formatted": formatted,
+				"transcript": strings.Join(text, " "), "words": words}
+		}
+		send(map[string]any{"type": "Begin", "id": "session", "expires_at": 0})
+
+		var words []map[string]any
+		for {
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				t.Errorf("read failed: %v", err)
+				return
+			}
+			if msgType == websocket.TextMessage {
+				if !strings.Contains(string(data), "Terminate") {
+					t.Errorf("unexpected control message: %s", data)
+				}
+				send(turn(words, true, false))
+				words[0]["text"] = "Hello"
+				send(turn(words, true, true))
+				send(map[string]any{"type": "Termination", "audio_duration_seconds": 1})
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+
+			received.Write(data)
+			start := len(words) * 500
+			words = append(words, map[string]any{"text": "hello", "start": start, "end": start + 400, "confidence": 0.9, "word_is_final": false})
+			send(turn(words, false, false))
+		}
+	}))
+}
+
+func TestStreamAudio(t *testing.T) {
+	var received bytes.Buffer
+	srv := streamingStandIn(t, &received)
+	defer srv.Close()
+
+	provider, err := NewProvider(&Config{
+		SampleRate:  16000,
+		Channels:    1,
+		BaseURL:     srv.URL,
+		FormatTurns: true,
+		Partials:    true,
+		Keyterms:    []string{"AssemblyAI"},
+	}, "test-key")
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+
+	audio := bytes.Repeat([]byte{1, 2}, 1000)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	log := events.NewLog(nil)
+	if err := provider.StreamAudio(ctx, &providertest.ChunkReader{Data: audio, Size: 1000}, log); err != nil {
+		t.Fatalf("StreamAudio failed: %v", err)
+	}
+	if !bytes.Equal(received.Bytes(), audio) {
+		t.Errorf("server received %d bytes, want %d", received.Len(), len(audio))
+	}
+
+	// Opened, two partials, the unformatted end of turn as an interim, the formatted one as final, Closed
+	got := log.Events()
+	providertest.CheckTypes(t, got, []events.Type{events.Opened, events.Interim, events.Interim, events.Interim, events.Final, events.Closed})
+	final := got[4]
+	if final.Transcript != "Hello hello" || len(final.Words) != 2 || final.Words[1].Start != 0.5 || final.Words[1].End != 0.9 {
+		t.Errorf("unexpected final %+v", final)
+	}
+
+	// Every raw message is recorded, the Begin handshake included
+	messages := log.Messages()
+	if len(messages) != 6 || !strings.Contains(string(messages[0].Data), `"Begin"`) || !strings.Contains(string(messages[5].Data), `"Termination"`) {
+		t.Errorf("unexpected messages %+v", messages)
+	}
+}
+
+func TestStreamAudio_Errors(t *testing.T) {
+	if _, err := NewProvider(&Config{SampleRate: 16000, Channels: 2}, "test-key"); err == nil {
+		t.Error("expected error for stereo audio")
+	}
+
+	var received bytes.Buffer
+	srv := streamingStandIn(t, &received)
+	defer srv.Close()
+
+	provider, _ := NewProvider(&Config{SampleRate: 16000, Channels: 1, BaseURL: srv.URL}, "wrong-key")
+	log := events.NewLog(nil)
+	err := provider.StreamAudio(context.Background(), &providertest.ChunkReader{Data: []byte{0, 0}, Size: 2}, log)
+	if err == nil || !strings.Contains(err.Error(), "API error 401") {
+		t.Errorf("expected API error 401, got %v", err)
+	}
+
+	// A session closed with an error code fails the run
+	closing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"Begin","id":"session"}`))
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(3005, "Too many concurrent sessions"))
+	}))
+	defer closing.Close()
+	provider, _ = NewProvider(&Config{SampleRate: 16000, Channels: 1, BaseURL: closing.URL}, "test-key")
+	err = provider.StreamAudio(context.Background(), &providertest.ChunkReader{Data: []byte{0, 0}, Size: 2}, events.NewLog(nil))
+	if err == nil || !strings.Contains(err.Error(), "code 3005: Too many concurrent sessions") {
+		t.Errorf("expected the close reason, got %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+package awstranscribe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers/internal/wsstream"
+	"github.com/gorilla/websocket"
+)
+
+// Config holds the provider configuration
+type Config struct {
+	SampleRate   int
+	Channels     int
+	Language     string
+	Interim      bool
+	Encoding     string     // encoding of the audio, only linear16 streams as is
+	Region       string     // defaults to DefaultRegion
+	BaseURL      string     // defaults to the regional streaming endpoint
+	Diarize      bool       // label the speaker of every word
+	Multichannel bool       // transcribe each channel independently
+	Options      url.Values // passthrough query parameters, replacing those of the same name
+}
+
+// DefaultRegion is the region used when Config.Region is empty
+const DefaultRegion = "us-east-1"
+
+const (
+	// streamPath is the path of the WebSocket streaming API
+	streamPath = "/stream-transcription-websocket"
+	// urlExpiry is how long the presigned URL may be used to connect
+	urlExpiry = 5 * time.Minute
+)
+
+// Provider implements the speech recognition provider using Amazon Transcribe streaming
+type Provider struct {
+	credentials Credentials
+	config      *Config
+}
+
+// NewProvider creates a new Amazon Transcribe provider; apiKey holds the credentials
+// as ACCESS_KEY_ID:SECRET_ACCESS_KEY[:SESSION_TOKEN]
+func NewProvider(config *Config, apiKey string) (*Provider, error) {
+	credentials, err := ParseCredentials(apiKey)
+	if err != nil {
+		return nil, err
+	}
+	if config.Encoding != "" && config.Encoding != "linear16" {
+		return nil, fmt.Errorf("Amazon Transcribe streams linear16 audio only, got %s", config.Encoding)
+	}
+	return &Provider{
+		credentials: credentials,
+		config:      config,
+	}, nil
+}
+
+// transcriptEvent is the payload of a TranscriptEvent message
+type transcriptEvent struct {
+	Transcript struct {
+		Results []result `json:"Results"`
+	} `json:"Transcript"`
+}
+
+// result is a segment of the transcript, partial until its final version
+type result struct {
+	ResultID     string        `json:"ResultId"`
+	IsPartial    bool          `json:"IsPartial"`
+	Alternatives []alternative `json:"Alternatives"`
+}
+
+type alternative struct {
+	Transcript string `json:"Transcript"`
+	Items      []item `json:"Items"`
+}
+
+// item is a word or a punctuation mark, with times in seconds
+type item struct {
+	Type       string  `json:"Type"`
+	Content    string  `json:"Content"`
+	StartTime  float64 `json:"StartTime"`
+	EndTime    float64 `json:"EndTime"`
+	Confidence float64 `json:"Confidence"`
+}
+
+// region returns the configured region
+func (p *Provider) region() string {
+	if p.config.Region == "" {
+		return DefaultRegion
+	}
+	return p.config.Region
+}
+
+// streamURL builds the presigned WebSocket URL with the session parameters
+func (p *Provider) streamURL(now time.Time) (string, error) {
+	base := fmt.Sprintf("wss://transcribestreaming.%s.amazonaws.com:8443", p.region())
+	if p.config.BaseURL != "" {
+		base = strings.TrimRight(p.config.BaseURL, "/")
+	}
+	u, err := url.Parse(base + streamPath)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %w", err)
+	}
+	if err := wsstream.NormalizeScheme(u); err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	q.Set("language-code", p.config.Language)
+	q.Set("media-encoding", "pcm")
+	q.Set("sample-rate", strconv.Itoa(p.config.SampleRate))
+	if p.config.Diarize {
+		q.Set("show-speaker-label", "true")
+	}
+	if p.config.Multichannel && p.config.Channels > 1 {
+		q.Set("enable-channel-identification", "true")
+		q.Set("number-of-channels", strconv.Itoa(p.config.Channels))
+	}
+	for key, values := range p.config.Options {
+		q[key] = values
+	}
+	u.RawQuery = q.Encode()
+
+	presign(u, p.credentials, "transcribe", p.region(), now, urlExpiry, emptyPayloadHash)
+	return u.String(), nil
+}
+
+// StreamAudio sends the audio as event-stream audio events as it is paced by the reader
+// and emits partial results as interim events and complete results as final events
+func (p *Provider) StreamAudio(ctx context.Context, audioReader io.Reader, sink events.Sink) error {
+	wsURL, err := p.streamURL(time.Now())
+	if err != nil {
+		return err
+	}
+
+	conn, err := wsstream.Dial(ctx, sink, wsURL, nil, nil)
+	if err != nil {
+		return events.EmitError(sink, err)
+	}
+	defer conn.Close()
+	sink.Emit(events.Event{Type: events.Opened})
+	defer sink.Emit(events.Event{Type: events.Closed})
+
+	sendErr := make(chan error, 1)
+	go func() {
+		sendErr <- sendAudio(conn, audioReader)
+	}()
+
+	for {
+		_, data, err := conn.Receive()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return events.EmitError(sink, err)
+		}
+		receivedAt := time.Now()
+
+		msg, err := DecodeMessage(data)
+		if err != nil {
+			return events.EmitError(sink, fmt.Errorf("failed to decode message: %w", err))
+		}
+		switch msg.Header(":message-type") {
+		case "exception":
+			var exception struct {
+				Message string `json:"Message"`
+			}
+			json.Unmarshal(msg.Payload, &exception)
+			return events.EmitError(sink, fmt.Errorf("provider error %s: %s", msg.Header(":exception-type"), exception.Message))
+		case "event":
+			if msg.Header(":event-type") != "TranscriptEvent" {
+				continue
+			}
+		default:
+			continue
+		}
+
+		var event transcriptEvent
+		if err := json.Unmarshal(msg.Payload, &event); err != nil {
+			return events.EmitError(sink, fmt.Errorf("failed to decode transcript: %w", err))
+		}
+		p.emitResults(event.Transcript.Results, receivedAt, sink)
+	}
+
+	if err := <-sendErr; err != nil {
+		return events.EmitError(sink, err)
+	}
+	return nil
+}
+
+// emitResults reports each result with its words, leaving punctuation out of the words
+func (p *Provider) emitResults(results []result, receivedAt time.Time, sink events.Sink) {
+	for _, r := range results {
+		if len(r.Alternatives) == 0 || (r.IsPartial && !p.config.Interim) {
+			continue
+		}
+		alt := r.Alternatives[0]
+		words := make([]events.Word, 0, len(alt.Items))
+		for _, it := range alt.Items {
+			if it.Type == "punctuation" {
+				continue
+			}
+			words = append(words, events.Word{Word: it.Content, Start: it.StartTime, End: it.EndTime, Confidence: it.Confidence})
+		}
+		eventType := events.Final
+		if r.IsPartial {
+			eventType = events.Interim
+		}
+		sink.Emit(events.Event{
+			Type:       eventType,
+			ReceivedAt: receivedAt,
+			Transcript: alt.Transcript,
+			Words:      words,
+		})
+	}
+}
+
+// audioEvent encodes a chunk of audio; an empty chunk ends the stream
+func audioEvent(chunk []byte) ([]byte, error) {
+	msg := Message{
+		Headers: []Header{
+			StringHeader(":content-type", "application/octet-stream"),
+			StringHeader(":event-type", "AudioEvent"),
+			StringHeader(":message-type", "event"),
+		},
+		Payload: chunk,
+	}
+	return msg.Encode()
+}
+
+// sendAudio writes each chunk as it is paced by the reader, then the empty audio event
+// ending the stream
+func sendAudio(conn *wsstream.Conn, audioReader io.Reader) error {
+	err := wsstream.SendChunks(audioReader, func(chunk []byte) error {
+		frame, err := audioEvent(chunk)
+		if err != nil {
+			return err
+		}
+		return conn.SendBinary(frame)
+	})
+	if err != nil {
+		return err
+	}
+
+	frame, _ := audioEvent(nil)
+	if err := conn.WriteMessage(websocket.BinaryMessage, frame); err != nil {
+		return fmt.Errorf("failed to end stream: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package awstranscribe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers/internal/providertest"
+	"github.com/gorilla/websocket"
+)
+
+var testCredentials = Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "session"}
+
+// verifySignature signs the request again, as the service does
+func verifySignature(r *http.Request) bool {
+	q := r.URL.Query()
+	signature := q.Get("X-Amz-Signature")
+	date, err := time.Parse("20060102T150405Z", q.Get("X-Amz-Date"))
+	if err != nil || q.Get("X-Amz-Security-Token") != testCredentials.SessionToken {
+		return false
+	}
+	for key := range q {
+		if strings.HasPrefix(key, "X-Amz-") {
+			q.Del(key)
+		}
+	}
+	u := &url.URL{Scheme: "ws", Host: r.Host, Path: r.URL.Path, RawQuery: q.Encode()}
+	presign(u, testCredentials, "transcribe", "eu-west-1", date, urlExpiry, emptyPayloadHash)
+	return u.Query().Get("X-Amz-Signature") == signature
+}
+
+// transcribeStandIn emulates the streaming API: a partial result per audio event,
+// then the complete result once the empty audio event ends the stream
+func transcribeStandIn(t *testing.T, received *bytes.Buffer) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != streamPath || !verifySignature(r) {
+			http.Error(w, "signature mismatch", http.StatusForbidden)
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("language-code") != "en-US" || q.Get("sample-rate") != "16000" || q.Get("media-encoding") != "pcm" {
+			http.Error(w, "bad parameters", http.StatusBadRequest)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+		send := func(partial bool, items []map[string]any) {
+			words := make([]string, 0, len(items))
+			for _, it := range items {
+				if it["Type"] == "pronunciation" {
+					words = append(words, it["Content"].(string))
+				}
+			}
+			payload, _ := json.Marshal(map[string]any{"Transcript": map[string]any{"Results": []map[string]any{{
+				"ResultId":     "r1",
+				"IsPartial":    partial,
+				"Alternatives": []map[string]any{{"Transcript": strings.Join(words, " "), "Items": items}},
+			}}}})
+			msg := Message{Headers: []Header{
+				StringHeader(":message-type", "event"),
+				StringHeader(":event-type", "TranscriptEvent"),
+				StringHeader(":content-type", "application/json"),
+			}, Payload: payload}
+			frame, _ := msg.Encode()
+			conn.WriteMessage(websocket.BinaryMessage, frame)
+		}
+
+		var items []map[string]any
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				t.Errorf("read failed: %v", err)
+				return
+			}
+			msg, err := DecodeMessage(data)
+			if err != nil || msg.Header(":event-type") != "AudioEvent" {
+				t.Errorf("unexpected frame: %v", err)
+				return
+			}
+			if len(msg.Payload) == 0 {
+				items = append(items, map[string]any{"Type": "punctuation", "Content": "."})
+				send(false, items)
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+
+			received.Write(msg.Payload)
+			start := float64(len(items)) * 0.5
+			items = append(items, map[string]any{"Type": "pronunciation", "Content": "hello", "StartTime": start, "EndTime": start + 0.4, "Confidence": 0.9})
+			send(true, items)
+		}
+	}))
+}
+
+func TestStreamAudio(t *testing.T) {
+	var received bytes.Buffer
+	srv := transcribeStandIn(t, &received)
+	defer srv.Close()
+
+	provider, err := NewProvider(&Config{
+		SampleRate: 16000,
+		Channels:   1,
+		Language:   "en-US",
+		Interim:    true,
+		Region:     "eu-west-1",
+		BaseURL:    srv.URL,
+	}, "AKIDEXAMPLE:secret:session")
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+
+	audio := bytes.Repeat([]byte{1, 2}, 1000)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	log := events.NewLog(nil)
+	if err := provider.StreamAudio(ctx, &providertest.ChunkReader{Data: audio, Size: 1000}, log); err != nil {
+		t.Fatalf("StreamAudio failed: %v", err)
+	}
+	if !bytes.Equal(received.Bytes(), audio) {
+		t.Errorf("server received %d bytes, want %d", received.Len(), len(audio))
+	}
+
+	got := log.Events()
+	providertest.CheckTypes(t, got, []events.Type{events.Opened, events.Interim, events.Interim, events.Final, events.Closed})
+	final := got[3]
+	if final.Transcript != "hello hello" || len(final.Words) != 2 || final.Words[1].Start != 0.5 || final.Words[1].End != 0.9 {
+		t.Errorf("unexpected final %+v", final)
+	}
+}
+
+func TestStreamAudio_Errors(t *testing.T) {
+	if _, err := NewProvider(&Config{}, "not-aws-credentials"); err == nil {
+		t.Error("expected error for malformed credentials")
+	}
+
+	var received bytes.Buffer
+	srv := transcribeStandIn(t, &received)
+	defer srv.Close()
+
+	provider, _ := NewProvider(&Config{SampleRate: 16000, Language: "en-US", Region: "eu-west-1", BaseURL: srv.URL}, "AKIDEXAMPLE:wrong:session")
+	err := provider.StreamAudio(context.Background(), &providertest.ChunkReader{Data: []byte{0, 0}, Size: 2}, events.NewLog(nil))
+	if err == nil || !strings.Contains(err.Error(), "API error 403") {
+		t.Errorf("expected API error 403, got %v", err)
+	}
+
+	// Exceptions arrive as event-stream messages
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		msg := Message{Headers: []Header{
+			StringHeader(":message-type", "exception"),
+			StringHeader(":exception-type", "BadRequestException"),
+		}, Payload: []byte(`{"Message":"Your request timed out"}`)}
+		frame, _ := msg.Encode()
+		conn.WriteMessage(websocket.BinaryMessage, frame)
+	}))
+	defer failing.Close()
+	provider, _ = NewProvider(&Config{SampleRate: 16000, Language: "en-US", BaseURL: failing.URL}, "AKIDEXAMPLE:secret")
+	err = provider.StreamAudio(context.Background(), &providertest.ChunkReader{Data: []byte{0, 0}, Size: 2}, events.NewLog(nil))
+	if err == nil || !strings.Contains(err.Error(), "BadRequestException: Your request timed out") {
+		t.Errorf("expected the exception, got %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package awstranscribe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Message is a message of the application/vnd.amazon.eventstream framing
+type Message struct {
+	Headers []Header
+	Payload []byte
+}
+
+// Header is a message header; the value is a string, or the raw bytes of other types
+type Header struct {
+	Name  string
+	Type  byte
+	Value []byte
+}
+
+// Header value types
+const (
+	headerTrue      byte = 0
+	headerFalse     byte = 1
+	headerByte      byte = 2
+	headerShort     byte = 3
+	headerInt       byte = 4
+	headerLong      byte = 5
+	headerBytes     byte = 6
+	headerString    byte = 7
+	headerTimestamp byte = 8
+	headerUUID      byte = 9
+)
+
+const (
+	// preludeLength is the size of the total length, headers length and prelude CRC
+	preludeLength = 12
+	// maxMessageLength is the largest message the framing allows
+	maxMessageLength = 16 * 1024 * 1024
+)
+
+// StringHeader creates a string header
+func StringHeader(name, value string) Header {
+	return Header{Name: name, Type: headerString, Value: []byte(value)}
+}
+
+// Header returns the value of the named header, empty when it is missing
+func (m *Message) Header(name string) string {
+	for _, h := range m.Headers {
+		if h.Name == name {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Encode serializes the message: prelude, prelude CRC, headers, payload and message CRC
+func (m *Message) Encode() ([]byte, error) {
+	var headers bytes.Buffer
+	for _, h := range m.Headers {
+		if len(h.Name) > 255 {
+			return nil, fmt.Errorf("header name too long: %s", h.Name)
+		}
+		headers.WriteByte(byte(len(h.Name)))
+		headers.WriteString(h.Name)
+		headers.WriteByte(h.Type)
+		switch h.Type {
+		case headerTrue, headerFalse:
+		case headerBytes, headerString:
+			if len(h.Value) > 0xffff {
+				return nil, fmt.Errorf("header value too long: %s", h.Name)
+			}
+			binary.Write(&headers, binary.BigEndian, uint16(len(h.Value)))
+			headers.Write(h.Value)
+		default:
+			size, err := fixedValueLength(h.Type)
+			if err != nil {
+				return nil, err
+			}
+			if len(h.Value) != size {
+				return nil, fmt.Errorf("header %s: expected %d bytes, got %d", h.Name, size, len(h.Value))
+			}
+			headers.Write(h.Value)
+		}
+	}
+
+	total := preludeLength + headers.Len() + len(m.Payload) + 4
+	if total > maxMessageLength {
+		return nil, fmt.Errorf("message too long: %d bytes", total)
+	}
+	buf := make([]byte, 0, total)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(total))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(headers.Len()))
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+	buf = append(buf, headers.Bytes()...)
+	buf = append(buf, m.Payload...)
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+	return buf, nil
+}
+
+// ReadMessage reads and checks the next message of r
+func ReadMessage(r io.Reader) (*Message, error) {
+	prelude := make([]byte, preludeLength)
+	if _, err := io.ReadFull(r, prelude); err != nil {
+		return nil, err
+	}
+	total := binary.BigEndian.Uint32(prelude[0:4])
+	headersLength := binary.BigEndian.Uint32(prelude[4:8])
+	if crc32.ChecksumIEEE(prelude[:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+		return nil, errors.New("prelude checksum mismatch")
+	}
+	if total > maxMessageLength || total < preludeLength+4 || headersLength > total-preludeLength-4 {
+		return nil, fmt.Errorf("invalid message length %d with %d bytes of headers", total, headersLength)
+	}
+
+	buf := make([]byte, total)
+	copy(buf, prelude)
+	if _, err := io.ReadFull(r, buf[preludeLength:]); err != nil {
+		return nil, fmt.Errorf("truncated message: %w", err)
+	}
+	if crc32.ChecksumIEEE(buf[:total-4]) != binary.BigEndian.Uint32(buf[total-4:]) {
+		return nil, errors.New("message checksum mismatch")
+	}
+
+	headers, err := decodeHeaders(buf[preludeLength : preludeLength+headersLength])
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Headers: headers, Payload: buf[preludeLength+headersLength : total-4]}, nil
+}
+
+// DecodeMessage decodes a single message, such as a WebSocket frame
+func DecodeMessage(data []byte) (*Message, error) {
+	r := bytes.NewReader(data)
+	m, err := ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%d trailing bytes after the message", r.Len())
+	}
+	return m, nil
+}
+
+// decodeHeaders parses the header block of a message
+func decodeHeaders(data []byte) ([]Header, error) {
+	var headers []Header
+	for len(data) > 0 {
+		nameLength := int(data[0])
+		if len(data) < 1+nameLength+1 {
+			return nil, errors.New("truncated header")
+		}
+		h := Header{Name: string(data[1 : 1+nameLength]), Type: data[1+nameLength]}
+		data = data[2+nameLength:]
+
+		var size int
+		switch h.Type {
+		case headerTrue, headerFalse:
+		case headerBytes, headerString:
+			if len(data) < 2 {
+				return nil, errors.New("truncated header")
+			}
+			size = int(binary.BigEndian.Uint16(data))
+			data = data[2:]
+		default:
+			var err error
+			if size, err = fixedValueLength(h.Type); err != nil {
+				return nil, err
+			}
+		}
+		if len(data) < size {
+			return nil, errors.New("truncated header")
+		}
+		h.Value = data[:size]
+		data = data[size:]
+		headers = append(headers, h)
+	}
+	return headers, nil
+}
+
+// fixedValueLength returns the value size of fixed-size header types
+func fixedValueLength(t byte) (int, error) {
+	switch t {
+	case headerByte:
+		return 1, nil
+	case headerShort:
+		return 2, nil
+	case headerInt:
+		return 4, nil
+	case headerLong, headerTimestamp:
+		return 8, nil
+	case headerUUID:
+		return 16, nil
+	}
+	return 0, fmt.Errorf("unknown header type %d", t)
+}
@@ -0,0 +1,70 @@
+package awstranscribe
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"testing"
+)
+
+func TestEncode_EmptyMessage(t *testing.T) {
+	// The empty message of the event-stream test suite
+	got, err := (&Message{}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "000000100000000005c248eb7d98c8ff"; hex.EncodeToString(got) != want {
+		t.Errorf("Encode() = %x, want %s", got, want)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	msg := Message{
+		Headers: []Header{
+			StringHeader(":event-type", "AudioEvent"),
+			{Name: "flag", Type: headerTrue},
+			{Name: "count", Type: headerInt, Value: []byte{0, 0, 0, 7}},
+			{Name: "blob", Type: headerBytes, Value: []byte{1, 2, 3}},
+		},
+		Payload: []byte("audio"),
+	}
+	data, err := msg.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two messages back to back, as on an HTTP/2 stream
+	r := bytes.NewReader(append(append([]byte(nil), data...), data...))
+	for i := 0; i < 2; i++ {
+		got, err := ReadMessage(r)
+		if err != nil {
+			t.Fatalf("ReadMessage %d failed: %v", i, err)
+		}
+		if got.Header(":event-type") != "AudioEvent" || string(got.Payload) != "audio" || len(got.Headers) != 4 {
+			t.Errorf("unexpected message %+v", got)
+		}
+		if !bytes.Equal(got.Headers[2].Value, []byte{0, 0, 0, 7}) || got.Headers[1].Type != headerTrue {
+			t.Errorf("unexpected headers %+v", got.Headers)
+		}
+	}
+	if _, err := ReadMessage(r); err != io.EOF {
+		t.Errorf("expected io.EOF after the last message, got %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		corrupt func([]byte) []byte
+	}{
+		{"prelude checksum", func(b []byte) []byte { b[9] ^= 1; return b }},
+		{"message checksum", func(b []byte) []byte { b[len(b)-6] ^= 1; return b }},
+		{"truncated", func(b []byte) []byte { return b[:len(b)-1] }},
+		{"trailing bytes", func(b []byte) []byte { return append(b, 0) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeMessage(tt.corrupt(append([]byte(nil), data...))); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
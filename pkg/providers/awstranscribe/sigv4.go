@@ -0,0 +1,119 @@
+package awstranscribe
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Credentials are AWS access keys, with a session token for temporary credentials
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// ParseCredentials parses ACCESS_KEY_ID:SECRET_ACCESS_KEY[:SESSION_TOKEN]
+func ParseCredentials(s string) (Credentials, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return Credentials{}, fmt.Errorf("AWS credentials must be ACCESS_KEY_ID:SECRET_ACCESS_KEY[:SESSION_TOKEN]")
+	}
+	creds := Credentials{AccessKeyID: parts[0], SecretAccessKey: parts[1]}
+	if len(parts) == 3 {
+		creds.SessionToken = parts[2]
+	}
+	return creds, nil
+}
+
+const (
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	// emptyPayloadHash is the SHA-256 of an empty payload, signed by WebSocket presigned URLs
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// presign adds a Signature Version 4 query string signature to a GET request URL,
+// signing only the host header
+func presign(u *url.URL, creds Credentials, service, region string, now time.Time, expires time.Duration, payloadHash string) {
+	now = now.UTC()
+	date := now.Format("20060102")
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+
+	q := u.Query()
+	q.Set("X-Amz-Algorithm", signingAlgorithm)
+	q.Set("X-Amz-Credential", creds.AccessKeyID+"/"+scope)
+	q.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	q.Set("X-Amz-Expires", fmt.Sprint(int(expires.Seconds())))
+	q.Set("X-Amz-SignedHeaders", "host")
+	if creds.SessionToken != "" {
+		q.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	query := canonicalQuery(q)
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		"GET",
+		path,
+		query,
+		"host:" + u.Host + "\n",
+		"host",
+		payloadHash,
+	}, "\n")
+	stringToSign := strings.Join([]string{
+		signingAlgorithm,
+		now.Format("20060102T150405Z"),
+		scope,
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	u.RawQuery = query + "&X-Amz-Signature=" + signature
+}
+
+// canonicalQuery sorts the parameters and escapes them as SigV4 requires
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for key := range q {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return uriEncode(keys[i]) < uriEncode(keys[j]) })
+
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string(nil), q[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key)+"="+uriEncode(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode escapes everything but unreserved characters
+func uriEncode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,313 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/audio"
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers/internal/wsstream"
+	"github.com/gorilla/websocket"
+)
+
+// Config holds the provider configuration
+type Config struct {
+	SampleRate      int
+	Channels        int
+	Language        string
+	Interim         bool
+	ProfanityFilter bool
+	Region          string     // service region, e.g. westeurope
+	BaseURL         string     // custom endpoint, with or without its path, instead of the region
+	EndpointingMs   int        // segmentation silence in milliseconds, 0 keeps the default
+	Options         url.Values // passthrough query parameters, replacing those of the same name
+}
+
+// recognitionPath is the path of the real-time conversation endpoint
+const recognitionPath = "/speech/recognition/conversation/cognitiveservices/v1"
+
+// Provider implements the speech recognition provider using the Azure AI Speech
+// WebSocket protocol
+type Provider struct {
+	apiKey string
+	config *Config
+}
+
+// NewProvider creates a new Azure provider; apiKey is a resource key, or an
+// authorization token from the issueToken endpoint
+func NewProvider(config *Config, apiKey string) (*Provider, error) {
+	if config.Region == "" && config.BaseURL == "" {
+		return nil, fmt.Errorf("Azure Speech needs a region or an endpoint")
+	}
+	return &Provider{
+		apiKey: apiKey,
+		config: config,
+	}, nil
+}
+
+// phrase is the payload of a speech.phrase message, with times in 100ns ticks
+type phrase struct {
+	RecognitionStatus string `json:"RecognitionStatus"`
+	DisplayText       string `json:"DisplayText"`
+	NBest             []struct {
+		Display string `json:"Display"`
+		Words   []struct {
+			Word       string  `json:"Word"`
+			Offset     int64   `json:"Offset"`
+			Duration   int64   `json:"Duration"`
+			Confidence float64 `json:"Confidence"`
+		} `json:"Words"`
+	} `json:"NBest"`
+}
+
+// hypothesis is the payload of a speech.hypothesis message
+type hypothesis struct {
+	Text string `json:"Text"`
+}
+
+// streamURL builds the WebSocket URL with the session parameters
+func (p *Provider) streamURL() (string, error) {
+	base := fmt.Sprintf("wss://%s.stt.speech.microsoft.com", p.config.Region)
+	if p.config.BaseURL != "" {
+		base = strings.TrimRight(p.config.BaseURL, "/")
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %w", err)
+	}
+	if err := wsstream.NormalizeScheme(u); err != nil {
+		return "", err
+	}
+	if u.Path == "" {
+		u.Path = recognitionPath
+	}
+
+	profanity := "raw"
+	if p.config.ProfanityFilter {
+		profanity = "masked"
+	}
+	q := u.Query()
+	q.Set("language", p.config.Language)
+	q.Set("format", "detailed")
+	q.Set("wordLevelTimestamps", "true")
+	q.Set("profanity", profanity)
+	if p.config.EndpointingMs > 0 {
+		q.Set("segmentationSilenceTimeoutMs", strconv.Itoa(p.config.EndpointingMs))
+	}
+	for key, values := range p.config.Options {
+		q[key] = values
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// authHeader returns the header authenticating the connection: tokens are JWTs
+func (p *Provider) authHeader() http.Header {
+	header := http.Header{}
+	if strings.Count(p.apiKey, ".") == 2 {
+		header.Set("Authorization", "Bearer "+p.apiKey)
+	} else {
+		header.Set("Ocp-Apim-Subscription-Key", p.apiKey)
+	}
+	header.Set("X-ConnectionId", newID())
+	return header
+}
+
+// StreamAudio sends the audio frame by frame as it is paced by the reader and
+// emits hypotheses as interim events and phrases as final events until the turn ends
+func (p *Provider) StreamAudio(ctx context.Context, audioReader io.Reader, sink events.Sink) error {
+	wsURL, err := p.streamURL()
+	if err != nil {
+		return err
+	}
+
+	// The speech configuration is sent before the audio
+	config, _ := json.Marshal(map[string]any{"context": map[string]any{
+		"system": map[string]string{"name": "speech_latency", "version": "1.0.0", "build": "Go", "lang": "Go"},
+		"os":     map[string]string{"platform": "Go", "name": "speech_latency", "version": "1.0.0"},
+	}})
+	conn, err := wsstream.Dial(ctx, sink, wsURL, p.authHeader(), textMessage("speech.config", "", "application/json", config))
+	if err != nil {
+		return events.EmitError(sink, err)
+	}
+	defer conn.Close()
+	sink.Emit(events.Event{Type: events.Opened})
+	defer sink.Emit(events.Event{Type: events.Closed})
+
+	sendErr := make(chan error, 1)
+	go func() {
+		sendErr <- p.sendAudio(conn, audioReader)
+	}()
+
+	for {
+		msgType, data, err := conn.Receive()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return events.EmitError(sink, err)
+		}
+		receivedAt := time.Now()
+		if msgType != websocket.TextMessage {
+			continue
+		}
+
+		path, body, err := parseMessage(data)
+		if err != nil {
+			return events.EmitError(sink, fmt.Errorf("failed to decode message: %w", err))
+		}
+		switch strings.ToLower(path) {
+		case "speech.hypothesis":
+			if !p.config.Interim {
+				continue
+			}
+			var h hypothesis
+			if err := json.Unmarshal(body, &h); err != nil {
+				return events.EmitError(sink, fmt.Errorf("failed to decode hypothesis: %w", err))
+			}
+			// Hypotheses carry no word offsets, so their words are untimed
+			fields := strings.Fields(h.Text)
+			words := make([]events.Word, len(fields))
+			for i, f := range fields {
+				words[i] = events.Word{Word: f}
+			}
+			sink.Emit(events.Event{Type: events.Interim, ReceivedAt: receivedAt, Transcript: h.Text, Words: words})
+		case "speech.phrase":
+			var ph phrase
+			if err := json.Unmarshal(body, &ph); err != nil {
+				return events.EmitError(sink, fmt.Errorf("failed to decode phrase: %w", err))
+			}
+			emitPhrase(ph, receivedAt, sink)
+		case "turn.end":
+			// The turn ends once the audio has been processed
+			if err := <-sendErr; err != nil {
+				return events.EmitError(sink, err)
+			}
+			return nil
+		}
+	}
+
+	if err := <-sendErr; err != nil {
+		return events.EmitError(sink, err)
+	}
+	return nil
+}
+
+// emitPhrase reports a recognized phrase as a final event; silence and unrecognized
+// audio end phrases without text
+func emitPhrase(ph phrase, receivedAt time.Time, sink events.Sink) {
+	switch ph.RecognitionStatus {
+	case "Success":
+	case "NoMatch", "InitialSilenceTimeout", "BabbleTimeout", "EndOfDictation":
+		return
+	default:
+		sink.Emit(events.Event{Type: events.Error, ReceivedAt: receivedAt, Err: fmt.Errorf("provider error: recognition status %s", ph.RecognitionStatus)})
+		return
+	}
+
+	transcript := ph.DisplayText
+	var words []events.Word
+	if len(ph.NBest) > 0 {
+		best := ph.NBest[0]
+		if best.Display != "" {
+			transcript = best.Display
+		}
+		words = make([]events.Word, len(best.Words))
+		for i, w := range best.Words {
+			words[i] = events.Word{Word: w.Word, Start: ticks(w.Offset), End: ticks(w.Offset + w.Duration), Confidence: w.Confidence}
+		}
+	}
+	sink.Emit(events.Event{Type: events.Final, ReceivedAt: receivedAt, Transcript: transcript, Words: words})
+}
+
+// sendAudio sends a WAV header and each chunk as it is paced by the reader in audio
+// frames, then the empty frame ending the audio
+func (p *Provider) sendAudio(conn *wsstream.Conn, audioReader io.Reader) error {
+	requestID := newID()
+	header := audio.WAVHeader(p.config.SampleRate, p.config.Channels, 2, 0)
+	if err := conn.SendBinary(audioFrame(requestID, header)); err != nil {
+		return err
+	}
+
+	err := wsstream.SendChunks(audioReader, func(chunk []byte) error {
+		return conn.SendBinary(audioFrame(requestID, chunk))
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := conn.WriteMessage(websocket.BinaryMessage, audioFrame(requestID, nil)); err != nil {
+		return fmt.Errorf("failed to end audio: %w", err)
+	}
+	return nil
+}
+
+// messageHeaders writes the headers of a message, each ended by CRLF
+func messageHeaders(path, requestID, contentType string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Path: %s\r\n", path)
+	if requestID != "" {
+		fmt.Fprintf(&b, "X-RequestId: %s\r\n", requestID)
+	}
+	fmt.Fprintf(&b, "X-Timestamp: %s\r\n", time.Now().UTC().Format("2006-01-02T15:04:05.000Z"))
+	if contentType != "" {
+		fmt.Fprintf(&b, "Content-Type: %s\r\n", contentType)
+	}
+	return b.Bytes()
+}
+
+// textMessage builds a text message: headers, a blank line and the body
+func textMessage(path, requestID, contentType string, body []byte) []byte {
+	msg := messageHeaders(path, requestID, contentType)
+	msg = append(msg, "\r\n"...)
+	return append(msg, body...)
+}
+
+// audioFrame builds a binary audio message: the length of the headers, the headers
+// and the audio
+func audioFrame(requestID string, chunk []byte) []byte {
+	headers := messageHeaders("audio", requestID, "audio/x-wav")
+	frame := binary.BigEndian.AppendUint16(nil, uint16(len(headers)))
+	frame = append(frame, headers...)
+	return append(frame, chunk...)
+}
+
+// parseMessage splits a text message into its path and body
+func parseMessage(data []byte) (string, []byte, error) {
+	head, body, ok := bytes.Cut(data, []byte("\r\n\r\n"))
+	if !ok {
+		return "", nil, errors.New("message without headers")
+	}
+	for _, line := range strings.Split(string(head), "\r\n") {
+		name, value, _ := strings.Cut(line, ":")
+		if textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name)) == "Path" {
+			return strings.TrimSpace(value), body, nil
+		}
+	}
+	return "", nil, errors.New("message without path")
+}
+
+// newID returns a random request or connection identifier
+func newID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// ticks converts 100-nanosecond ticks to seconds
+func ticks(t int64) float64 {
+	return float64(t) / 1e7
+}
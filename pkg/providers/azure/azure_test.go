@@ -0,0 +1,169 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers/internal/providertest"
+	"github.com/gorilla/websocket"
+)
+
+// speechStandIn emulates the Speech service: a hypothesis per audio frame, then
+// the phrase and the end of the turn once the empty frame ends the audio
+func speechStandIn(t *testing.T, received *bytes.Buffer) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != recognitionPath {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Ocp-Apim-Subscription-Key") != "test-key" && r.Header.Get("Authorization") != "Bearer a.b.c" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("language") != "en-US" || q.Get("format") != "detailed" || q.Get("segmentationSilenceTimeoutMs") != "500" {
+			http.Error(w, "bad parameters", http.StatusBadRequest)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+		send := func(path, body string) {
+			conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("X-RequestId: 1\r\nPath: %s\r\nContent-Type: application/json\r\n\r\n%s", path, body)))
+		}
+
+		_, config, err := conn.ReadMessage()
+		if err != nil || !strings.HasPrefix(string(config), "Path: speech.config\r\n") {
+			t.Errorf("expected speech.config first, got %q (%v)", config, err)
+			return
+		}
+		send("turn.start", `{"context":{"serviceTag":"test"}}`)
+
+		var text []string
+		for frame := 0; ; frame++ {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				t.Errorf("read failed: %v", err)
+				return
+			}
+			headersLength := binary.BigEndian.Uint16(data)
+			headers, chunk := string(data[2:2+headersLength]), data[2+headersLength:]
+			if !strings.HasPrefix(headers, "Path: audio\r\n") {
+				t.Errorf("unexpected audio headers %q", headers)
+			}
+			if frame == 0 {
+				if !bytes.HasPrefix(chunk, []byte("RIFF")) {
+					t.Error("expected a WAV header in the first audio frame")
+				}
+				continue
+			}
+			if len(chunk) == 0 {
+				send("speech.phrase", `{"RecognitionStatus":"Success","DisplayText":"Hello, hello.","Offset":1000000,"Duration":9000000,`+
+					`"NBest":[{"Confidence":0.9,"Display":"Hello, hello.","Words":[`+
+					`{"Word":"hello","Offset":1000000,"Duration":3000000,"Confidence":0.9},`+
+					`{"Word":"hello","Offset":5000000,"Duration":4000000,"Confidence":0.8}]}]}`)
+				send("speech.phrase", `{"RecognitionStatus":"EndOfDictation","Offset":10000000,"Duration":0}`)
+				send("turn.end", "{}")
+				return
+			}
+
+			received.Write(chunk)
+			text = append(text, "hello")
+			send("speech.hypothesis", fmt.Sprintf(`{"Text":%q,"Offset":1000000,"Duration":3000000}`, strings.Join(text, " ")))
+		}
+	}))
+}
+
+func TestStreamAudio(t *testing.T) {
+	for _, key := range []string{"test-key", "a.b.c"} {
+		t.Run(key, func(t *testing.T) {
+			var received bytes.Buffer
+			srv := speechStandIn(t, &received)
+			defer srv.Close()
+
+			provider, err := NewProvider(&Config{
+				SampleRate:    16000,
+				Channels:      1,
+				Language:      "en-US",
+				Interim:       true,
+				BaseURL:       srv.URL,
+				EndpointingMs: 500,
+			}, key)
+			if err != nil {
+				t.Fatalf("NewProvider failed: %v", err)
+			}
+
+			audio := bytes.Repeat([]byte{1, 2}, 1000)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			log := events.NewLog(nil)
+			if err := provider.StreamAudio(ctx, &providertest.ChunkReader{Data: audio, Size: 1000}, log); err != nil {
+				t.Fatalf("StreamAudio failed: %v", err)
+			}
+			if !bytes.Equal(received.Bytes(), audio) {
+				t.Errorf("server received %d bytes, want %d", received.Len(), len(audio))
+			}
+
+			got := log.Events()
+			providertest.CheckTypes(t, got, []events.Type{events.Opened, events.Interim, events.Interim, events.Final, events.Closed})
+			if got[2].Transcript != "hello hello" || len(got[2].Words) != 2 {
+				t.Errorf("unexpected hypothesis %+v", got[2])
+			}
+			final := got[3]
+			if final.Transcript != "Hello, hello." || len(final.Words) != 2 || final.Words[1].Start != 0.5 || final.Words[1].End != 0.9 {
+				t.Errorf("unexpected final %+v", final)
+			}
+		})
+	}
+}
+
+func TestStreamAudio_Errors(t *testing.T) {
+	if _, err := NewProvider(&Config{}, "test-key"); err == nil {
+		t.Error("expected error without a region or an endpoint")
+	}
+
+	provider, _ := NewProvider(&Config{Region: "westeurope", Language: "en-US"}, "test-key")
+	if got, _ := provider.streamURL(); !strings.HasPrefix(got, "wss://westeurope.stt.speech.microsoft.com"+recognitionPath+"?") {
+		t.Errorf("unexpected regional URL %s", got)
+	}
+
+	var received bytes.Buffer
+	srv := speechStandIn(t, &received)
+	defer srv.Close()
+
+	provider, _ = NewProvider(&Config{SampleRate: 16000, Channels: 1, Language: "en-US", BaseURL: srv.URL}, "wrong-key")
+	err := provider.StreamAudio(context.Background(), &providertest.ChunkReader{Data: []byte{0, 0}, Size: 2}, events.NewLog(nil))
+	if err == nil || !strings.Contains(err.Error(), "API error 401") {
+		t.Errorf("expected API error 401, got %v", err)
+	}
+
+	// The service closes the connection with the reason of a failure
+	closing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "Quota exceeded"))
+	}))
+	defer closing.Close()
+	provider, _ = NewProvider(&Config{SampleRate: 16000, Channels: 1, Language: "en-US", BaseURL: closing.URL}, "test-key")
+	err = provider.StreamAudio(context.Background(), &providertest.ChunkReader{Data: []byte{0, 0}, Size: 2}, events.NewLog(nil))
+	if err == nil || !strings.Contains(err.Error(), "code 1011: Quota exceeded") {
+		t.Errorf("expected the close reason, got %v", err)
+	}
+}
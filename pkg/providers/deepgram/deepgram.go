@@ -7,7 +7,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
 )
 
 // Config holds the provider configuration
@@ -18,13 +22,31 @@ type Config struct {
 	Interim     bool
 	Punctuate   bool
 	SmartFormat bool
+	Live        bool   // stream over the live WebSocket API instead of the REST upload
+	BaseURL     string // defaults to DefaultBaseURL
+	Model       string // defaults to DefaultModel
+	Encoding    string // encoding of the live audio, defaults to linear16
+
+	Version         string     // model version, empty selects the latest
+	Tier            string     // model tier of older models, e.g. enhanced
+	EndpointingMs   int        // silence ending a live utterance, 0 keeps the default, negative disables
+	UtteranceEndMs  int        // gap between words sending an UtteranceEnd on the live API, 0 disables
+	Keyterms        []string   // terms to boost on nova-3
+	Keywords        []string   // keywords to boost on older models, as word:intensifier
+	Diarize         bool       // label the speaker of every word
+	Multichannel    bool       // transcribe each channel independently
+	Numerals        bool       // write numbers as digits
+	ProfanityFilter bool       // mask profanity
+	Redact          []string   // entities to redact, e.g. pci, ssn, numbers
+	Options         url.Values // passthrough request parameters, replacing those of the same name
 }
 
-// Result contains the benchmark results
-type Result struct {
-	Latency    float64 // in milliseconds
-	Throughput float64 // words per second
-}
+const (
+	// DefaultBaseURL is the Deepgram API endpoint used when Config.BaseURL is empty
+	DefaultBaseURL = "https://api.deepgram.com"
+	// DefaultModel is the Deepgram model used when Config.Model is empty
+	DefaultModel = "nova-3"
+)
 
 // Provider implements the speech recognition provider using Deepgram
 type Provider struct {
@@ -58,10 +80,32 @@ type DeepgramResponse struct {
 	} `json:"results"`
 }
 
-// StreamAudio processes an audio stream and measures latency
-func (p *Provider) StreamAudio(ctx context.Context, audioReader io.Reader) (*Result, error) {
-	startTime := time.Now()
-	
+// baseURL returns the configured API endpoint without a trailing slash
+func (p *Provider) baseURL() string {
+	if p.config.BaseURL == "" {
+		return DefaultBaseURL
+	}
+	return strings.TrimRight(p.config.BaseURL, "/")
+}
+
+// model returns the configured model
+func (p *Provider) model() string {
+	if p.config.Model == "" {
+		return DefaultModel
+	}
+	return p.config.Model
+}
+
+// StreamAudio sends an audio stream to Deepgram and emits transcript events to sink
+func (p *Provider) StreamAudio(ctx context.Context, audioReader io.Reader, sink events.Sink) error {
+	if p.config.Live {
+		return p.streamLive(ctx, audioReader, sink)
+	}
+	return p.streamREST(ctx, audioReader, sink)
+}
+
+// streamREST uploads the whole audio file to the pre-recorded API
+func (p *Provider) streamREST(ctx context.Context, audioReader io.Reader, sink events.Sink) error {
 	// For REST API, we need the complete audio data
 	// If it's a chunked reader, we need to read from the original file
 	var audioData []byte
@@ -75,14 +119,14 @@ func (p *Provider) StreamAudio(ctx context.Context, audioReader io.Reader) (*Res
 	}
 	
 	if err != nil {
-		return nil, fmt.Errorf("failed to read audio data: %w", err)
+		return fmt.Errorf("failed to read audio data: %w", err)
 	}
 
 	// Create HTTP request
-	url := "https://api.deepgram.com/v1/listen"
+	url := p.baseURL() + "/v1/listen"
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(audioData))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set headers
@@ -90,59 +134,52 @@ func (p *Provider) StreamAudio(ctx context.Context, audioReader io.Reader) (*Res
 	req.Header.Set("Content-Type", "audio/wav")
 	
 	// Add query parameters
-	q := req.URL.Query()
-	q.Add("model", "nova-3")
-	q.Add("language", p.config.Language)
-	if p.config.Punctuate {
-		q.Add("punctuate", "true")
-	}
-	if p.config.SmartFormat {
-		q.Add("smart_format", "true")
-	}
-	q.Add("words", "true") // Enable word timestamps
-	q.Add("interim_results", "true") // Enable word timestamps
-	req.URL.RawQuery = q.Encode()
+	req.URL.RawQuery = p.queryParams(false).Encode()
 
 	// Send request
+	sink.Emit(events.Event{Type: events.Opened})
+	defer sink.Emit(events.Event{Type: events.Closed})
+
 	client := &http.Client{Timeout: 30 * time.Second}
+	events.RecordRequest(sink, events.Request{URL: req.URL.String(), Header: req.Header})
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return events.EmitError(sink, fmt.Errorf("failed to send request: %w", err))
 	}
 	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return events.EmitError(sink, fmt.Errorf("failed to read response: %w", err))
+	}
+	events.RecordMessage(sink, body)
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(body))
+		return events.EmitError(sink, fmt.Errorf("API error %d: %s", resp.StatusCode, string(body)))
 	}
 
 	// Parse response
 	var dgResp DeepgramResponse
-	if err := json.NewDecoder(resp.Body).Decode(&dgResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := json.Unmarshal(body, &dgResp); err != nil {
+		return events.EmitError(sink, fmt.Errorf("failed to decode response: %w", err))
 	}
-
-	// Calculate latency (time to first response)
-	latency := time.Since(startTime)
-
-	// Calculate throughput
-	var firstWord string
-	var wordCount int
-	var isFinal bool
-	if len(dgResp.Results.Channels) > 0 && len(dgResp.Results.Channels[0].Alternatives) > 0 {
-		firstWord = dgResp.Results.Channels[0].Alternatives[0].Words[0].Word
-		wordCount = len(dgResp.Results.Channels[0].Alternatives[0].Words)
-		isFinal = dgResp.Results.IsFinal
+	receivedAt := time.Now()
+
+	// Pre-recorded results are final by construction
+	for _, channel := range dgResp.Results.Channels {
+		if len(channel.Alternatives) == 0 {
+			continue
+		}
+		alt := channel.Alternatives[0]
+		words := make([]events.Word, len(alt.Words))
+		for i, w := range alt.Words {
+			words[i] = events.Word{Word: w.Word, Start: w.Start, End: w.End, Confidence: w.Confidence}
+		}
+		sink.Emit(events.Event{
+			Type:       events.Final,
+			ReceivedAt: receivedAt,
+			Transcript: alt.Transcript,
+			Words:      words,
+		})
 	}
-
-	throughput := float64(wordCount) / latency.Seconds()
-
-	fmt.Printf("Transcription: %s\n", dgResp.Results.Channels[0].Alternatives[0].Transcript)
-	fmt.Printf("first Word: %s\n", firstWord)
-	fmt.Printf("first Word is_final: %t\n", isFinal)
-
-	return &Result{
-		Latency:    float64(latency.Nanoseconds()) / 1e6, // Convert to milliseconds
-		Throughput: throughput,
-	}, nil
-} 
\ No newline at end of file
+	return nil
+}
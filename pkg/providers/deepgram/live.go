@@ -0,0 +1,143 @@
+package deepgram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers/internal/wsstream"
+	"github.com/gorilla/websocket"
+)
+
+// liveResponse represents a message received on the live WebSocket API
+type liveResponse struct {
+	Type        string  `json:"type"`
+	Start       float64 `json:"start"`
+	Duration    float64 `json:"duration"`
+	IsFinal     bool    `json:"is_final"`
+	SpeechFinal bool    `json:"speech_final"`
+	Channel     struct {
+		Alternatives []struct {
+			Transcript string  `json:"transcript"`
+			Confidence float64 `json:"confidence"`
+			Words      []struct {
+				Word       string  `json:"word"`
+				Start      float64 `json:"start"`
+				End        float64 `json:"end"`
+				Confidence float64 `json:"confidence"`
+			} `json:"words"`
+		} `json:"alternatives"`
+	} `json:"channel"`
+	LastWordEnd float64 `json:"last_word_end"`
+}
+
+// liveURL builds the WebSocket URL of the live API with the streaming parameters
+func (p *Provider) liveURL() (string, error) {
+	u, err := url.Parse(p.baseURL() + "/v1/listen")
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %w", err)
+	}
+	if err := wsstream.NormalizeScheme(u); err != nil {
+		return "", err
+	}
+
+	// The paced reader yields raw samples without the WAV header, so the encoding must be explicit
+	encoding := p.config.Encoding
+	if encoding == "" {
+		encoding = "linear16"
+	}
+	q := p.queryParams(true)
+	q.Set("encoding", encoding)
+	q.Set("sample_rate", strconv.Itoa(p.config.SampleRate))
+	q.Set("channels", strconv.Itoa(p.config.Channels))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// streamLive sends the audio chunk by chunk over the live WebSocket API
+func (p *Provider) streamLive(ctx context.Context, audioReader io.Reader, sink events.Sink) error {
+	wsURL, err := p.liveURL()
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", "Token "+p.apiKey)
+
+	conn, err := wsstream.Dial(ctx, sink, wsURL, header, nil)
+	if err != nil {
+		return events.EmitError(sink, err)
+	}
+	defer conn.Close()
+	sink.Emit(events.Event{Type: events.Opened})
+	defer sink.Emit(events.Event{Type: events.Closed})
+
+	sendErr := make(chan error, 1)
+	go func() {
+		sendErr <- sendLiveAudio(conn, audioReader)
+	}()
+
+	for {
+		_, data, err := conn.Receive()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return events.EmitError(sink, err)
+		}
+		receivedAt := time.Now()
+
+		var msg liveResponse
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return events.EmitError(sink, fmt.Errorf("failed to decode message: %w", err))
+		}
+		switch msg.Type {
+		case "Results":
+			if len(msg.Channel.Alternatives) == 0 {
+				continue
+			}
+			alt := msg.Channel.Alternatives[0]
+			words := make([]events.Word, len(alt.Words))
+			for i, w := range alt.Words {
+				words[i] = events.Word{Word: w.Word, Start: w.Start, End: w.End, Confidence: w.Confidence}
+			}
+			eventType := events.Interim
+			if msg.IsFinal {
+				eventType = events.Final
+			}
+			sink.Emit(events.Event{
+				Type:       eventType,
+				ReceivedAt: receivedAt,
+				Transcript: alt.Transcript,
+				Words:      words,
+			})
+		case "UtteranceEnd":
+			sink.Emit(events.Event{Type: events.UtteranceEnd, ReceivedAt: receivedAt})
+		case "Error":
+			sink.Emit(events.Event{Type: events.Error, ReceivedAt: receivedAt, Err: fmt.Errorf("provider error: %s", data)})
+		}
+	}
+
+	if err := <-sendErr; err != nil {
+		return events.EmitError(sink, err)
+	}
+	return nil
+}
+
+// sendLiveAudio writes each chunk as it is paced by the reader, then asks Deepgram to flush
+func sendLiveAudio(conn *wsstream.Conn, audioReader io.Reader) error {
+	if err := wsstream.SendChunks(audioReader, conn.SendBinary); err != nil {
+		return err
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"CloseStream"}`)); err != nil {
+		return fmt.Errorf("failed to close stream: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package deepgram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers/internal/providertest"
+	"github.com/gorilla/websocket"
+)
+
+// liveStandIn emulates the live API: it echoes one interim and one final result per audio chunk
+func liveStandIn(t *testing.T, received *bytes.Buffer) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/listen" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Token test-key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("encoding") != "linear16" || r.URL.Query().Get("sample_rate") != "16000" {
+			http.Error(w, "bad encoding", http.StatusBadRequest)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		chunk := 0
+		for {
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				t.Errorf("read failed: %v", err)
+				return
+			}
+			if msgType == websocket.TextMessage {
+				if !strings.Contains(string(data), "CloseStream") {
+					t.Errorf("unexpected control message: %s", data)
+				}
+				conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"Metadata"}`))
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+
+			received.Write(data)
+			chunk++
+			word := map[string]any{"word": "word", "start": float64(chunk - 1), "end": float64(chunk)}
+			for _, final := range []bool{false, true} {
+				msg := map[string]any{
+					"type":     "Results",
+					"is_final": final,
+					"channel": map[string]any{
+						"alternatives": []map[string]any{{"transcript": "word", "words": []any{word}}},
+					},
+				}
+				payload, _ := json.Marshal(msg)
+				conn.WriteMessage(websocket.TextMessage, payload)
+			}
+		}
+	}))
+}
+
+func TestStreamLive(t *testing.T) {
+	var received bytes.Buffer
+	srv := liveStandIn(t, &received)
+	defer srv.Close()
+
+	provider, _ := NewProvider(&Config{
+		SampleRate: 16000,
+		Channels:   1,
+		Language:   "en-US",
+		Interim:    true,
+		Live:       true,
+		BaseURL:    srv.URL,
+	}, "test-key")
+
+	audio := bytes.Repeat([]byte{1, 2}, 1500)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	log := events.NewLog(nil)
+	if err := provider.StreamAudio(ctx, &providertest.ChunkReader{Data: audio, Size: 1000}, log); err != nil {
+		t.Fatalf("StreamAudio failed: %v", err)
+	}
+
+	if !bytes.Equal(received.Bytes(), audio) {
+		t.Errorf("server received %d bytes, want %d", received.Len(), len(audio))
+	}
+
+	// Opened, then an interim and a final per chunk, then Closed
+	got := log.Events()
+	if len(got) != 8 {
+		t.Fatalf("expected 8 events, got %d", len(got))
+	}
+	if got[0].Type != events.Opened || got[7].Type != events.Closed {
+		t.Errorf("expected opened/closed around results, got %s/%s", got[0].Type, got[7].Type)
+	}
+	for i, e := range got[1:7] {
+		want := events.Interim
+		if i%2 == 1 {
+			want = events.Final
+		}
+		if e.Type != want {
+			t.Errorf("event %d: type = %s, want %s", i+1, e.Type, want)
+		}
+		if e.ReceivedAt.Before(got[i].ReceivedAt) {
+			t.Errorf("event %d received before event %d", i+1, i)
+		}
+		if len(e.Words) != 1 || e.Words[0].End != float64(i/2+1) {
+			t.Errorf("event %d: unexpected words %+v", i+1, e.Words)
+		}
+	}
+}
+
+func TestStreamLive_Unauthorized(t *testing.T) {
+	var received bytes.Buffer
+	srv := liveStandIn(t, &received)
+	defer srv.Close()
+
+	provider, _ := NewProvider(&Config{SampleRate: 16000, Channels: 1, Live: true, BaseURL: srv.URL}, "wrong-key")
+
+	log := events.NewLog(nil)
+	err := provider.StreamAudio(context.Background(), &providertest.ChunkReader{Data: []byte{0, 0}, Size: 2}, log)
+	if err == nil || !strings.Contains(err.Error(), "API error 401") {
+		t.Errorf("expected API error 401, got %v", err)
+	}
+	if got := log.Events(); len(got) != 1 || got[0].Type != events.Error {
+		t.Errorf("expected a single error event, got %+v", got)
+	}
+}
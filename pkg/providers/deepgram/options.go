@@ -0,0 +1,70 @@
+package deepgram
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// queryParams builds the request parameters shared by the pre-recorded and live APIs;
+// interim results, endpointing and utterance ends only exist on the live API
+func (p *Provider) queryParams(live bool) url.Values {
+	q := url.Values{}
+	q.Set("model", p.model())
+	if p.config.Language != "" {
+		q.Set("language", p.config.Language)
+	}
+	if p.config.Version != "" {
+		q.Set("version", p.config.Version)
+	}
+	if p.config.Tier != "" {
+		q.Set("tier", p.config.Tier)
+	}
+	if p.config.Punctuate {
+		q.Set("punctuate", "true")
+	}
+	if p.config.SmartFormat {
+		q.Set("smart_format", "true")
+	}
+	if p.config.Diarize {
+		q.Set("diarize", "true")
+	}
+	if p.config.Multichannel {
+		q.Set("multichannel", "true")
+	}
+	if p.config.Numerals {
+		q.Set("numerals", "true")
+	}
+	if p.config.ProfanityFilter {
+		q.Set("profanity_filter", "true")
+	}
+	for _, keyterm := range p.config.Keyterms {
+		q.Add("keyterm", keyterm)
+	}
+	for _, keyword := range p.config.Keywords {
+		q.Add("keywords", keyword)
+	}
+	for _, redact := range p.config.Redact {
+		q.Add("redact", redact)
+	}
+
+	if live {
+		q.Set("interim_results", strconv.FormatBool(p.config.Interim))
+		switch {
+		case p.config.EndpointingMs < 0:
+			q.Set("endpointing", "false")
+		case p.config.EndpointingMs > 0:
+			q.Set("endpointing", strconv.Itoa(p.config.EndpointingMs))
+		}
+		if p.config.UtteranceEndMs > 0 {
+			q.Set("utterance_end_ms", strconv.Itoa(p.config.UtteranceEndMs))
+		}
+	} else {
+		q.Set("words", "true")
+	}
+
+	// Passthrough options replace the parameters of the same name
+	for key, values := range p.config.Options {
+		q[key] = append([]string(nil), values...)
+	}
+	return q
+}
@@ -0,0 +1,46 @@
+package deepgram
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestQueryParams(t *testing.T) {
+	provider, _ := NewProvider(&Config{
+		Language:      "en-US",
+		Interim:       false,
+		Punctuate:     true,
+		Version:       "2024-01-01",
+		EndpointingMs: -1,
+		Keyterms:      []string{"Deepgram", "nova three"},
+		Redact:        []string{"pci", "ssn"},
+		Diarize:       true,
+		Options:       url.Values{"model": {"nova-2"}, "filler_words": {"true"}},
+	}, "test-key")
+
+	rest := provider.queryParams(false)
+	want := url.Values{
+		"model":        {"nova-2"},
+		"language":     {"en-US"},
+		"version":      {"2024-01-01"},
+		"punctuate":    {"true"},
+		"diarize":      {"true"},
+		"keyterm":      {"Deepgram", "nova three"},
+		"redact":       {"pci", "ssn"},
+		"words":        {"true"},
+		"filler_words": {"true"},
+	}
+	if !reflect.DeepEqual(rest, want) {
+		t.Errorf("pre-recorded parameters = %v, want %v", rest, want)
+	}
+
+	// Interim results and endpointing only exist on the live API, and follow the config
+	live := provider.queryParams(true)
+	if live.Get("interim_results") != "false" || live.Get("endpointing") != "false" || live.Has("words") {
+		t.Errorf("unexpected live parameters %v", live)
+	}
+	if live.Has("utterance_end_ms") {
+		t.Errorf("expected no utterance end by default, got %v", live)
+	}
+}
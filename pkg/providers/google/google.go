@@ -0,0 +1,340 @@
+package google
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/speech/apiv2/speechpb"
+	"github.com/elishowk/speech_latency/pkg/events"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Config holds the provider configuration
+type Config struct {
+	SampleRate      int
+	Channels        int
+	Language        string
+	Interim         bool
+	Punctuate       bool
+	ProfanityFilter bool
+	Encoding        string   // encoding of the audio, linear16, mulaw or alaw, defaults to linear16
+	Model           string   // defaults to DefaultModel
+	Phrases         []string // phrases to boost
+	Project         string   // defaults to the project of the service account
+	Location        string   // defaults to DefaultLocation
+	Recognizer      string   // defaults to "_", the recognizer configured inline
+	Endpoint        string   // gRPC host:port, defaults to the endpoint of the location
+	Insecure        bool     // plaintext transport, for local fakes
+	Credentials     string   // path to a service-account JSON key, instead of an access token
+	MinStability    float32  // interim results less stable than this are not reported
+}
+
+const (
+	// DefaultModel is the recognition model used when Config.Model is empty
+	DefaultModel = "long"
+	// DefaultLocation is the location used when Config.Location is empty
+	DefaultLocation = "global"
+)
+
+// maxAudioRequest is the largest audio payload of a single streaming request
+const maxAudioRequest = 25 * 1024
+
+// Provider implements the speech recognition provider using Google Cloud
+// Speech-to-Text v2 StreamingRecognize
+type Provider struct {
+	accessToken string
+	config      *Config
+	account     *serviceAccount
+}
+
+// NewProvider creates a new Google provider; apiKey is an OAuth access token,
+// unused when the config names a service-account key
+func NewProvider(config *Config, apiKey string) (*Provider, error) {
+	p := &Provider{accessToken: apiKey, config: config}
+	if config.Credentials != "" {
+		account, err := loadServiceAccount(config.Credentials)
+		if err != nil {
+			return nil, err
+		}
+		p.account = account
+	}
+	if p.project() == "" {
+		return nil, fmt.Errorf("a Google Cloud project is required")
+	}
+	return p, nil
+}
+
+// project returns the configured project, or the project of the service account
+func (p *Provider) project() string {
+	if p.config.Project == "" && p.account != nil {
+		return p.account.ProjectID
+	}
+	return p.config.Project
+}
+
+// location returns the configured location
+func (p *Provider) location() string {
+	if p.config.Location == "" {
+		return DefaultLocation
+	}
+	return p.config.Location
+}
+
+// recognizer returns the full resource name of the recognizer
+func (p *Provider) recognizer() string {
+	recognizer := p.config.Recognizer
+	if recognizer == "" {
+		recognizer = "_"
+	}
+	return fmt.Sprintf("projects/%s/locations/%s/recognizers/%s", p.project(), p.location(), recognizer)
+}
+
+// endpoint returns the gRPC address: regional locations have their own endpoint
+func (p *Provider) endpoint() string {
+	endpoint := p.config.Endpoint
+	if endpoint == "" {
+		endpoint = "speech.googleapis.com"
+		if location := p.location(); location != DefaultLocation {
+			endpoint = location + "-" + endpoint
+		}
+	}
+	endpoint = strings.TrimPrefix(strings.TrimPrefix(endpoint, "https://"), "http://")
+	endpoint = strings.TrimRight(endpoint, "/")
+	if _, _, err := net.SplitHostPort(endpoint); err != nil {
+		endpoint = net.JoinHostPort(endpoint, "443")
+	}
+	return endpoint
+}
+
+// dial connects to the endpoint with the configured transport and credentials
+func (p *Provider) dial() (*grpc.ClientConn, error) {
+	transport := credentials.NewTLS(nil)
+	if p.config.Insecure {
+		transport = insecure.NewCredentials()
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(transport)}
+	if p.account != nil || p.accessToken != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(&bearerCredentials{
+			accessToken: p.accessToken,
+			account:     p.account,
+			secure:      !p.config.Insecure,
+		}))
+	}
+	return grpc.NewClient(p.endpoint(), opts...)
+}
+
+// streamingConfig builds the first request of the stream
+func (p *Provider) streamingConfig() *speechpb.StreamingRecognizeRequest {
+	encoding := speechpb.ExplicitDecodingConfig_LINEAR16
+	switch p.config.Encoding {
+	case "mulaw":
+		encoding = speechpb.ExplicitDecodingConfig_MULAW
+	case "alaw":
+		encoding = speechpb.ExplicitDecodingConfig_ALAW
+	}
+	model := p.config.Model
+	if model == "" {
+		model = DefaultModel
+	}
+
+	config := &speechpb.RecognitionConfig{
+		DecodingConfig: &speechpb.RecognitionConfig_ExplicitDecodingConfig{
+			ExplicitDecodingConfig: &speechpb.ExplicitDecodingConfig{
+				Encoding:          encoding,
+				SampleRateHertz:   int32(p.config.SampleRate),
+				AudioChannelCount: int32(p.config.Channels),
+			},
+		},
+		Model:         model,
+		LanguageCodes: []string{p.config.Language},
+		Features: &speechpb.RecognitionFeatures{
+			EnableWordTimeOffsets:      true,
+			EnableWordConfidence:       true,
+			EnableAutomaticPunctuation: p.config.Punctuate,
+			ProfanityFilter:            p.config.ProfanityFilter,
+		},
+	}
+	if len(p.config.Phrases) > 0 {
+		phrases := make([]*speechpb.PhraseSet_Phrase, len(p.config.Phrases))
+		for i, phrase := range p.config.Phrases {
+			phrases[i] = &speechpb.PhraseSet_Phrase{Value: phrase}
+		}
+		config.Adaptation = &speechpb.SpeechAdaptation{
+			PhraseSets: []*speechpb.SpeechAdaptation_AdaptationPhraseSet{{
+				Value: &speechpb.SpeechAdaptation_AdaptationPhraseSet_InlinePhraseSet{
+					InlinePhraseSet: &speechpb.PhraseSet{Phrases: phrases},
+				},
+			}},
+		}
+	}
+
+	return &speechpb.StreamingRecognizeRequest{
+		Recognizer: p.recognizer(),
+		StreamingRequest: &speechpb.StreamingRecognizeRequest_StreamingConfig{
+			StreamingConfig: &speechpb.StreamingRecognitionConfig{
+				Config: config,
+				StreamingFeatures: &speechpb.StreamingRecognitionFeatures{
+					InterimResults:            p.config.Interim,
+					EnableVoiceActivityEvents: true,
+				},
+			},
+		},
+	}
+}
+
+// StreamAudio sends the audio over StreamingRecognize as it is paced by the reader
+// and emits interim, final and end of speech events to sink
+func (p *Provider) StreamAudio(ctx context.Context, audioReader io.Reader, sink events.Sink) error {
+	conn, err := p.dial()
+	if err != nil {
+		return events.EmitError(sink, fmt.Errorf("failed to connect: %w", err))
+	}
+	defer conn.Close()
+
+	// Requests are routed by recognizer
+	ctx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(ctx, "x-goog-request-params", "recognizer="+p.recognizer()))
+	defer cancel()
+	streamingConfig := p.streamingConfig()
+	body, _ := protojson.Marshal(streamingConfig)
+	events.RecordRequest(sink, events.Request{
+		URL:    "grpc://" + p.endpoint() + "/google.cloud.speech.v2.Speech/StreamingRecognize",
+		Header: http.Header{"X-Goog-Request-Params": {"recognizer=" + p.recognizer()}},
+		Body:   body,
+	})
+	stream, err := speechpb.NewSpeechClient(conn).StreamingRecognize(ctx)
+	if err != nil {
+		return events.EmitError(sink, fmt.Errorf("failed to open stream: %w", apiError(err)))
+	}
+	if err := stream.Send(streamingConfig); err != nil {
+		// The reason comes with the response stream
+		_, err = stream.Recv()
+		return events.EmitError(sink, fmt.Errorf("failed to configure stream: %w", apiError(err)))
+	}
+	sink.Emit(events.Event{Type: events.Opened})
+	defer sink.Emit(events.Event{Type: events.Closed})
+
+	sendErr := make(chan error, 1)
+	go func() {
+		sendErr <- sendAudio(stream, audioReader)
+	}()
+
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return events.EmitError(sink, fmt.Errorf("stream interrupted: %w", ctx.Err()))
+			}
+			return events.EmitError(sink, apiError(err))
+		}
+		receivedAt := time.Now()
+		if data, err := protojson.Marshal(resp); err == nil {
+			events.RecordMessage(sink, data)
+		}
+
+		if resp.SpeechEventType == speechpb.StreamingRecognizeResponse_SPEECH_ACTIVITY_END {
+			sink.Emit(events.Event{Type: events.UtteranceEnd, ReceivedAt: receivedAt})
+		}
+		p.emitResults(resp.Results, receivedAt, sink)
+	}
+
+	if err := <-sendErr; err != nil {
+		return events.EmitError(sink, err)
+	}
+	return nil
+}
+
+// emitResults reports each final result with its words, and the interim results
+// of a response together, as the hypothesis so far; interim results carry no word
+// offsets, so their words are untimed
+func (p *Provider) emitResults(results []*speechpb.StreamingRecognitionResult, receivedAt time.Time, sink events.Sink) {
+	var interim []string
+	for _, result := range results {
+		if len(result.Alternatives) == 0 {
+			continue
+		}
+		alt := result.Alternatives[0]
+		if !result.IsFinal {
+			if result.Stability >= p.config.MinStability && alt.Transcript != "" {
+				interim = append(interim, strings.TrimSpace(alt.Transcript))
+			}
+			continue
+		}
+
+		words := make([]events.Word, len(alt.Words))
+		for i, w := range alt.Words {
+			words[i] = events.Word{Word: w.Word, Start: seconds(w.StartOffset), End: seconds(w.EndOffset), Confidence: float64(w.Confidence)}
+		}
+		sink.Emit(events.Event{
+			Type:       events.Final,
+			ReceivedAt: receivedAt,
+			Transcript: strings.TrimSpace(alt.Transcript),
+			Words:      words,
+		})
+	}
+
+	if len(interim) > 0 {
+		transcript := strings.Join(interim, " ")
+		fields := strings.Fields(transcript)
+		words := make([]events.Word, len(fields))
+		for i, f := range fields {
+			words[i] = events.Word{Word: f}
+		}
+		sink.Emit(events.Event{Type: events.Interim, ReceivedAt: receivedAt, Transcript: transcript, Words: words})
+	}
+}
+
+// sendAudio sends each chunk as it is paced by the reader, split to the request size limit
+func sendAudio(stream speechpb.Speech_StreamingRecognizeClient, audioReader io.Reader) error {
+	buf := make([]byte, maxAudioRequest)
+	for {
+		n, err := audioReader.Read(buf)
+		if n > 0 {
+			request := &speechpb.StreamingRecognizeRequest{
+				StreamingRequest: &speechpb.StreamingRecognizeRequest_Audio{Audio: append([]byte(nil), buf[:n]...)},
+			}
+			if serr := stream.Send(request); serr != nil {
+				// The reason comes with the response stream
+				return nil
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read audio data: %w", err)
+		}
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return fmt.Errorf("failed to close stream: %w", err)
+	}
+	return nil
+}
+
+// apiError formats a gRPC status like the HTTP errors of other providers
+func apiError(err error) error {
+	if s, ok := status.FromError(err); ok && s != nil {
+		return fmt.Errorf("API error %s: %s", s.Code(), s.Message())
+	}
+	return err
+}
+
+// seconds converts a protobuf duration to seconds
+func seconds(d *durationpb.Duration) float64 {
+	return d.AsDuration().Seconds()
+}
@@ -0,0 +1,221 @@
+package google
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/speech/apiv2/speechpb"
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers/internal/providertest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// fakeSpeech emulates StreamingRecognize: an interim result per audio request,
+// then the end of speech and a final result once the client closes its side
+type fakeSpeech struct {
+	speechpb.UnimplementedSpeechServer
+	authorize func(token string) error
+	received  bytes.Buffer
+}
+
+func (f *fakeSpeech) StreamingRecognize(stream speechpb.Speech_StreamingRecognizeServer) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	token, _ := strings.CutPrefix(strings.Join(md.Get("authorization"), ""), "Bearer ")
+	if err := f.authorize(token); err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	if params := md.Get("x-goog-request-params"); len(params) != 1 || params[0] != "recognizer=projects/bench/locations/global/recognizers/_" {
+		return status.Errorf(codes.InvalidArgument, "unexpected routing %v", params)
+	}
+
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	config := first.GetStreamingConfig()
+	decoding := config.GetConfig().GetExplicitDecodingConfig()
+	if decoding.GetSampleRateHertz() != 16000 || decoding.GetEncoding() != speechpb.ExplicitDecodingConfig_LINEAR16 || !config.GetStreamingFeatures().GetInterimResults() {
+		return status.Errorf(codes.InvalidArgument, "unexpected config %v", config)
+	}
+
+	words := []string{"hello", "world"}
+	for i := 0; ; i++ {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		f.received.Write(req.GetAudio())
+
+		// The second hypothesis is unstable and filtered out by the provider
+		stream.Send(&speechpb.StreamingRecognizeResponse{Results: []*speechpb.StreamingRecognitionResult{
+			{Alternatives: []*speechpb.SpeechRecognitionAlternative{{Transcript: words[i%2]}}, Stability: 0.9},
+			{Alternatives: []*speechpb.SpeechRecognitionAlternative{{Transcript: "hollow"}}, Stability: 0.1},
+		}})
+	}
+
+	stream.Send(&speechpb.StreamingRecognizeResponse{SpeechEventType: speechpb.StreamingRecognizeResponse_SPEECH_ACTIVITY_END})
+	return stream.Send(&speechpb.StreamingRecognizeResponse{Results: []*speechpb.StreamingRecognitionResult{{
+		IsFinal: true,
+		Alternatives: []*speechpb.SpeechRecognitionAlternative{{
+			Transcript: "hello world",
+			Words: []*speechpb.WordInfo{
+				{Word: "hello", StartOffset: durationpb.New(100 * time.Millisecond), EndOffset: durationpb.New(400 * time.Millisecond), Confidence: 0.9},
+				{Word: "world", StartOffset: durationpb.New(500 * time.Millisecond), EndOffset: durationpb.New(900 * time.Millisecond), Confidence: 0.8},
+			},
+		}},
+	}}})
+}
+
+// startFake serves the fake on a local port and returns its address
+func startFake(t *testing.T, fake *fakeSpeech) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	speechpb.RegisterSpeechServer(server, fake)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+	return lis.Addr().String()
+}
+
+func TestStreamAudio(t *testing.T) {
+	fake := &fakeSpeech{authorize: func(token string) error {
+		if token != "test-token" {
+			return errors.New("bad token")
+		}
+		return nil
+	}}
+	addr := startFake(t, fake)
+
+	provider, err := NewProvider(&Config{
+		SampleRate:   16000,
+		Channels:     1,
+		Language:     "en-US",
+		Interim:      true,
+		Project:      "bench",
+		Endpoint:     addr,
+		Insecure:     true,
+		MinStability: 0.5,
+	}, "test-token")
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+
+	audio := bytes.Repeat([]byte{1, 2}, 1000)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	log := events.NewLog(nil)
+	if err := provider.StreamAudio(ctx, &providertest.ChunkReader{Data: audio, Size: 1000}, log); err != nil {
+		t.Fatalf("StreamAudio failed: %v", err)
+	}
+	if !bytes.Equal(fake.received.Bytes(), audio) {
+		t.Errorf("server received %d bytes, want %d", fake.received.Len(), len(audio))
+	}
+
+	got := log.Events()
+	providertest.CheckTypes(t, got, []events.Type{events.Opened, events.Interim, events.Interim, events.UtteranceEnd, events.Final, events.Closed})
+	if got[1].Transcript != "hello" || len(got[1].Words) != 1 {
+		t.Errorf("expected the stable hypothesis only, got %+v", got[1])
+	}
+	final := got[4]
+	if final.Transcript != "hello world" || len(final.Words) != 2 || final.Words[1].End != 0.9 {
+		t.Errorf("unexpected final %+v", final)
+	}
+}
+
+func TestStreamAudio_ServiceAccount(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	credentials, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "bench",
+		"private_key_id": "key-1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"client_email":   "bench@bench.iam.gserviceaccount.com",
+	})
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, credentials, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fake checks the self-signed token like Google would
+	fake := &fakeSpeech{authorize: func(token string) error {
+		parts := strings.Split(token, ".")
+		if len(parts) != 3 {
+			return errors.New("not a JWT")
+		}
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+			return err
+		}
+		var claims struct {
+			Iss string `json:"iss"`
+			Aud string `json:"aud"`
+		}
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		json.Unmarshal(payload, &claims)
+		if claims.Iss != "bench@bench.iam.gserviceaccount.com" || claims.Aud != tokenAudience {
+			return errors.New("unexpected claims")
+		}
+		return nil
+	}}
+	addr := startFake(t, fake)
+
+	// The project comes from the key
+	provider, err := NewProvider(&Config{SampleRate: 16000, Channels: 1, Interim: true, Endpoint: addr, Insecure: true, Credentials: path}, "")
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	log := events.NewLog(nil)
+	if err := provider.StreamAudio(context.Background(), &providertest.ChunkReader{Data: []byte{0, 0}, Size: 2}, log); err != nil {
+		t.Fatalf("StreamAudio failed: %v", err)
+	}
+}
+
+func TestStreamAudio_Errors(t *testing.T) {
+	if _, err := NewProvider(&Config{}, "token"); err == nil {
+		t.Error("expected error without a project")
+	}
+
+	fake := &fakeSpeech{authorize: func(string) error { return errors.New("token expired") }}
+	addr := startFake(t, fake)
+	provider, _ := NewProvider(&Config{SampleRate: 16000, Channels: 1, Project: "bench", Endpoint: addr, Insecure: true}, "old-token")
+
+	log := events.NewLog(nil)
+	err := provider.StreamAudio(context.Background(), &providertest.ChunkReader{Data: []byte{0, 0}, Size: 2}, log)
+	if err == nil || !strings.Contains(err.Error(), "API error Unauthenticated: token expired") {
+		t.Errorf("expected an authentication error, got %v", err)
+	}
+
+	if got := (&Provider{config: &Config{Location: "europe-west4"}}).endpoint(); got != "europe-west4-speech.googleapis.com:443" {
+		t.Errorf("unexpected regional endpoint %s", got)
+	}
+}
@@ -0,0 +1,37 @@
+// Package providertest holds the helpers shared by the provider tests
+package providertest
+
+import (
+	"io"
+	"testing"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+)
+
+// ChunkReader returns at most Size bytes per read, like the paced WAV reader
+type ChunkReader struct {
+	Data []byte
+	Size int
+}
+
+func (r *ChunkReader) Read(p []byte) (int, error) {
+	if len(r.Data) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p[:min(len(p), r.Size)], r.Data)
+	r.Data = r.Data[n:]
+	return n, nil
+}
+
+// CheckTypes compares the types of the events, in order
+func CheckTypes(t testing.TB, got []events.Event, want []events.Type) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), got)
+	}
+	for i, e := range got {
+		if e.Type != want[i] {
+			t.Errorf("event %d: type = %s, want %s", i, e.Type, want[i])
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Package wsstream holds the WebSocket plumbing shared by the streaming providers:
+// the endpoint scheme, the handshake errors, the interruption of a stream by its
+// context, the end of a session, the paced sending of the audio and the recording of
+// the raw exchange.
+package wsstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/gorilla/websocket"
+)
+
+// ReadBufferSize bounds a single audio read; the paced reader caps it at its chunk size
+const ReadBufferSize = 32 * 1024
+
+// NormalizeScheme turns the http and https schemes of a base URL into ws and wss
+func NormalizeScheme(u *url.URL) error {
+	switch u.Scheme {
+	case "https", "wss":
+		u.Scheme = "wss"
+	case "http", "ws":
+		u.Scheme = "ws"
+	default:
+		return fmt.Errorf("unsupported URL scheme: %s", u.Scheme)
+	}
+	return nil
+}
+
+// Conn is a WebSocket connection closed when its context ends, which unblocks its
+// reader and its sender
+type Conn struct {
+	*websocket.Conn
+	ctx  context.Context
+	stop func() bool
+	sink events.Sink
+}
+
+// Dial opens a connection, then sends start as a text message when it is set. The
+// handshake and start are recorded to sink as the request, and a refused handshake
+// fails with the status and body of the response
+func Dial(ctx context.Context, sink events.Sink, wsURL string, header http.Header, start []byte) (*Conn, error) {
+	events.RecordRequest(sink, events.Request{URL: wsURL, Header: header, Body: start})
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, wsURL, header)
+	if err != nil {
+		if resp != nil {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			events.RecordMessage(sink, body)
+			return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		}
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	c := &Conn{
+		Conn: conn,
+		ctx:  ctx,
+		stop: context.AfterFunc(ctx, func() { conn.Close() }),
+		sink: sink,
+	}
+	if start != nil {
+		if err := c.WriteMessage(websocket.TextMessage, start); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("failed to send start message: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// Close closes the connection
+func (c *Conn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
+
+// Receive reads the next message and records it. It returns io.EOF once the session
+// ends with a normal close or the connection is closed, and fails when the context
+// ends or the session is closed with an error code
+func (c *Conn) Receive() (int, []byte, error) {
+	msgType, data, err := c.ReadMessage()
+	if err == nil {
+		events.RecordMessage(c.sink, data)
+		return msgType, data, nil
+	}
+	if c.ctx.Err() != nil {
+		return 0, nil, fmt.Errorf("stream interrupted: %w", c.ctx.Err())
+	}
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) && closeErr.Code != websocket.CloseNormalClosure {
+		return 0, nil, fmt.Errorf("session closed with code %d: %s", closeErr.Code, closeErr.Text)
+	}
+	if closeErr != nil || errors.Is(err, io.EOF) {
+		return 0, nil, io.EOF
+	}
+	return 0, nil, fmt.Errorf("failed to read message: %w", err)
+}
+
+// SendBinary writes a chunk of audio as a binary message
+func (c *Conn) SendBinary(chunk []byte) error {
+	if err := c.WriteMessage(websocket.BinaryMessage, chunk); err != nil {
+		return fmt.Errorf("failed to send audio: %w", err)
+	}
+	return nil
+}
+
+// SendChunks reads the audio as it is paced by the reader and hands each chunk to send
+// until the end of the audio
+func SendChunks(audioReader io.Reader, send func(chunk []byte) error) error {
+	buf := make([]byte, ReadBufferSize)
+	for {
+		n, err := audioReader.Read(buf)
+		if n > 0 {
+			if serr := send(buf[:n]); serr != nil {
+				return serr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read audio data: %w", err)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+)
+
+// Config holds the provider configuration
+type Config struct {
+	Language string     // BCP-47 or ISO-639-1 code, sent as ISO-639-1
+	BaseURL  string     // defaults to DefaultBaseURL
+	Model    string     // defaults to DefaultModel
+	Stream   bool       // ask for incremental text deltas over server-sent events
+	Options  url.Values // extra form fields, replacing those of the same name
+}
+
+const (
+	// DefaultBaseURL is the OpenAI API endpoint used when Config.BaseURL is empty
+	DefaultBaseURL = "https://api.openai.com"
+	// DefaultModel is the transcription model used when Config.Model is empty
+	DefaultModel = "whisper-1"
+)
+
+// requestTimeout bounds a transcription request; local models may take a while on long files
+const requestTimeout = 5 * time.Minute
+
+// Provider implements the speech recognition provider for OpenAI-compatible
+// /v1/audio/transcriptions endpoints
+type Provider struct {
+	apiKey string
+	config *Config
+}
+
+// NewProvider creates a new OpenAI-compatible provider
+func NewProvider(config *Config, apiKey string) (*Provider, error) {
+	return &Provider{
+		apiKey: apiKey,
+		config: config,
+	}, nil
+}
+
+// transcription is the json and verbose_json response body
+type transcription struct {
+	Text  string `json:"text"`
+	Words []struct {
+		Word  string  `json:"word"`
+		Start float64 `json:"start"`
+		End   float64 `json:"end"`
+	} `json:"words"`
+}
+
+// streamEvent is a server-sent event of a streamed transcription, either in
+// OpenAI's transcript.text.* shape or in the chat-like shape of vLLM
+type streamEvent struct {
+	Type    string `json:"type"`
+	Delta   string `json:"delta"`
+	Text    string `json:"text"`
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+}
+
+// baseURL returns the configured API endpoint without a trailing slash
+func (p *Provider) baseURL() string {
+	if p.config.BaseURL == "" {
+		return DefaultBaseURL
+	}
+	return strings.TrimRight(p.config.BaseURL, "/")
+}
+
+// model returns the configured model
+func (p *Provider) model() string {
+	if p.config.Model == "" {
+		return DefaultModel
+	}
+	return p.config.Model
+}
+
+// StreamAudio uploads the audio as a multipart form and emits the transcript events to sink
+func (p *Provider) StreamAudio(ctx context.Context, audioReader io.Reader, sink events.Sink) error {
+	// The endpoint needs a complete file, header included
+	var audioData []byte
+	var err error
+	if chunkedReader, ok := audioReader.(interface{ GetFile() io.Reader }); ok {
+		audioData, err = io.ReadAll(chunkedReader.GetFile())
+	} else {
+		audioData, err = io.ReadAll(audioReader)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read audio data: %w", err)
+	}
+
+	body, contentType, err := p.form(audioData)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL()+"/v1/audio/transcriptions", body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	sink.Emit(events.Event{Type: events.Opened})
+	defer sink.Emit(events.Event{Type: events.Closed})
+
+	client := &http.Client{Timeout: requestTimeout}
+	events.RecordRequest(sink, events.Request{URL: req.URL.String(), Header: req.Header})
+	resp, err := client.Do(req)
+	if err != nil {
+		return events.EmitError(sink, fmt.Errorf("failed to send request: %w", err))
+	}
+	defer resp.Body.Close()
+
+	// Servers without streaming support answer a stream request with plain JSON
+	if resp.StatusCode == http.StatusOK && strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return readStream(resp.Body, sink)
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return events.EmitError(sink, fmt.Errorf("failed to read response: %w", err))
+	}
+	events.RecordMessage(sink, respBody)
+	if resp.StatusCode != http.StatusOK {
+		return events.EmitError(sink, fmt.Errorf("API error %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody))))
+	}
+	var result transcription
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return events.EmitError(sink, fmt.Errorf("failed to decode response: %w", err))
+	}
+
+	words := make([]events.Word, len(result.Words))
+	for i, w := range result.Words {
+		words[i] = events.Word{Word: strings.TrimSpace(w.Word), Start: w.Start, End: w.End}
+	}
+	if len(words) == 0 {
+		words = untimedWords(result.Text)
+	}
+	sink.Emit(events.Event{Type: events.Final, Transcript: strings.TrimSpace(result.Text), Words: words})
+	return nil
+}
+
+// form builds the multipart body: word timestamps need verbose_json, which
+// streaming does not support
+func (p *Provider) form(audioData []byte) (io.Reader, string, error) {
+	fields := url.Values{}
+	fields.Set("model", p.model())
+	if p.config.Language != "" {
+		language, _, _ := strings.Cut(p.config.Language, "-")
+		fields.Set("language", strings.ToLower(language))
+	}
+	if p.config.Stream {
+		fields.Set("response_format", "json")
+		fields.Set("stream", "true")
+	} else {
+		fields.Set("response_format", "verbose_json")
+		fields.Set("timestamp_granularities[]", "word")
+	}
+	for key, values := range p.config.Options {
+		fields[key] = values
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for key, values := range fields {
+		for _, value := range values {
+			if err := w.WriteField(key, value); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	part, err := w.CreateFormFile("file", "audio.wav")
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(audioData); err != nil {
+		return nil, "", err
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return &body, w.FormDataContentType(), nil
+}
+
+// readStream records every line of the stream, and emits an interim event with the
+// text so far for every delta and a final event when the transcription is done
+func readStream(body io.Reader, sink events.Sink) error {
+	var text strings.Builder
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			events.RecordMessage(sink, scanner.Bytes())
+		}
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var e streamEvent
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			return events.EmitError(sink, fmt.Errorf("failed to decode event: %w", err))
+		}
+		switch {
+		case e.Type == "transcript.text.done":
+			sink.Emit(events.Event{Type: events.Final, Transcript: strings.TrimSpace(e.Text), Words: untimedWords(e.Text)})
+			return nil
+		case e.Type == "transcript.text.delta":
+			text.WriteString(e.Delta)
+		case len(e.Choices) > 0:
+			text.WriteString(e.Choices[0].Delta.Content)
+		default:
+			continue
+		}
+		sink.Emit(events.Event{Type: events.Interim, Transcript: strings.TrimSpace(text.String()), Words: untimedWords(text.String())})
+	}
+	if err := scanner.Err(); err != nil {
+		return events.EmitError(sink, fmt.Errorf("failed to read stream: %w", err))
+	}
+
+	// Chat-like streams end without a done event
+	sink.Emit(events.Event{Type: events.Final, Transcript: strings.TrimSpace(text.String()), Words: untimedWords(text.String())})
+	return nil
+}
+
+// untimedWords splits text into words without timestamps, for responses that
+// carry none; their latency is measured from the start of the upload
+func untimedWords(text string) []events.Word {
+	fields := strings.Fields(text)
+	words := make([]events.Word, len(fields))
+	for i, f := range fields {
+		words[i] = events.Word{Word: f}
+	}
+	return words
+}
@@ -0,0 +1,140 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+)
+
+// transcriptionStandIn checks the upload and answers with body, as an event stream when stream is requested
+func transcriptionStandIn(t *testing.T, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("missing file: %v", err)
+			return
+		}
+		audio, _ := io.ReadAll(file)
+		if string(audio) != "RIFF audio" || r.FormValue("model") != "whisper-1" || r.FormValue("language") != "en" {
+			t.Errorf("unexpected form %v with %q", r.MultipartForm.Value, audio)
+		}
+
+		if r.FormValue("stream") == "true" {
+			w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+			if r.FormValue("response_format") != "verbose_json" || r.FormValue("timestamp_granularities[]") != "word" {
+				t.Errorf("expected word timestamps to be requested, got %v", r.MultipartForm.Value)
+			}
+		}
+		io.WriteString(w, body)
+	}))
+}
+
+func transcribe(t *testing.T, srv *httptest.Server, config Config) ([]events.Event, error) {
+	t.Helper()
+	config.BaseURL = srv.URL
+	config.Language = "en-US"
+	provider, _ := NewProvider(&config, "test-key")
+	log := events.NewLog(nil)
+	err := provider.StreamAudio(context.Background(), bytes.NewReader([]byte("RIFF audio")), log)
+	return log.Events(), err
+}
+
+func TestStreamAudio_VerboseJSON(t *testing.T) {
+	srv := transcriptionStandIn(t, `{"text":" Hello world.","words":[{"word":"Hello","start":0.1,"end":0.4},{"word":"world","start":0.5,"end":0.9}]}`)
+	defer srv.Close()
+
+	got, err := transcribe(t, srv, Config{})
+	if err != nil {
+		t.Fatalf("StreamAudio failed: %v", err)
+	}
+	if len(got) != 3 || got[1].Type != events.Final {
+		t.Fatalf("expected opened, final, closed, got %+v", got)
+	}
+	if got[1].Transcript != "Hello world." || len(got[1].Words) != 2 || got[1].Words[1].End != 0.9 {
+		t.Errorf("unexpected final %+v", got[1])
+	}
+}
+
+func TestStreamAudio_Stream(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"openai", "data: {\"type\":\"transcript.text.delta\",\"delta\":\"Hello\"}\n\n" +
+			"data: {\"type\":\"transcript.text.delta\",\"delta\":\" world.\"}\n\n" +
+			"data: {\"type\":\"transcript.text.done\",\"text\":\"Hello world.\"}\n\n"},
+		{"chat-like", "data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\n" +
+			"data: {\"choices\":[{\"delta\":{\"content\":\" world.\"}}]}\n\n" +
+			"data: [DONE]\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := transcriptionStandIn(t, tt.body)
+			defer srv.Close()
+
+			got, err := transcribe(t, srv, Config{Stream: true})
+			if err != nil {
+				t.Fatalf("StreamAudio failed: %v", err)
+			}
+			// Opened, an interim per delta, the final, then Closed
+			if len(got) != 5 {
+				t.Fatalf("expected 5 events, got %+v", got)
+			}
+			if got[1].Type != events.Interim || got[1].Transcript != "Hello" || len(got[2].Words) != 2 {
+				t.Errorf("unexpected interims %+v and %+v", got[1], got[2])
+			}
+			if got[3].Type != events.Final || got[3].Transcript != "Hello world." {
+				t.Errorf("unexpected final %+v", got[3])
+			}
+		})
+	}
+}
+
+func TestStreamAudio_Options(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("response_format") != "json" || r.FormValue("prompt") != "Deepgram" {
+			http.Error(w, "unexpected form", http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, `{"text":"Deepgram"}`)
+	}))
+	defer srv.Close()
+
+	// Options replace the fields of the same name, and untimed text still makes words
+	got, err := transcribe(t, srv, Config{Options: url.Values{"response_format": {"json"}, "prompt": {"Deepgram"}}})
+	if err != nil {
+		t.Fatalf("StreamAudio failed: %v", err)
+	}
+	if len(got[1].Words) != 1 || got[1].Words[0].Word != "Deepgram" {
+		t.Errorf("unexpected final %+v", got[1])
+	}
+
+	limited := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer limited.Close()
+	got, err = transcribe(t, limited, Config{})
+	if err == nil || !strings.Contains(err.Error(), "API error 429: rate limited") {
+		t.Errorf("expected an API error, got %v", err)
+	}
+	if len(got) != 3 || got[1].Type != events.Error {
+		t.Errorf("expected an error event, got %+v", got)
+	}
+}
@@ -0,0 +1,251 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers/internal/wsstream"
+)
+
+// Config holds the provider configuration
+type Config struct {
+	Command    []string // the plugin executable and its arguments
+	SampleRate int
+	Channels   int
+	Encoding   string
+	Language   string
+	Model      string
+	Interim    bool
+	Options    url.Values
+}
+
+const (
+	// maxLineSize bounds a line written by the plugin
+	maxLineSize = 1024 * 1024
+	// stderrTail is how much of the plugin's standard error is kept for error messages
+	stderrTail = 4 * 1024
+)
+
+// Provider implements the speech recognition provider by running an external plugin
+type Provider struct {
+	apiKey string
+	config *Config
+}
+
+// NewProvider creates a new plugin provider; the API key, optional, is passed on to the plugin
+func NewProvider(config *Config, apiKey string) (*Provider, error) {
+	if len(config.Command) == 0 {
+		return nil, fmt.Errorf("a plugin command is required")
+	}
+	return &Provider{
+		apiKey: apiKey,
+		config: config,
+	}, nil
+}
+
+// StreamAudio starts the plugin, writes it the audio as it is paced by the reader
+// and emits the transcript events it writes back
+func (p *Provider) StreamAudio(ctx context.Context, audioReader io.Reader, sink events.Sink) error {
+	cmd := exec.CommandContext(ctx, p.config.Command[0], p.config.Command[1:]...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create plugin input: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create plugin output: %w", err)
+	}
+	stderr := &tailBuffer{limit: stderrTail}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return events.EmitError(sink, fmt.Errorf("failed to start plugin: %w", err))
+	}
+
+	// The plugin is always waited for; its exit status explains most failures
+	waited := false
+	wait := func() error {
+		waited = true
+		stdin.Close()
+		if err := cmd.Wait(); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("stream interrupted: %w", ctx.Err())
+			}
+			if tail := strings.TrimSpace(stderr.String()); tail != "" {
+				return fmt.Errorf("plugin failed: %w: %s", err, tail)
+			}
+			return fmt.Errorf("plugin failed: %w", err)
+		}
+		return nil
+	}
+	defer func() {
+		if !waited {
+			cmd.Process.Kill()
+			cmd.Wait()
+		}
+	}()
+
+	encoder := json.NewEncoder(stdin)
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	config := ConfigMessage{
+		Type:       TypeConfig,
+		SampleRate: p.config.SampleRate,
+		Channels:   p.config.Channels,
+		Encoding:   p.config.Encoding,
+		Language:   p.config.Language,
+		Model:      p.config.Model,
+		Interim:    p.config.Interim,
+		APIKey:     p.apiKey,
+		Options:    p.config.Options,
+	}
+	// The recorded request is the command line and the configuration, without the API key
+	recorded := config
+	recorded.APIKey = ""
+	body, _ := json.Marshal(recorded)
+	events.RecordRequest(sink, events.Request{URL: strings.Join(p.config.Command, " "), Body: body})
+	if err := encoder.Encode(config); err != nil {
+		return events.EmitError(sink, errors.Join(fmt.Errorf("failed to configure plugin: %w", err), wait()))
+	}
+	ready, err := readEvent(scanner, sink)
+	if err != nil || ready.Type != TypeReady {
+		if err == nil {
+			err = fmt.Errorf("unexpected %s message %s", ready.Type, ready.Message)
+		}
+		return events.EmitError(sink, errors.Join(fmt.Errorf("failed to configure plugin: %w", err), wait()))
+	}
+	sink.Emit(events.Event{Type: events.Opened})
+	defer sink.Emit(events.Event{Type: events.Closed})
+
+	sendErr := make(chan error, 1)
+	go func() {
+		sendErr <- p.sendAudio(encoder, stdin, audioReader)
+	}()
+
+	var readErr error
+	for {
+		msg, err := readEvent(scanner, sink)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				readErr = err
+			}
+			break
+		}
+		receivedAt := time.Now()
+
+		switch msg.Type {
+		case TypeInterim, TypeFinal:
+			eventType := events.Final
+			if msg.Type == TypeInterim {
+				eventType = events.Interim
+			}
+			words := make([]events.Word, len(msg.Words))
+			for i, w := range msg.Words {
+				words[i] = events.Word{Word: w.Word, Start: w.Start, End: w.End, Confidence: w.Confidence}
+			}
+			sink.Emit(events.Event{Type: eventType, ReceivedAt: receivedAt, Transcript: msg.Transcript, Words: words})
+		case TypeUtteranceEnd:
+			sink.Emit(events.Event{Type: events.UtteranceEnd, ReceivedAt: receivedAt})
+		case TypeError:
+			sink.Emit(events.Event{Type: events.Error, ReceivedAt: receivedAt, Err: fmt.Errorf("provider error: %s", msg.Message)})
+		}
+	}
+
+	// A plugin failure explains a broken input or output, so it comes first
+	if err := wait(); err != nil {
+		return events.EmitError(sink, err)
+	}
+	if readErr != nil {
+		return events.EmitError(sink, readErr)
+	}
+	if err := <-sendErr; err != nil {
+		return events.EmitError(sink, err)
+	}
+	return nil
+}
+
+// sendAudio writes each chunk as it is paced by the reader, then the end of the audio,
+// and closes the plugin's input
+func (p *Provider) sendAudio(encoder *json.Encoder, stdin io.Closer, audioReader io.Reader) error {
+	defer stdin.Close()
+	bytesPerSecond := float64(p.config.SampleRate * max(p.config.Channels, 1) * 2)
+	buf := make([]byte, wsstream.ReadBufferSize)
+	var seq int
+	var sent int64
+	for {
+		n, err := audioReader.Read(buf)
+		if n > 0 {
+			seq++
+			msg := AudioMessage{Type: TypeAudio, Seq: seq, Offset: float64(sent) / bytesPerSecond, SentAt: time.Now(), Data: buf[:n]}
+			if werr := encoder.Encode(msg); werr != nil {
+				return fmt.Errorf("failed to send audio: %w", werr)
+			}
+			sent += int64(n)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read audio data: %w", err)
+		}
+	}
+
+	end := AudioMessage{Type: TypeEnd, Seq: seq, Offset: float64(sent) / bytesPerSecond, SentAt: time.Now()}
+	if err := encoder.Encode(end); err != nil {
+		return fmt.Errorf("failed to end audio: %w", err)
+	}
+	return nil
+}
+
+// readEvent reads and records the next line written by the plugin, skipping blank lines
+func readEvent(scanner *bufio.Scanner, sink events.Sink) (EventMessage, error) {
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		events.RecordMessage(sink, []byte(line))
+		var msg EventMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			return msg, fmt.Errorf("failed to decode plugin message %q: %w", line, err)
+		}
+		return msg, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return EventMessage{}, fmt.Errorf("failed to read plugin output: %w", err)
+	}
+	return EventMessage{}, io.EOF
+}
+
+// tailBuffer keeps the last bytes written to it
+type tailBuffer struct {
+	mu    sync.Mutex
+	data  []byte
+	limit int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, p...)
+	if len(b.data) > b.limit {
+		b.data = b.data[len(b.data)-b.limit:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.data)
+}
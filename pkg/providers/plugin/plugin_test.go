@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers/internal/providertest"
+)
+
+// buildEchoPlugin builds the reference plugin
+func buildEchoPlugin(t *testing.T) string {
+	bin := filepath.Join(t.TempDir(), "echo_plugin")
+	build := exec.Command("go", "build", "-o", bin, "github.com/elishowk/speech_latency/cmd/echo_plugin")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("failed to build the echo plugin: %v\n%s", err, out)
+	}
+	return bin
+}
+
+func TestStreamAudio(t *testing.T) {
+	bin := buildEchoPlugin(t)
+
+	provider, err := NewProvider(&Config{
+		Command:    []string{bin, "-final-every", "2"},
+		SampleRate: 16000,
+		Channels:   1,
+		Encoding:   "linear16",
+		Language:   "en-US",
+		Interim:    true,
+	}, "plugin-key")
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+
+	// Three chunks of 100ms
+	audio := bytes.Repeat([]byte{1, 2}, 4800)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	log := events.NewLog(nil)
+	if err := provider.StreamAudio(ctx, &providertest.ChunkReader{Data: audio, Size: 3200}, log); err != nil {
+		t.Fatalf("StreamAudio failed: %v", err)
+	}
+
+	got := log.Events()
+	providertest.CheckTypes(t, got, []events.Type{events.Opened, events.Interim, events.Final, events.UtteranceEnd, events.Interim, events.Final, events.UtteranceEnd, events.Closed})
+	if first := got[2]; first.Transcript != "chunk1 chunk2" || len(first.Words) != 2 || first.Words[1].Start != 0.1 || first.Words[1].End != 0.2 {
+		t.Errorf("unexpected final %+v", first)
+	}
+	if last := got[5]; last.Transcript != "chunk3" {
+		t.Errorf("unexpected last final %+v", last)
+	}
+
+	// The recorded configuration leaves out the API key
+	requests := log.Requests()
+	if len(requests) != 1 || requests[0].URL != bin+" -final-every 2" ||
+		!strings.Contains(string(requests[0].Body), `"sample_rate":16000`) || strings.Contains(string(requests[0].Body), "plugin-key") {
+		t.Errorf("unexpected requests %+v", requests)
+	}
+	if len(log.Messages()) == 0 {
+		t.Error("no plugin message recorded")
+	}
+}
+
+func TestStreamAudio_Errors(t *testing.T) {
+	if _, err := NewProvider(&Config{}, ""); err == nil {
+		t.Error("expected error without a command")
+	}
+
+	provider, _ := NewProvider(&Config{Command: []string{filepath.Join(t.TempDir(), "missing")}}, "")
+	err := provider.StreamAudio(context.Background(), &providertest.ChunkReader{}, events.NewLog(nil))
+	if err == nil || !strings.Contains(err.Error(), "failed to start plugin") {
+		t.Errorf("expected a start error, got %v", err)
+	}
+
+	// A refused configuration reports the plugin's reason and standard error
+	bin := buildEchoPlugin(t)
+	provider, _ = NewProvider(&Config{Command: []string{bin, "-fail", "model not found"}, SampleRate: 16000, Channels: 1}, "")
+	err = provider.StreamAudio(context.Background(), &providertest.ChunkReader{Data: []byte{0, 0}, Size: 2}, events.NewLog(nil))
+	if err == nil || !strings.Contains(err.Error(), "unexpected error message model not found") ||
+		!strings.Contains(err.Error(), "echo_plugin: configuration refused") {
+		t.Errorf("expected the refusal, got %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+// Package plugin runs a speech recognition engine as an external process that
+// speaks a line-delimited JSON protocol over its standard input and output.
+//
+// The provider writes a ConfigMessage line, to which the plugin answers with a
+// "ready" EventMessage, or an "error" one. The provider then writes an AudioMessage
+// line per chunk as the audio is paced, with its base64 audio and the time it was
+// sent, and a final AudioMessage of type "end" before closing the plugin's input.
+// Meanwhile the plugin writes "interim", "final", "utterance_end" and "error"
+// EventMessage lines, and exits once its transcripts are written; a non-zero exit
+// status fails the run. Whatever the plugin writes to its standard error is
+// reported when it fails.
+package plugin
+
+import "time"
+
+// Message types
+const (
+	TypeConfig       = "config"
+	TypeAudio        = "audio"
+	TypeEnd          = "end"
+	TypeReady        = "ready"
+	TypeInterim      = "interim"
+	TypeFinal        = "final"
+	TypeUtteranceEnd = "utterance_end"
+	TypeError        = "error"
+)
+
+// ConfigMessage is the first line written to the plugin
+type ConfigMessage struct {
+	Type       string              `json:"type"`
+	SampleRate int                 `json:"sample_rate"`
+	Channels   int                 `json:"channels"`
+	Encoding   string              `json:"encoding"` // encoding of the audio, e.g. linear16
+	Language   string              `json:"language"`
+	Model      string              `json:"model,omitempty"`
+	Interim    bool                `json:"interim"` // whether interim events are wanted
+	APIKey     string              `json:"api_key,omitempty"`
+	Options    map[string][]string `json:"options,omitempty"` // the --provider-opt values
+}
+
+// AudioMessage is a chunk of audio written to the plugin as it is sent, or the end of the audio
+type AudioMessage struct {
+	Type   string    `json:"type"`
+	Seq    int       `json:"seq"`            // number of the chunk, from 1; the number of chunks for the end
+	Offset float64   `json:"offset"`         // seconds of audio before the chunk
+	SentAt time.Time `json:"sent_at"`        // when the chunk was sent
+	Data   []byte    `json:"data,omitempty"` // the audio, base64 encoded
+}
+
+// EventMessage is a line written by the plugin
+type EventMessage struct {
+	Type       string `json:"type"`
+	Transcript string `json:"transcript,omitempty"`
+	Words      []Word `json:"words,omitempty"`
+	Message    string `json:"message,omitempty"` // the reason of an error
+}
+
+// Word is a recognized word, with times in seconds from the beginning of the audio
+type Word struct {
+	Word       string  `json:"word"`
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Confidence float64 `json:"confidence,omitempty"`
+}
@@ -4,14 +4,30 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/url"
+	"strings"
+	"time"
 
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers/assemblyai"
+	"github.com/elishowk/speech_latency/pkg/providers/awstranscribe"
+	"github.com/elishowk/speech_latency/pkg/providers/azure"
 	"github.com/elishowk/speech_latency/pkg/providers/deepgram"
+	"github.com/elishowk/speech_latency/pkg/providers/google"
+	"github.com/elishowk/speech_latency/pkg/providers/openai"
+	"github.com/elishowk/speech_latency/pkg/providers/plugin"
+	"github.com/elishowk/speech_latency/pkg/providers/replay"
+	"github.com/elishowk/speech_latency/pkg/providers/speechmatics"
+	"github.com/elishowk/speech_latency/pkg/providers/vosk"
+	"github.com/elishowk/speech_latency/pkg/providers/whisperserver"
+	"github.com/elishowk/speech_latency/pkg/providers/wsgeneric"
+	"github.com/elishowk/speech_latency/pkg/session"
 )
 
 // Provider defines the interface that all speech recognition providers must implement
 type Provider interface {
-	// StreamAudio processes an audio stream and returns latency metrics
-	StreamAudio(ctx context.Context, audioReader io.Reader) (*Result, error)
+	// StreamAudio sends an audio stream to the provider and emits transcript events to sink
+	StreamAudio(ctx context.Context, audioReader io.Reader, sink events.Sink) error
 }
 
 // Config holds common configuration for all providers
@@ -22,39 +38,69 @@ type Config struct {
 	Interim     bool
 	Punctuate   bool
 	SmartFormat bool
-}
+	Live        bool   // stream in real time instead of uploading the whole file
+	Model       string // provider specific, empty selects the provider default
+	Encoding    string // encoding of the streamed audio, e.g. linear16
+	BaseURL     string // API endpoint, empty selects the provider default
+
+	RealtimeFactor float64 // pacing speed of the audio relative to real time, 0 when unpaced; set by the benchmark
+
+	ModelVersion    string     // provider specific, empty selects the latest
+	Tier            string     // provider specific model tier
+	EndpointingMs   int        // silence ending an utterance, 0 keeps the provider default, negative disables
+	UtteranceEndMs  int        // gap between words ending an utterance, 0 disables
+	MaxDelayMs      int        // longest delay before a word is final, 0 keeps the provider default
+	OperatingPoint  string     // provider specific accuracy and latency trade-off, e.g. enhanced
+	WindowStepMs    int        // audio between two submissions of a sliding window, 0 keeps the provider default
+	WindowMs        int        // length of a sliding window, 0 keeps the provider default
+	Keyterms        []string   // terms to boost
+	Keywords        []string   // keywords to boost, in the provider's syntax
+	Diarize         bool       // label the speaker of every word
+	Multichannel    bool       // transcribe each channel independently
+	Numerals        bool       // write numbers as digits
+	ProfanityFilter bool       // mask profanity
+	Redact          []string   // entities to redact
+	Options         url.Values // provider specific request parameters, passed through as is
 
-// Result contains the benchmark results
-type Result struct {
-	Latency    float64 // in milliseconds
-	Throughput float64 // words per second
+	Project     string // cloud project, for providers that bill one
+	Region      string // provider region or location, e.g. us-central1
+	Credentials string // path to a credentials file, instead of the API key
+	Insecure    bool   // plaintext transport to BaseURL, for local fakes
+
+	Recognizer   string  // saved recognizer used instead of the inline configuration
+	MinStability float64 // interim results less stable than this are not reported
+
+	Command    []string // plugin executable and its arguments, for the exec provider
+	Template   string   // path to the protocol template of the websocket-generic provider
+	Session    string   // path to the session file replayed by the replay provider
+	SessionRun int      // run of the session file replayed, from 1; 0 replays the first
 }
 
-// deepgramAdapter adapts deepgram.Provider to implement the Provider interface
-type deepgramAdapter struct {
-	provider *deepgram.Provider
+// KeyPolicy reports whether a provider configured by config needs an API key
+type KeyPolicy func(config *Config) bool
+
+// KeyRequired requires an API key, except with a base URL: self-hosted and mock
+// endpoints may take none. Providers registered without a policy use it
+func KeyRequired(config *Config) bool {
+	return config.BaseURL == ""
 }
 
-func (a *deepgramAdapter) StreamAudio(ctx context.Context, audioReader io.Reader) (*Result, error) {
-	result, err := a.provider.StreamAudio(ctx, audioReader)
-	if err != nil {
-		return nil, err
-	}
-	return &Result{
-		Latency:    result.Latency,
-		Throughput: result.Throughput,
-	}, nil
+// KeyOptional never requires an API key, for providers running locally
+func KeyOptional(config *Config) bool {
+	return false
 }
 
 // Factory creates provider instances
 type Factory struct {
-	providers map[string]func(*Config, string) (Provider, error)
+	providers   map[string]func(*Config, string) (Provider, error)
+	keyPolicies map[string]KeyPolicy
 }
 
 // NewFactory creates a new provider factory
 func NewFactory() *Factory {
 	f := &Factory{
-		providers: make(map[string]func(*Config, string) (Provider, error)),
+		providers:   make(map[string]func(*Config, string) (Provider, error)),
+		keyPolicies: make(map[string]KeyPolicy),
 	}
 	
 	// Register providers
@@ -66,13 +112,227 @@ func NewFactory() *Factory {
 			Interim:     config.Interim,
 			Punctuate:   config.Punctuate,
 			SmartFormat: config.SmartFormat,
+			Live:        config.Live,
+			Model:       config.Model,
+			Encoding:    config.Encoding,
+			BaseURL:     config.BaseURL,
+
+			Version:         config.ModelVersion,
+			Tier:            config.Tier,
+			EndpointingMs:   config.EndpointingMs,
+			UtteranceEndMs:  config.UtteranceEndMs,
+			Keyterms:        config.Keyterms,
+			Keywords:        config.Keywords,
+			Diarize:         config.Diarize,
+			Multichannel:    config.Multichannel,
+			Numerals:        config.Numerals,
+			ProfanityFilter: config.ProfanityFilter,
+			Redact:          config.Redact,
+			Options:         config.Options,
+		}
+		return deepgram.NewProvider(dgConfig, apiKey)
+	})
+	f.RegisterProvider("assemblyai", func(config *Config, apiKey string) (Provider, error) {
+		aaiConfig := &assemblyai.Config{
+			SampleRate:       config.SampleRate,
+			Channels:         config.Channels,
+			Encoding:         config.Encoding,
+			BaseURL:          config.BaseURL,
+			Model:            config.Model,
+			FormatTurns:      config.Punctuate || config.SmartFormat,
+			Partials:         config.Interim,
+			EndOfTurnSilence: config.EndpointingMs,
+			MaxTurnSilence:   config.UtteranceEndMs,
+			Keyterms:         config.Keyterms,
+			Options:          config.Options,
+		}
+		return assemblyai.NewProvider(aaiConfig, apiKey)
+	})
+	f.RegisterProvider("aws-transcribe", func(config *Config, apiKey string) (Provider, error) {
+		awsConfig := &awstranscribe.Config{
+			SampleRate:   config.SampleRate,
+			Channels:     config.Channels,
+			Language:     config.Language,
+			Interim:      config.Interim,
+			Encoding:     config.Encoding,
+			Region:       config.Region,
+			BaseURL:      config.BaseURL,
+			Diarize:      config.Diarize,
+			Multichannel: config.Multichannel,
+			Options:      config.Options,
+		}
+		return awstranscribe.NewProvider(awsConfig, apiKey)
+	})
+	f.RegisterProvider("azure", func(config *Config, apiKey string) (Provider, error) {
+		azConfig := &azure.Config{
+			SampleRate:      config.SampleRate,
+			Channels:        config.Channels,
+			Language:        config.Language,
+			Interim:         config.Interim,
+			ProfanityFilter: config.ProfanityFilter,
+			Region:          config.Region,
+			BaseURL:         config.BaseURL,
+			EndpointingMs:   config.EndpointingMs,
+			Options:         config.Options,
+		}
+		return azure.NewProvider(azConfig, apiKey)
+	})
+	f.RegisterProvider("google", func(config *Config, apiKey string) (Provider, error) {
+		gConfig := &google.Config{
+			SampleRate:      config.SampleRate,
+			Channels:        config.Channels,
+			Language:        config.Language,
+			Interim:         config.Interim,
+			Punctuate:       config.Punctuate,
+			ProfanityFilter: config.ProfanityFilter,
+			Encoding:        config.Encoding,
+			Model:           config.Model,
+			Phrases:         append([]string(nil), config.Keyterms...),
+			Project:         config.Project,
+			Location:        config.Region,
+			Recognizer:      config.Recognizer,
+			Endpoint:        config.BaseURL,
+			Insecure:        config.Insecure,
+			Credentials:     config.Credentials,
+			MinStability:    float32(config.MinStability),
+		}
+		// Keywords carry a Deepgram intensifier, phrases do not
+		for _, keyword := range config.Keywords {
+			phrase, _, _ := strings.Cut(keyword, ":")
+			gConfig.Phrases = append(gConfig.Phrases, phrase)
+		}
+		return google.NewProvider(gConfig, apiKey)
+	})
+	// A credentials file authenticates instead of the API key
+	f.SetKeyPolicy("google", func(config *Config) bool {
+		return KeyRequired(config) && config.Credentials == ""
+	})
+	f.RegisterProvider("openai", func(config *Config, apiKey string) (Provider, error) {
+		oaConfig := &openai.Config{
+			Language: config.Language,
+			BaseURL:  config.BaseURL,
+			Model:    config.Model,
+			Stream:   config.Live,
+			Options:  config.Options,
+		}
+		return openai.NewProvider(oaConfig, apiKey)
+	})
+	f.RegisterProvider("speechmatics", func(config *Config, apiKey string) (Provider, error) {
+		smConfig := &speechmatics.Config{
+			SampleRate:     config.SampleRate,
+			Channels:       config.Channels,
+			Language:       config.Language,
+			Interim:        config.Interim,
+			Encoding:       config.Encoding,
+			BaseURL:        config.BaseURL,
+			Region:         config.Region,
+			MaxDelayMs:     config.MaxDelayMs,
+			OperatingPoint: config.OperatingPoint,
+			EndOfUtterance: config.UtteranceEndMs,
+			Diarize:        config.Diarize,
+			Vocabulary:     config.Keyterms,
+			Options:        config.Options,
+		}
+		return speechmatics.NewProvider(smConfig, apiKey)
+	})
+	f.RegisterProvider("vosk", func(config *Config, apiKey string) (Provider, error) {
+		voskConfig := &vosk.Config{
+			SampleRate: config.SampleRate,
+			Channels:   config.Channels,
+			Interim:    config.Interim,
+			BaseURL:    config.BaseURL,
 		}
-		dgProvider, err := deepgram.NewProvider(dgConfig, apiKey)
+		return vosk.NewProvider(voskConfig, apiKey)
+	})
+	f.SetKeyPolicy("vosk", KeyOptional)
+	f.RegisterProvider("whisper-server", func(config *Config, apiKey string) (Provider, error) {
+		wsConfig := &whisperserver.Config{
+			SampleRate: config.SampleRate,
+			Channels:   config.Channels,
+			Language:   config.Language,
+			Interim:    config.Interim,
+			BaseURL:    config.BaseURL,
+			Step:       time.Duration(config.WindowStepMs) * time.Millisecond,
+			Window:     time.Duration(config.WindowMs) * time.Millisecond,
+			Options:    config.Options,
+		}
+		if config.Live && wsConfig.Step == 0 {
+			wsConfig.Step = whisperserver.DefaultStep
+		}
+		return whisperserver.NewProvider(wsConfig, apiKey)
+	})
+	f.SetKeyPolicy("whisper-server", KeyOptional)
+	f.RegisterProvider("exec", func(config *Config, apiKey string) (Provider, error) {
+		pluginConfig := &plugin.Config{
+			Command:    config.Command,
+			SampleRate: config.SampleRate,
+			Channels:   config.Channels,
+			Encoding:   config.Encoding,
+			Language:   config.Language,
+			Model:      config.Model,
+			Interim:    config.Interim,
+			Options:    config.Options,
+		}
+		return plugin.NewProvider(pluginConfig, apiKey)
+	})
+	f.SetKeyPolicy("exec", KeyOptional)
+	f.RegisterProvider("websocket-generic", func(config *Config, apiKey string) (Provider, error) {
+		if config.Template == "" {
+			return nil, fmt.Errorf("websocket-generic requires a template file")
+		}
+		template, err := wsgeneric.LoadTemplate(config.Template)
 		if err != nil {
 			return nil, err
 		}
-		return &deepgramAdapter{provider: dgProvider}, nil
+		wgConfig := &wsgeneric.Config{
+			Template:   template,
+			SampleRate: config.SampleRate,
+			Channels:   config.Channels,
+			Encoding:   config.Encoding,
+			Language:   config.Language,
+			Model:      config.Model,
+			Interim:    config.Interim,
+			BaseURL:    config.BaseURL,
+			Options:    config.Options,
+		}
+		return wsgeneric.NewProvider(wgConfig, apiKey)
 	})
+	// Templates use the API key only when their protocol has one
+	f.SetKeyPolicy("websocket-generic", KeyOptional)
+	f.RegisterProvider("replay", func(config *Config, apiKey string) (Provider, error) {
+		if config.Session == "" {
+			return nil, fmt.Errorf("replay requires a session file")
+		}
+		if len(config.Options) > 0 {
+			return nil, fmt.Errorf("replay takes no provider options")
+		}
+		rpConfig := &replay.Config{}
+		run := max(config.SessionRun, 1)
+		sessions, err := session.Load(config.Session)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range sessions {
+			if s.Run == run {
+				rpConfig.Session = s
+			}
+		}
+		if rpConfig.Session == nil {
+			return nil, fmt.Errorf("%s has no run %d", config.Session, run)
+		}
+		// Events keep their place relative to the audio, so they are replayed as much
+		// faster than recorded as the audio is streamed
+		recorded := rpConfig.Session.RealtimeFactor
+		switch {
+		case config.RealtimeFactor == 0:
+		case recorded == 0:
+			return nil, fmt.Errorf("run %d of %s was streamed unpaced, replay it with a realtime factor of 0", run, config.Session)
+		default:
+			rpConfig.Speed = config.RealtimeFactor / recorded
+		}
+		return replay.NewProvider(rpConfig, apiKey)
+	})
+	f.SetKeyPolicy("replay", KeyOptional)
 	
 	return f
 }
@@ -82,6 +342,20 @@ func (f *Factory) RegisterProvider(name string, factory func(*Config, string) (P
 	f.providers[name] = factory
 }
 
+// SetKeyPolicy sets whether a provider needs an API key; providers need one by default
+func (f *Factory) SetKeyPolicy(name string, policy KeyPolicy) {
+	f.keyPolicies[name] = policy
+}
+
+// NeedsAPIKey reports whether a provider configured by config needs an API key
+func (f *Factory) NeedsAPIKey(name string, config *Config) bool {
+	policy, ok := f.keyPolicies[name]
+	if !ok {
+		policy = KeyRequired
+	}
+	return policy(config)
+}
+
 // CreateProvider creates a new provider instance
 func (f *Factory) CreateProvider(name string, config *Config, apiKey string) (Provider, error) {
 	factory, ok := f.providers[name]
@@ -0,0 +1,88 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/session"
+)
+
+// Config holds the provider configuration
+type Config struct {
+	Session *session.Session
+	Speed   float64 // 1 replays the recorded timing, 2 twice as fast, 0 without waiting
+}
+
+// clock tells the time and waits; tests replace the system clock
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the clock of the time package
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Provider implements a speech recognition provider re-emitting a recorded session
+type Provider struct {
+	config *Config
+	clock  clock
+}
+
+// NewProvider creates a new replay provider; replays take no API key
+func NewProvider(config *Config, apiKey string) (*Provider, error) {
+	if config.Session == nil {
+		return nil, fmt.Errorf("a recorded session is required")
+	}
+	if config.Speed < 0 {
+		return nil, fmt.Errorf("replay speed must not be negative, got %g", config.Speed)
+	}
+	return &Provider{config: config, clock: systemClock{}}, nil
+}
+
+// StreamAudio reads the audio as a provider would send it and re-emits the recorded
+// events at their recorded time from the start of the stream, divided by the speed.
+// It fails with the recorded error of the stream, if any
+func (p *Provider) StreamAudio(ctx context.Context, audioReader io.Reader, sink events.Sink) error {
+	start := p.clock.Now()
+	drained := make(chan error, 1)
+	go func() {
+		if _, err := io.Copy(io.Discard, audioReader); err != nil {
+			drained <- fmt.Errorf("failed to read audio data: %w", err)
+			return
+		}
+		drained <- nil
+	}()
+
+	recorded := p.config.Session
+	for _, e := range recorded.Events {
+		if p.config.Speed > 0 {
+			at := start.Add(time.Duration(float64(recorded.Elapsed(e.ReceivedAt)) / p.config.Speed))
+			select {
+			case <-ctx.Done():
+				return events.EmitError(sink, fmt.Errorf("stream interrupted: %w", ctx.Err()))
+			case <-p.clock.After(at.Sub(p.clock.Now())):
+			}
+		}
+		// The receive time and audio offset are those of the replay
+		e.ReceivedAt = p.clock.Now()
+		e.AudioOffset = 0
+		sink.Emit(e)
+	}
+
+	// Like a provider, the replay ends once the audio is sent
+	select {
+	case <-ctx.Done():
+		return events.EmitError(sink, fmt.Errorf("stream interrupted: %w", ctx.Err()))
+	case err := <-drained:
+		if err != nil {
+			return events.EmitError(sink, err)
+		}
+	}
+	return recorded.Err
+}
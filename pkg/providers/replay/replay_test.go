@@ -0,0 +1,121 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/session"
+)
+
+// recorded is a session of 3 events over 200ms that failed
+func recorded() *session.Session {
+	started := time.Now().Add(-time.Hour)
+	return &session.Session{
+		Header: session.Header{Started: started},
+		Events: []events.Event{
+			{Type: events.Opened, ReceivedAt: started},
+			{Type: events.Interim, ReceivedAt: started.Add(100 * time.Millisecond), Transcript: "hel", AudioOffset: time.Second},
+			{Type: events.Final, ReceivedAt: started.Add(200 * time.Millisecond), Transcript: "hello", Words: []events.Word{{Word: "hello", End: 0.2}}},
+		},
+		Err: errors.New("recorded failure"),
+	}
+}
+
+// fakeClock returns at once from waits, advancing its time by the duration waited
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d > 0 {
+		c.now = c.now.Add(d)
+	}
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// countingReader counts the bytes read from it
+type countingReader struct {
+	r    io.Reader
+	read int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.read += n
+	return n, err
+}
+
+func TestStreamAudio(t *testing.T) {
+	for _, speed := range []float64{0, 1, 2} {
+		provider, err := NewProvider(&Config{Session: recorded(), Speed: speed}, "")
+		if err != nil {
+			t.Fatalf("NewProvider failed: %v", err)
+		}
+		started := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+		provider.clock = &fakeClock{now: started}
+
+		audio := &countingReader{r: bytes.NewReader(make([]byte, 4096))}
+		log := events.NewLog(nil)
+		err = provider.StreamAudio(context.Background(), audio, log)
+		if err == nil || err.Error() != "recorded failure" {
+			t.Errorf("speed %g: expected the recorded error, got %v", speed, err)
+		}
+		if audio.read != 4096 {
+			t.Errorf("speed %g: read %d bytes of audio, want 4096", speed, audio.read)
+		}
+
+		got := log.Events()
+		if len(got) != 3 || got[1].Type != events.Interim || got[2].Transcript != "hello" || len(got[2].Words) != 1 {
+			t.Fatalf("speed %g: unexpected events %+v", speed, got)
+		}
+		if got[1].AudioOffset != 0 {
+			t.Errorf("speed %g: the recorded audio offset was kept", speed)
+		}
+		// Events come at their recorded time divided by the speed
+		want := []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond}
+		for i, e := range got {
+			if speed > 0 {
+				want[i] = time.Duration(float64(want[i]) / speed)
+			} else {
+				want[i] = 0
+			}
+			if elapsed := e.ReceivedAt.Sub(started); elapsed != want[i] {
+				t.Errorf("speed %g: event %d replayed after %v, want %v", speed, i, elapsed, want[i])
+			}
+		}
+	}
+}
+
+func TestStreamAudio_Errors(t *testing.T) {
+	if _, err := NewProvider(&Config{}, ""); err == nil {
+		t.Error("expected error without a session")
+	}
+	if _, err := NewProvider(&Config{Session: recorded(), Speed: -1}, ""); err == nil {
+		t.Error("expected error for a negative speed")
+	}
+
+	provider, _ := NewProvider(&Config{Session: recorded(), Speed: 0.01}, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := provider.StreamAudio(ctx, bytes.NewReader(nil), events.NewLog(nil))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the stream to be interrupted, got %v", err)
+	}
+}
@@ -0,0 +1,273 @@
+package speechmatics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers/internal/wsstream"
+	"github.com/gorilla/websocket"
+)
+
+// Config holds the provider configuration
+type Config struct {
+	SampleRate     int
+	Channels       int
+	Language       string     // BCP-47 or ISO-639-1 code, sent as ISO-639-1
+	Interim        bool       // ask for partial transcripts
+	Encoding       string     // encoding of the audio, linear16 or mulaw, defaults to linear16
+	BaseURL        string     // defaults to the endpoint of the region
+	Region         string     // defaults to DefaultRegion
+	MaxDelayMs     int        // longest delay before a word is final, 0 keeps the default
+	OperatingPoint string     // standard or enhanced, empty keeps the default
+	EndOfUtterance int        // silence in milliseconds triggering an end of utterance, 0 disables
+	Diarize        bool       // label the speaker of every word
+	Vocabulary     []string   // words and phrases to add to the vocabulary
+	Options        url.Values // extra transcription_config fields, JSON values or strings
+}
+
+// DefaultRegion is the region used when neither Config.BaseURL nor Config.Region is set
+const DefaultRegion = "eu2"
+
+// Provider implements the speech recognition provider using the Speechmatics real-time API
+type Provider struct {
+	apiKey string
+	config *Config
+}
+
+// NewProvider creates a new Speechmatics provider
+func NewProvider(config *Config, apiKey string) (*Provider, error) {
+	if config.Channels > 1 {
+		return nil, fmt.Errorf("Speechmatics streams mono audio only, got %d channels", config.Channels)
+	}
+	return &Provider{
+		apiKey: apiKey,
+		config: config,
+	}, nil
+}
+
+// message is a message received on the real-time API
+type message struct {
+	Message  string `json:"message"`
+	Metadata struct {
+		Transcript string `json:"transcript"`
+	} `json:"metadata"`
+	Results []result `json:"results"`
+	Type    string   `json:"type"`
+	Reason  string   `json:"reason"`
+}
+
+// result is a word or a punctuation mark, with times in seconds
+type result struct {
+	Type         string  `json:"type"`
+	StartTime    float64 `json:"start_time"`
+	EndTime      float64 `json:"end_time"`
+	Alternatives []struct {
+		Content    string  `json:"content"`
+		Confidence float64 `json:"confidence"`
+	} `json:"alternatives"`
+}
+
+// streamURL returns the WebSocket URL of the region or of the configured endpoint
+func (p *Provider) streamURL() (string, error) {
+	region := p.config.Region
+	if region == "" {
+		region = DefaultRegion
+	}
+	base := fmt.Sprintf("wss://%s.rt.speechmatics.com/v2", region)
+	if p.config.BaseURL != "" {
+		base = strings.TrimRight(p.config.BaseURL, "/")
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %w", err)
+	}
+	if err := wsstream.NormalizeScheme(u); err != nil {
+		return "", err
+	}
+	if u.Path == "" {
+		u.Path = "/v2"
+	}
+	return u.String(), nil
+}
+
+// startRecognition builds the StartRecognition message
+func (p *Provider) startRecognition() map[string]any {
+	encoding := "pcm_s16le"
+	if p.config.Encoding == "mulaw" {
+		encoding = "mulaw"
+	}
+	language, _, _ := strings.Cut(p.config.Language, "-")
+	if language == "" {
+		language = "en"
+	}
+
+	transcription := map[string]any{
+		"language":        strings.ToLower(language),
+		"enable_partials": p.config.Interim,
+	}
+	if p.config.MaxDelayMs > 0 {
+		transcription["max_delay"] = float64(p.config.MaxDelayMs) / 1000
+	}
+	if p.config.OperatingPoint != "" {
+		transcription["operating_point"] = p.config.OperatingPoint
+	}
+	if p.config.EndOfUtterance > 0 {
+		transcription["conversation_config"] = map[string]any{
+			"end_of_utterance_silence_trigger": float64(p.config.EndOfUtterance) / 1000,
+		}
+	}
+	if p.config.Diarize {
+		transcription["diarization"] = "speaker"
+	}
+	if len(p.config.Vocabulary) > 0 {
+		vocabulary := make([]map[string]string, len(p.config.Vocabulary))
+		for i, content := range p.config.Vocabulary {
+			vocabulary[i] = map[string]string{"content": content}
+		}
+		transcription["additional_vocab"] = vocabulary
+	}
+	for key, values := range p.config.Options {
+		var value any
+		if err := json.Unmarshal([]byte(values[0]), &value); err != nil {
+			value = values[0]
+		}
+		transcription[key] = value
+	}
+
+	return map[string]any{
+		"message": "StartRecognition",
+		"audio_format": map[string]any{
+			"type":        "raw",
+			"encoding":    encoding,
+			"sample_rate": p.config.SampleRate,
+		},
+		"transcription_config": transcription,
+	}
+}
+
+// StreamAudio starts a recognition, sends the audio chunk by chunk as it is paced by
+// the reader and emits partial transcripts as interim events and transcripts as final events
+func (p *Provider) StreamAudio(ctx context.Context, audioReader io.Reader, sink events.Sink) error {
+	wsURL, err := p.streamURL()
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+p.apiKey)
+
+	// Audio starts once the recognition has started
+	start, _ := json.Marshal(p.startRecognition())
+	conn, err := wsstream.Dial(ctx, sink, wsURL, header, start)
+	if err != nil {
+		return events.EmitError(sink, err)
+	}
+	defer conn.Close()
+	sink.Emit(events.Event{Type: events.Opened})
+	defer sink.Emit(events.Event{Type: events.Closed})
+
+	var started message
+	_, data, err := conn.Receive()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &started)
+	}
+	if err != nil {
+		return events.EmitError(sink, fmt.Errorf("failed to start recognition: %w", err))
+	}
+	if started.Message != "RecognitionStarted" {
+		return events.EmitError(sink, fmt.Errorf("failed to start recognition: %s %s: %s", started.Message, started.Type, started.Reason))
+	}
+
+	sendErr := make(chan error, 1)
+	go func() {
+		sendErr <- sendAudio(conn, audioReader)
+	}()
+
+	for {
+		_, data, err := conn.Receive()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return events.EmitError(sink, err)
+		}
+		receivedAt := time.Now()
+
+		var msg message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return events.EmitError(sink, fmt.Errorf("failed to decode message: %w", err))
+		}
+		switch msg.Message {
+		case "AddPartialTranscript", "AddTranscript":
+			eventType := events.Final
+			if msg.Message == "AddPartialTranscript" {
+				eventType = events.Interim
+			}
+			// Transcripts are sent for silence too
+			words := resultWords(msg.Results)
+			if len(words) == 0 {
+				continue
+			}
+			sink.Emit(events.Event{
+				Type:       eventType,
+				ReceivedAt: receivedAt,
+				Transcript: strings.TrimSpace(msg.Metadata.Transcript),
+				Words:      words,
+			})
+		case "EndOfUtterance":
+			sink.Emit(events.Event{Type: events.UtteranceEnd, ReceivedAt: receivedAt})
+		case "Error":
+			return events.EmitError(sink, fmt.Errorf("provider error %s: %s", msg.Type, msg.Reason))
+		}
+		if msg.Message == "EndOfTranscript" {
+			break
+		}
+	}
+
+	if err := <-sendErr; err != nil {
+		return events.EmitError(sink, err)
+	}
+	return nil
+}
+
+// resultWords returns the words of the results, leaving punctuation out
+func resultWords(results []result) []events.Word {
+	words := make([]events.Word, 0, len(results))
+	for _, r := range results {
+		if r.Type != "word" || len(r.Alternatives) == 0 {
+			continue
+		}
+		alt := r.Alternatives[0]
+		words = append(words, events.Word{Word: alt.Content, Start: r.StartTime, End: r.EndTime, Confidence: alt.Confidence})
+	}
+	return words
+}
+
+// sendAudio writes each chunk as it is paced by the reader, then ends the stream
+// with the number of the last chunk
+func sendAudio(conn *wsstream.Conn, audioReader io.Reader) error {
+	seqNo := 0
+	err := wsstream.SendChunks(audioReader, func(chunk []byte) error {
+		seqNo++
+		return conn.SendBinary(chunk)
+	})
+	if err != nil {
+		return err
+	}
+
+	end, _ := json.Marshal(map[string]any{"message": "EndOfStream", "last_seq_no": seqNo})
+	if err := conn.WriteMessage(websocket.TextMessage, end); err != nil {
+		return fmt.Errorf("failed to end stream: %w", err)
+	}
+	return nil
+}
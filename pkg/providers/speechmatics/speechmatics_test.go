@@ -0,0 +1,159 @@
+package speechmatics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers/internal/providertest"
+	"github.com/gorilla/websocket"
+)
+
+// realtimeStandIn emulates the real-time API: a partial transcript per audio message,
+// then the transcript, the end of utterance and the end of the transcript at the end of the stream
+func realtimeStandIn(t *testing.T, received *bytes.Buffer) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		var start struct {
+			Message     string `json:"message"`
+			AudioFormat struct {
+				Encoding   string `json:"encoding"`
+				SampleRate int    `json:"sample_rate"`
+			} `json:"audio_format"`
+			TranscriptionConfig map[string]any `json:"transcription_config"`
+		}
+		if err := conn.ReadJSON(&start); err != nil {
+			t.Errorf("read failed: %v", err)
+			return
+		}
+		config := start.TranscriptionConfig
+		if start.Message != "StartRecognition" || start.AudioFormat.SampleRate != 16000 || config["language"] != "en" ||
+			config["max_delay"] != 0.7 || config["operating_point"] != "enhanced" || config["max_delay_mode"] != "fixed" {
+			conn.WriteJSON(map[string]any{"message": "Error", "type": "invalid_config", "reason": "unexpected configuration"})
+			return
+		}
+		conn.WriteJSON(map[string]any{"message": "RecognitionStarted", "id": "session"})
+
+		word := func(start float64) map[string]any {
+			return map[string]any{"type": "word", "start_time": start, "end_time": start + 0.4,
+				"alternatives": []map[string]any{{"content": "hello", "confidence": 0.9}}}
+		}
+		var results []map[string]any
+		for seqNo := 0; ; {
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				t.Errorf("read failed: %v", err)
+				return
+			}
+			if msgType == websocket.TextMessage {
+				var end struct {
+					Message   string `json:"message"`
+					LastSeqNo int    `json:"last_seq_no"`
+				}
+				json.Unmarshal(data, &end)
+				if end.Message != "EndOfStream" || end.LastSeqNo != seqNo {
+					t.Errorf("unexpected end of stream %s after %d chunks", data, seqNo)
+				}
+				results = append(results, map[string]any{"type": "punctuation", "start_time": 0.9, "end_time": 0.9,
+					"alternatives": []map[string]any{{"content": "."}}})
+				conn.WriteJSON(map[string]any{"message": "AddTranscript", "metadata": map[string]any{"transcript": "hello hello."}, "results": results})
+				conn.WriteJSON(map[string]any{"message": "EndOfUtterance"})
+				conn.WriteJSON(map[string]any{"message": "AddTranscript", "metadata": map[string]any{"transcript": ""}, "results": []any{}})
+				conn.WriteJSON(map[string]any{"message": "EndOfTranscript"})
+				return
+			}
+
+			received.Write(data)
+			seqNo++
+			conn.WriteJSON(map[string]any{"message": "AudioAdded", "seq_no": seqNo})
+			results = append(results, word(float64(seqNo-1)*0.5))
+			conn.WriteJSON(map[string]any{"message": "AddPartialTranscript", "metadata": map[string]any{"transcript": "hello"}, "results": results})
+		}
+	}))
+}
+
+func TestStreamAudio(t *testing.T) {
+	var received bytes.Buffer
+	srv := realtimeStandIn(t, &received)
+	defer srv.Close()
+
+	provider, err := NewProvider(&Config{
+		SampleRate:     16000,
+		Channels:       1,
+		Language:       "en-US",
+		Interim:        true,
+		BaseURL:        srv.URL,
+		MaxDelayMs:     700,
+		OperatingPoint: "enhanced",
+		EndOfUtterance: 500,
+		Options:        map[string][]string{"max_delay_mode": {"fixed"}},
+	}, "test-key")
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+
+	audio := bytes.Repeat([]byte{1, 2}, 1000)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	log := events.NewLog(nil)
+	if err := provider.StreamAudio(ctx, &providertest.ChunkReader{Data: audio, Size: 1000}, log); err != nil {
+		t.Fatalf("StreamAudio failed: %v", err)
+	}
+	if !bytes.Equal(received.Bytes(), audio) {
+		t.Errorf("server received %d bytes, want %d", received.Len(), len(audio))
+	}
+
+	// The empty transcript of the trailing silence is left out
+	got := log.Events()
+	providertest.CheckTypes(t, got, []events.Type{events.Opened, events.Interim, events.Interim, events.Final, events.UtteranceEnd, events.Closed})
+	final := got[3]
+	if final.Transcript != "hello hello." || len(final.Words) != 2 || final.Words[1].Start != 0.5 || final.Words[1].End != 0.9 {
+		t.Errorf("unexpected final %+v", final)
+	}
+}
+
+func TestStreamAudio_Errors(t *testing.T) {
+	if _, err := NewProvider(&Config{SampleRate: 16000, Channels: 2}, "test-key"); err == nil {
+		t.Error("expected error for stereo audio")
+	}
+
+	provider, _ := NewProvider(&Config{Region: "us2"}, "test-key")
+	if got, _ := provider.streamURL(); got != "wss://us2.rt.speechmatics.com/v2" {
+		t.Errorf("unexpected regional URL %s", got)
+	}
+
+	var received bytes.Buffer
+	srv := realtimeStandIn(t, &received)
+	defer srv.Close()
+
+	provider, _ = NewProvider(&Config{SampleRate: 16000, BaseURL: srv.URL}, "wrong-key")
+	err := provider.StreamAudio(context.Background(), &providertest.ChunkReader{Data: []byte{0, 0}, Size: 2}, events.NewLog(nil))
+	if err == nil || !strings.Contains(err.Error(), "API error 401") {
+		t.Errorf("expected API error 401, got %v", err)
+	}
+
+	// A rejected configuration fails the start of the recognition
+	provider, _ = NewProvider(&Config{SampleRate: 16000, BaseURL: srv.URL}, "test-key")
+	err = provider.StreamAudio(context.Background(), &providertest.ChunkReader{Data: []byte{0, 0}, Size: 2}, events.NewLog(nil))
+	if err == nil || !strings.Contains(err.Error(), "Error invalid_config: unexpected configuration") {
+		t.Errorf("expected the configuration error, got %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package vosk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers/internal/wsstream"
+	"github.com/gorilla/websocket"
+)
+
+// Config holds the provider configuration
+type Config struct {
+	SampleRate int
+	Channels   int
+	Interim    bool
+	BaseURL    string // defaults to DefaultBaseURL
+}
+
+// DefaultBaseURL is the address of a local vosk-server used when Config.BaseURL is empty
+const DefaultBaseURL = "ws://localhost:2700"
+
+// Provider implements the speech recognition provider using a Vosk (Kaldi) WebSocket server
+type Provider struct {
+	config *Config
+}
+
+// NewProvider creates a new Vosk provider; Vosk servers take no API key
+func NewProvider(config *Config, apiKey string) (*Provider, error) {
+	if config.Channels > 1 {
+		return nil, fmt.Errorf("Vosk recognizes mono audio only, got %d channels", config.Channels)
+	}
+	return &Provider{config: config}, nil
+}
+
+// message is a message of the server: a partial hypothesis or a result
+type message struct {
+	Partial *string `json:"partial"`
+	Text    *string `json:"text"`
+	Result  []word  `json:"result"`
+}
+
+// word is a word of a result, with times in seconds
+type word struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Conf  float64 `json:"conf"`
+}
+
+// streamURL returns the WebSocket URL of the server
+func (p *Provider) streamURL() (string, error) {
+	base := DefaultBaseURL
+	if p.config.BaseURL != "" {
+		base = strings.TrimRight(p.config.BaseURL, "/")
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %w", err)
+	}
+	if err := wsstream.NormalizeScheme(u); err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// StreamAudio sends the audio chunk by chunk as it is paced by the reader and
+// emits partial hypotheses as interim events and results as final events
+func (p *Provider) StreamAudio(ctx context.Context, audioReader io.Reader, sink events.Sink) error {
+	wsURL, err := p.streamURL()
+	if err != nil {
+		return err
+	}
+
+	config, _ := json.Marshal(map[string]any{"config": map[string]any{"sample_rate": p.config.SampleRate, "words": 1}})
+	conn, err := wsstream.Dial(ctx, sink, wsURL, nil, config)
+	if err != nil {
+		return events.EmitError(sink, err)
+	}
+	defer conn.Close()
+	sink.Emit(events.Event{Type: events.Opened})
+	defer sink.Emit(events.Event{Type: events.Closed})
+
+	sendErr := make(chan error, 1)
+	go func() {
+		sendErr <- sendAudio(conn, audioReader)
+	}()
+
+	for {
+		_, data, err := conn.Receive()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return events.EmitError(sink, err)
+		}
+		receivedAt := time.Now()
+
+		var msg message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return events.EmitError(sink, fmt.Errorf("failed to decode message: %w", err))
+		}
+
+		// The server answers every audio message, mostly with unchanged or empty partials
+		if msg.Partial != nil {
+			if !p.config.Interim || *msg.Partial == "" {
+				continue
+			}
+			fields := strings.Fields(*msg.Partial)
+			words := make([]events.Word, len(fields))
+			for i, f := range fields {
+				words[i] = events.Word{Word: f}
+			}
+			sink.Emit(events.Event{Type: events.Interim, ReceivedAt: receivedAt, Transcript: *msg.Partial, Words: words})
+			continue
+		}
+		if msg.Text == nil || *msg.Text == "" {
+			continue
+		}
+		words := make([]events.Word, len(msg.Result))
+		for i, w := range msg.Result {
+			words[i] = events.Word{Word: w.Word, Start: w.Start, End: w.End, Confidence: w.Conf}
+		}
+		sink.Emit(events.Event{Type: events.Final, ReceivedAt: receivedAt, Transcript: *msg.Text, Words: words})
+	}
+
+	if err := <-sendErr; err != nil {
+		return events.EmitError(sink, err)
+	}
+	return nil
+}
+
+// sendAudio writes each chunk as it is paced by the reader, then the end of the stream
+func sendAudio(conn *wsstream.Conn, audioReader io.Reader) error {
+	if err := wsstream.SendChunks(audioReader, conn.SendBinary); err != nil {
+		return err
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"eof" : 1}`)); err != nil {
+		return fmt.Errorf("failed to end stream: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package vosk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers/internal/providertest"
+	"github.com/gorilla/websocket"
+)
+
+// voskStub emulates vosk-server: an answer per audio message, a result every
+// second message, and the last result and the closing of the connection on EOF
+func voskStub(t *testing.T, received *bytes.Buffer) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		var config struct {
+			Config struct {
+				SampleRate int `json:"sample_rate"`
+				Words      int `json:"words"`
+			} `json:"config"`
+		}
+		if err := conn.ReadJSON(&config); err != nil || config.Config.SampleRate != 16000 || config.Config.Words != 1 {
+			t.Errorf("unexpected config %+v (%v)", config, err)
+			return
+		}
+
+		var start float64
+		for n := 1; ; n++ {
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				t.Errorf("read failed: %v", err)
+				return
+			}
+			if msgType == websocket.TextMessage {
+				if !strings.Contains(string(data), `"eof"`) {
+					t.Errorf("unexpected message %s", data)
+				}
+				conn.WriteMessage(websocket.TextMessage, []byte(`{"text": ""}`))
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+
+			received.Write(data)
+			if n%2 == 1 {
+				conn.WriteMessage(websocket.TextMessage, []byte(`{"partial": "hello"}`))
+				continue
+			}
+			result, _ := json.Marshal(map[string]any{
+				"result": []map[string]any{{"conf": 0.95, "start": start, "end": start + 0.4, "word": "hello"}},
+				"text":   "hello",
+			})
+			conn.WriteMessage(websocket.TextMessage, result)
+			start += 0.5
+		}
+	}))
+}
+
+func TestStreamAudio(t *testing.T) {
+	var received bytes.Buffer
+	srv := voskStub(t, &received)
+	defer srv.Close()
+
+	provider, err := NewProvider(&Config{SampleRate: 16000, Interim: true, BaseURL: srv.URL}, "")
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+
+	audio := bytes.Repeat([]byte{1, 2}, 2000)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	log := events.NewLog(nil)
+	if err := provider.StreamAudio(ctx, &providertest.ChunkReader{Data: audio, Size: 1000}, log); err != nil {
+		t.Fatalf("StreamAudio failed: %v", err)
+	}
+	if !bytes.Equal(received.Bytes(), audio) {
+		t.Errorf("server received %d bytes, want %d", received.Len(), len(audio))
+	}
+
+	// The empty result after EOF is left out
+	got := log.Events()
+	providertest.CheckTypes(t, got, []events.Type{events.Opened, events.Interim, events.Final, events.Interim, events.Final, events.Closed})
+	final := got[4]
+	if final.Transcript != "hello" || len(final.Words) != 1 || final.Words[0].Start != 0.5 || final.Words[0].Confidence != 0.95 {
+		t.Errorf("unexpected final %+v", final)
+	}
+
+	// The handshake with the configuration and every raw message are recorded
+	requests, messages := log.Requests(), log.Messages()
+	if len(requests) != 1 || !strings.HasPrefix(requests[0].URL, "ws://") || !strings.Contains(string(requests[0].Body), `"sample_rate":16000`) {
+		t.Errorf("unexpected requests %+v", requests)
+	}
+	if len(messages) != 5 || string(messages[0].Data) != `{"partial": "hello"}` || string(messages[4].Data) != `{"text": ""}` {
+		t.Errorf("unexpected messages %+v", messages)
+	}
+}
+
+func TestStreamAudio_Errors(t *testing.T) {
+	if _, err := NewProvider(&Config{SampleRate: 16000, Channels: 2}, ""); err == nil {
+		t.Error("expected error for stereo audio")
+	}
+
+	provider, _ := NewProvider(&Config{SampleRate: 16000, BaseURL: "ftp://localhost"}, "")
+	if err := provider.StreamAudio(context.Background(), &providertest.ChunkReader{}, events.NewLog(nil)); err == nil {
+		t.Error("expected error for an unsupported scheme")
+	}
+
+	// The server closes the connection when the config is invalid
+	closing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.ReadMessage()
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "invalid sample rate"))
+	}))
+	defer closing.Close()
+	provider, _ = NewProvider(&Config{SampleRate: 1, BaseURL: closing.URL}, "")
+	err := provider.StreamAudio(context.Background(), &providertest.ChunkReader{Data: []byte{0, 0}, Size: 2}, events.NewLog(nil))
+	if err == nil || !strings.Contains(err.Error(), "code 1011: invalid sample rate") {
+		t.Errorf("expected the close reason, got %v", err)
+	}
+}
@@ -0,0 +1,262 @@
+package whisperserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/audio"
+	"github.com/elishowk/speech_latency/pkg/events"
+)
+
+// Config holds the provider configuration
+type Config struct {
+	SampleRate int
+	Channels   int
+	Language   string        // BCP-47 or ISO-639-1 code, sent as ISO-639-1
+	Interim    bool          // report the hypotheses of the window as interim events
+	BaseURL    string        // server address, with or without the inference path, defaults to DefaultBaseURL
+	Step       time.Duration // audio between two submissions of the window, 0 uploads the whole file once
+	Window     time.Duration // window length after which committed audio is dropped, defaults to DefaultWindow
+	Options    url.Values    // extra form fields, replacing those of the same name
+}
+
+const (
+	// DefaultBaseURL is the address of a local whisper.cpp server used when Config.BaseURL is empty
+	DefaultBaseURL = "http://localhost:8080"
+	// DefaultStep is the step of the sliding window suggested for live runs
+	DefaultStep = time.Second
+	// DefaultWindow is the window length used when Config.Window is zero
+	DefaultWindow = 15 * time.Second
+	// inferencePath is the transcription endpoint of the whisper.cpp server
+	inferencePath = "/inference"
+)
+
+// requestTimeout bounds a transcription request; local models may take a while on long files
+const requestTimeout = 5 * time.Minute
+
+// Provider implements the speech recognition provider for whisper.cpp's HTTP server,
+// uploading the whole file or re-submitting a sliding window to emulate streaming
+type Provider struct {
+	apiKey string
+	config *Config
+	client *http.Client
+}
+
+// NewProvider creates a new whisper.cpp server provider; the API key is optional,
+// for servers behind an authenticating proxy
+func NewProvider(config *Config, apiKey string) (*Provider, error) {
+	if config.Step < 0 || config.Window < 0 {
+		return nil, fmt.Errorf("step and window must not be negative")
+	}
+	return &Provider{
+		apiKey: apiKey,
+		config: config,
+		client: &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// inference is the verbose_json response body, with times in seconds
+type inference struct {
+	Text     string `json:"text"`
+	Error    string `json:"error"`
+	Segments []struct {
+		Text  string  `json:"text"`
+		Start float64 `json:"start"`
+		End   float64 `json:"end"`
+		Words []struct {
+			Word        string  `json:"word"`
+			Start       float64 `json:"start"`
+			End         float64 `json:"end"`
+			Probability float64 `json:"probability"`
+		} `json:"words"`
+	} `json:"segments"`
+}
+
+// inferenceURL returns the endpoint, keeping the path of the base URL when it has one
+func (p *Provider) inferenceURL() (string, error) {
+	base := DefaultBaseURL
+	if p.config.BaseURL != "" {
+		base = strings.TrimRight(p.config.BaseURL, "/")
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Path == "" {
+		u.Path = inferencePath
+	}
+	return u.String(), nil
+}
+
+// StreamAudio transcribes the audio, once or window by window, and emits the transcript events to sink
+func (p *Provider) StreamAudio(ctx context.Context, audioReader io.Reader, sink events.Sink) error {
+	endpoint, err := p.inferenceURL()
+	if err != nil {
+		return err
+	}
+	if p.config.Step > 0 {
+		return p.streamWindows(ctx, endpoint, audioReader, sink)
+	}
+
+	// The whole file is uploaded at once, header included
+	var audioData []byte
+	if chunkedReader, ok := audioReader.(interface{ GetFile() io.Reader }); ok {
+		audioData, err = io.ReadAll(chunkedReader.GetFile())
+	} else {
+		audioData, err = io.ReadAll(audioReader)
+		audioData = append(audio.WAVHeader(p.config.SampleRate, p.config.Channels, 2, int64(len(audioData))), audioData...)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read audio data: %w", err)
+	}
+
+	sink.Emit(events.Event{Type: events.Opened})
+	defer sink.Emit(events.Event{Type: events.Closed})
+
+	words, err := p.transcribe(ctx, endpoint, audioData, sink)
+	if err != nil {
+		return events.EmitError(sink, err)
+	}
+	sink.Emit(events.Event{Type: events.Final, ReceivedAt: time.Now(), Transcript: joinWords(words), Words: words})
+	return nil
+}
+
+// transcribe posts a WAV file, recording the exchange to sink, and returns its words,
+// timed from the start of the file
+func (p *Provider) transcribe(ctx context.Context, endpoint string, wav []byte, sink events.Sink) ([]events.Word, error) {
+	body, contentType, err := p.form(wav)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	events.RecordRequest(sink, events.Request{URL: req.URL.String(), Header: req.Header})
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	events.RecordMessage(sink, respBody)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var result inference
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("provider error: %s", result.Error)
+	}
+	return result.words(), nil
+}
+
+// form builds the multipart body asking for segments with word timestamps
+func (p *Provider) form(wav []byte) (io.Reader, string, error) {
+	fields := url.Values{}
+	fields.Set("response_format", "verbose_json")
+	fields.Set("temperature", "0")
+	if p.config.Language != "" {
+		language, _, _ := strings.Cut(p.config.Language, "-")
+		fields.Set("language", strings.ToLower(language))
+	}
+	for key, values := range p.config.Options {
+		fields[key] = values
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for key, values := range fields {
+		for _, value := range values {
+			if err := w.WriteField(key, value); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	part, err := w.CreateFormFile("file", "audio.wav")
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(wav); err != nil {
+		return nil, "", err
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return &body, w.FormDataContentType(), nil
+}
+
+// words flattens the segments into words: whisper tokens starting without a space
+// continue the previous word, and segments without tokens are spread evenly;
+// non-speech annotations such as [BLANK_AUDIO] are left out
+func (r *inference) words() []events.Word {
+	var words []events.Word
+	for _, s := range r.Segments {
+		if len(s.Words) == 0 {
+			fields := strings.Fields(s.Text)
+			for i, f := range fields {
+				step := (s.End - s.Start) / float64(len(fields))
+				words = append(words, events.Word{Word: f, Start: s.Start + float64(i)*step, End: s.Start + float64(i+1)*step})
+			}
+			continue
+		}
+
+		first := len(words)
+		for _, w := range s.Words {
+			text := strings.TrimSpace(w.Word)
+			if text == "" {
+				continue
+			}
+			if len(words) > first && !strings.HasPrefix(w.Word, " ") {
+				last := &words[len(words)-1]
+				last.Word += text
+				last.End = w.End
+				last.Confidence = min(last.Confidence, w.Probability)
+				continue
+			}
+			words = append(words, events.Word{Word: text, Start: w.Start, End: w.End, Confidence: w.Probability})
+		}
+	}
+
+	speech := words[:0]
+	for _, w := range words {
+		if !isAnnotation(w.Word) {
+			speech = append(speech, w)
+		}
+	}
+	return speech
+}
+
+// isAnnotation reports whether a word is a non-speech annotation, e.g. [BLANK_AUDIO] or (music)
+func isAnnotation(word string) bool {
+	return (strings.HasPrefix(word, "[") && strings.HasSuffix(word, "]")) ||
+		(strings.HasPrefix(word, "(") && strings.HasSuffix(word, ")"))
+}
+
+// joinWords joins the words into a transcript
+func joinWords(words []events.Word) string {
+	texts := make([]string, len(words))
+	for i, w := range words {
+		texts[i] = w.Word
+	}
+	return strings.Join(texts, " ")
+}
@@ -0,0 +1,190 @@
+package whisperserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+)
+
+const (
+	testRate    = 8000
+	wordSamples = testRate / 2 // every word fills half a second of audio
+)
+
+// inferenceStub emulates whisper.cpp's /inference: the test audio holds one constant
+// sample value per word, and a word is recognized once most of its audio is in the file
+func inferenceStub(t *testing.T, submissions *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/inference" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.FormValue("response_format") != "verbose_json" || r.FormValue("language") != "en" {
+			http.Error(w, "bad parameters", http.StatusBadRequest)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		wav, _ := io.ReadAll(file)
+		*submissions++
+
+		samples := make([]int16, (len(wav)-44)/2)
+		binary.Read(bytes.NewReader(wav[44:]), binary.LittleEndian, samples)
+		var words []map[string]any
+		for i := 0; i < len(samples); {
+			j := i
+			for j < len(samples) && samples[j] == samples[i] {
+				j++
+			}
+			if j-i >= wordSamples*4/5 {
+				start := float64(i) / testRate
+				words = append(words, map[string]any{"word": fmt.Sprintf(" w%d", samples[i]), "start": start, "end": start + 0.4, "probability": 0.9})
+			}
+			i = j
+		}
+		json.NewEncoder(w).Encode(map[string]any{"text": "", "segments": []map[string]any{{"text": "", "words": words}}})
+	}))
+}
+
+// pacedReader returns at most a word of audio per read, pausing between words
+type pacedReader struct {
+	words   int
+	read    int
+	pending []byte
+}
+
+func (r *pacedReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		if r.read == r.words {
+			return 0, io.EOF
+		}
+		time.Sleep(5 * time.Millisecond)
+		r.read++
+		for i := 0; i < wordSamples; i++ {
+			r.pending = binary.LittleEndian.AppendUint16(r.pending, uint16(r.read))
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func TestStreamAudio_SlidingWindow(t *testing.T) {
+	var submissions int
+	srv := inferenceStub(t, &submissions)
+	defer srv.Close()
+
+	provider, err := NewProvider(&Config{
+		SampleRate: testRate,
+		Channels:   1,
+		Language:   "en-US",
+		Interim:    true,
+		BaseURL:    srv.URL,
+		Step:       500 * time.Millisecond,
+		Window:     2 * time.Second,
+	}, "")
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	log := events.NewLog(nil)
+	if err := provider.StreamAudio(ctx, &pacedReader{words: 10}, log); err != nil {
+		t.Fatalf("StreamAudio failed: %v", err)
+	}
+	if submissions < 2 {
+		t.Errorf("expected the window to be submitted repeatedly, got %d submissions", submissions)
+	}
+
+	// Every word is final exactly once, in order and timed from the start of the stream
+	var finals []events.Word
+	var interims int
+	for _, e := range log.Events() {
+		switch e.Type {
+		case events.Final:
+			finals = append(finals, e.Words...)
+		case events.Interim:
+			interims++
+		case events.Error:
+			t.Errorf("unexpected error: %v", e.Err)
+		}
+	}
+	if interims == 0 {
+		t.Error("expected interim hypotheses")
+	}
+	if len(finals) != 10 {
+		t.Fatalf("expected 10 final words, got %+v", finals)
+	}
+	for i, w := range finals {
+		if w.Word != fmt.Sprintf("w%d", i+1) || w.Start != float64(i)*0.5 {
+			t.Errorf("final word %d = %+v", i, w)
+		}
+	}
+}
+
+func TestStreamAudio_Upload(t *testing.T) {
+	var submissions int
+	srv := inferenceStub(t, &submissions)
+	defer srv.Close()
+
+	provider, _ := NewProvider(&Config{SampleRate: testRate, Channels: 1, Language: "en", BaseURL: srv.URL}, "")
+	log := events.NewLog(nil)
+	if err := provider.StreamAudio(context.Background(), &pacedReader{words: 3}, log); err != nil {
+		t.Fatalf("StreamAudio failed: %v", err)
+	}
+	got := log.Events()
+	if submissions != 1 || len(got) != 3 || got[1].Type != events.Final || got[1].Transcript != "w1 w2 w3" {
+		t.Errorf("expected a single final transcript, got %d submissions and %+v", submissions, got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "failed to load model", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	provider, _ = NewProvider(&Config{SampleRate: testRate, Channels: 1, BaseURL: failing.URL, Step: time.Second}, "")
+	err := provider.StreamAudio(context.Background(), &pacedReader{words: 3}, events.NewLog(nil))
+	if err == nil || !strings.Contains(err.Error(), "API error 500: failed to load model") {
+		t.Errorf("expected API error 500, got %v", err)
+	}
+}
+
+func TestInferenceWords(t *testing.T) {
+	var result inference
+	json.Unmarshal([]byte(`{"segments":[
+		{"text":" Hello world.","start":0,"end":1,"words":[
+			{"word":" Hel","start":0,"end":0.2,"probability":0.9},
+			{"word":"lo","start":0.2,"end":0.4,"probability":0.7},
+			{"word":" world.","start":0.5,"end":1,"probability":0.8}]},
+		{"text":" [BLANK_AUDIO]","start":1,"end":2},
+		{"text":" no tokens","start":2,"end":3}]}`), &result)
+
+	words := result.words()
+	want := []events.Word{
+		{Word: "Hello", Start: 0, End: 0.4, Confidence: 0.7},
+		{Word: "world.", Start: 0.5, End: 1, Confidence: 0.8},
+		{Word: "no", Start: 2, End: 2.5},
+		{Word: "tokens", Start: 2.5, End: 3},
+	}
+	if len(words) != len(want) {
+		t.Fatalf("words() = %+v", words)
+	}
+	for i := range want {
+		if words[i] != want[i] {
+			t.Errorf("word %d = %+v, want %+v", i, words[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package whisperserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/elishowk/speech_latency/pkg/audio"
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers/internal/wsstream"
+)
+
+// pcmBuffer collects the audio as it is paced by the reader
+type pcmBuffer struct {
+	mu   sync.Mutex
+	data []byte
+	err  error // io.EOF once all the audio has been read
+	more chan struct{}
+}
+
+// fill reads the audio into the buffer until its end
+func (b *pcmBuffer) fill(r io.Reader) {
+	buf := make([]byte, wsstream.ReadBufferSize)
+	for {
+		n, err := r.Read(buf)
+		b.mu.Lock()
+		b.data = append(b.data, buf[:n]...)
+		if err != nil {
+			b.err = err
+		}
+		b.mu.Unlock()
+		select {
+		case b.more <- struct{}{}:
+		default:
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// wait returns the audio once it holds at least n bytes or has been read entirely,
+// with io.EOF in the latter case
+func (b *pcmBuffer) wait(ctx context.Context, n int) ([]byte, error) {
+	for {
+		b.mu.Lock()
+		data, err := b.data, b.err
+		b.mu.Unlock()
+		if len(data) >= n || err != nil {
+			return data, err
+		}
+		select {
+		case <-b.more:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// streamWindows re-submits the window of audio every step, as the audio is paced
+// by the reader. Words on which two successive hypotheses agree are final, the rest
+// is interim; once the window is full, the audio before the last final word is dropped,
+// or the whole window is final when no word was agreed on. The hypothesis of the last
+// window is final.
+func (p *Provider) streamWindows(ctx context.Context, endpoint string, audioReader io.Reader, sink events.Sink) error {
+	frameSize := p.config.Channels * 2
+	bytesPerSecond := float64(p.config.SampleRate * frameSize)
+	stepBytes := max(int(p.config.Step.Seconds()*bytesPerSecond)/frameSize*frameSize, frameSize)
+	window := p.config.Window
+	if window == 0 {
+		window = DefaultWindow
+	}
+
+	buffer := &pcmBuffer{more: make(chan struct{}, 1)}
+	go buffer.fill(audioReader)
+
+	sink.Emit(events.Event{Type: events.Opened})
+	defer sink.Emit(events.Event{Type: events.Closed})
+
+	var (
+		start     int           // first byte of the window
+		submitted int           // bytes of audio in the last submission
+		committed float64       // end of the last final word, in seconds
+		previous  []events.Word // words of the last hypothesis that are not final
+	)
+	for {
+		data, err := buffer.wait(ctx, submitted+stepBytes)
+		if ctx.Err() != nil {
+			return events.EmitError(sink, fmt.Errorf("stream interrupted: %w", ctx.Err()))
+		}
+		done := err == io.EOF
+		if err != nil && !done {
+			return events.EmitError(sink, fmt.Errorf("failed to read audio data: %w", err))
+		}
+		if len(data) == start {
+			return nil
+		}
+
+		wav := append(audio.WAVHeader(p.config.SampleRate, p.config.Channels, 2, int64(len(data)-start)), data[start:]...)
+		words, err := p.transcribe(ctx, endpoint, wav, sink)
+		if err != nil {
+			if ctx.Err() != nil {
+				return events.EmitError(sink, fmt.Errorf("stream interrupted: %w", ctx.Err()))
+			}
+			return events.EmitError(sink, err)
+		}
+		receivedAt := time.Now()
+		submitted = len(data)
+
+		// Words are timed from the start of the stream, those already final are left out
+		offset := float64(start) / bytesPerSecond
+		var hypothesis []events.Word
+		for _, w := range words {
+			w.Start += offset
+			w.End += offset
+			if (w.Start+w.End)/2 >= committed {
+				hypothesis = append(hypothesis, w)
+			}
+		}
+
+		if done {
+			if len(hypothesis) > 0 {
+				sink.Emit(events.Event{Type: events.Final, ReceivedAt: receivedAt, Transcript: joinWords(hypothesis), Words: hypothesis})
+			}
+			return nil
+		}
+
+		if n := agreement(previous, hypothesis); n > 0 {
+			sink.Emit(events.Event{Type: events.Final, ReceivedAt: receivedAt, Transcript: joinWords(hypothesis[:n]), Words: hypothesis[:n]})
+			committed = hypothesis[n-1].End
+			hypothesis = hypothesis[n:]
+		}
+		if p.config.Interim && len(hypothesis) > 0 {
+			sink.Emit(events.Event{Type: events.Interim, ReceivedAt: receivedAt, Transcript: joinWords(hypothesis), Words: hypothesis})
+		}
+		previous = hypothesis
+
+		if float64(len(data)-start)/bytesPerSecond < window.Seconds() {
+			continue
+		}
+		if committedByte := int(committed*bytesPerSecond) / frameSize * frameSize; committedByte > start {
+			start = min(committedByte, len(data))
+			continue
+		}
+		if len(hypothesis) > 0 {
+			sink.Emit(events.Event{Type: events.Final, ReceivedAt: receivedAt, Transcript: joinWords(hypothesis), Words: hypothesis})
+		}
+		start, committed, previous = len(data), float64(len(data))/bytesPerSecond, nil
+	}
+}
+
+// agreement returns the number of leading words two hypotheses share, ignoring case
+// and punctuation
+func agreement(a, b []events.Word) int {
+	n := 0
+	for n < len(a) && n < len(b) && normalize(a[n].Word) == normalize(b[n].Word) {
+		n++
+	}
+	return n
+}
+
+// normalize lowercases a word and strips its punctuation
+func normalize(word string) string {
+	return strings.ToLower(strings.TrimFunc(word, unicode.IsPunct))
+}
@@ -0,0 +1,187 @@
+package wsgeneric
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Selector picks a value out of a decoded JSON message with a JSONPath-style
+// expression: an optional $ root, then .name fields and [index] array elements,
+// negative indexes counting from the end, e.g. $.channel.alternatives[0].words
+type Selector struct {
+	expr  string
+	steps []step
+}
+
+// step is a field name, or an array index when name is empty
+type step struct {
+	name  string
+	index int
+}
+
+// ParseSelector compiles a selector expression
+func ParseSelector(expr string) (Selector, error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(expr), "$")
+	// A selector may start with a field name, without the root
+	if rest != "" && rest[0] != '.' && rest[0] != '[' {
+		rest = "." + rest
+	}
+	var steps []step
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[]") + 1
+			if end == 0 {
+				end = len(rest)
+			}
+			if end == 1 {
+				return Selector{}, fmt.Errorf("invalid selector %q: empty field name", expr)
+			}
+			steps = append(steps, step{name: rest[1:end]})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return Selector{}, fmt.Errorf("invalid selector %q: unclosed [", expr)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return Selector{}, fmt.Errorf("invalid selector %q: index %q is not a number", expr, rest[1:end])
+			}
+			steps = append(steps, step{index: index})
+			rest = rest[end+1:]
+		default:
+			return Selector{}, fmt.Errorf("invalid selector %q: expected . or [ before %q", expr, rest)
+		}
+	}
+	return Selector{expr: expr, steps: steps}, nil
+}
+
+// IsZero reports whether the selector was not configured
+func (s Selector) IsZero() bool {
+	return s.expr == ""
+}
+
+// String returns the selector expression
+func (s Selector) String() string {
+	return s.expr
+}
+
+// Select returns the selected value and whether it exists
+func (s Selector) Select(doc any) (any, bool) {
+	if s.IsZero() {
+		return nil, false
+	}
+	value := doc
+	for _, st := range s.steps {
+		if st.name != "" {
+			object, ok := value.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if value, ok = object[st.name]; !ok {
+				return nil, false
+			}
+			continue
+		}
+		array, ok := value.([]any)
+		if !ok {
+			return nil, false
+		}
+		index := st.index
+		if index < 0 {
+			index += len(array)
+		}
+		if index < 0 || index >= len(array) {
+			return nil, false
+		}
+		value = array[index]
+	}
+	return value, true
+}
+
+// UnmarshalYAML compiles a selector written in a template
+func (s *Selector) UnmarshalYAML(node *yaml.Node) error {
+	var expr string
+	if err := node.Decode(&expr); err != nil {
+		return err
+	}
+	parsed, err := ParseSelector(expr)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*s = parsed
+	return nil
+}
+
+// Condition holds when the selected value equals Equals or, without Equals, when
+// it is true, a non-zero number or a non-empty string, array or object
+type Condition struct {
+	Path   Selector `yaml:"path"`
+	Equals *string  `yaml:"equals"`
+}
+
+// UnmarshalYAML accepts a bare selector as a shorthand for a condition without Equals
+func (c *Condition) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return c.Path.UnmarshalYAML(node)
+	}
+	type plain Condition
+	return node.Decode((*plain)(c))
+}
+
+// Holds evaluates the condition on a decoded message; an unset condition never holds
+func (c Condition) Holds(doc any) bool {
+	value, ok := c.Path.Select(doc)
+	if !ok {
+		return false
+	}
+	if c.Equals != nil {
+		return text(value) == *c.Equals
+	}
+	return truthy(value)
+}
+
+// truthy reports whether a JSON value is set
+func truthy(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []any:
+		return len(v) > 0
+	case map[string]any:
+		return len(v) > 0
+	}
+	return true
+}
+
+// text formats a JSON value: strings as is, anything else as JSON
+func text(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// number converts a JSON number, or a string holding one, to a float
+func number(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,67 @@
+package wsgeneric
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSelector(t *testing.T) {
+	var doc any
+	json.Unmarshal([]byte(`{"type":"final","is_final":true,"seq":2,"alternatives":[{"transcript":"hello world","words":[{"w":"hello"},{"w":"world"}]}]}`), &doc)
+
+	tests := []struct {
+		expr string
+		want string
+		ok   bool
+	}{
+		{"$.type", "final", true},
+		{"type", "final", true},
+		{"$.alternatives[0].transcript", "hello world", true},
+		{"alternatives[0].words[-1].w", "world", true},
+		{"$.seq", "2", true},
+		{"$.alternatives[1].transcript", "", false},
+		{"$.missing.field", "", false},
+		{"$.type.field", "", false},
+	}
+	for _, tt := range tests {
+		selector, err := ParseSelector(tt.expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.expr, err)
+			continue
+		}
+		value, ok := selector.Select(doc)
+		if ok != tt.ok || (ok && text(value) != tt.want) {
+			t.Errorf("%s: got %v (%v), want %q (%v)", tt.expr, value, ok, tt.want, tt.ok)
+		}
+	}
+
+	for _, expr := range []string{"$.", "$.a..b", "$.a[", "$.a[x]", "$.a]"} {
+		if _, err := ParseSelector(expr); err == nil {
+			t.Errorf("%s: expected error", expr)
+		}
+	}
+
+	final, equals := "final", "2"
+	for _, c := range []struct {
+		condition Condition
+		want      bool
+	}{
+		{Condition{Path: mustSelector("$.is_final")}, true},
+		{Condition{Path: mustSelector("$.type"), Equals: &final}, true},
+		{Condition{Path: mustSelector("$.seq"), Equals: &equals}, true},
+		{Condition{Path: mustSelector("$.missing")}, false},
+		{Condition{}, false},
+	} {
+		if got := c.condition.Holds(doc); got != c.want {
+			t.Errorf("%s: Holds = %v, want %v", c.condition.Path, got, c.want)
+		}
+	}
+}
+
+func mustSelector(expr string) Selector {
+	s, err := ParseSelector(expr)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
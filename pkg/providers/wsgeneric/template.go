@@ -0,0 +1,142 @@
+package wsgeneric
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Template describes a vendor's WebSocket protocol. URL, headers and messages are
+// text/template strings rendered with the stream's TemplateData
+type Template struct {
+	URL     string            `yaml:"url"`     // endpoint, e.g. wss://api.example.com/v1/stream?rate={{.SampleRate}}
+	Headers map[string]string `yaml:"headers"` // handshake headers, skipped when they render empty
+	Start   string            `yaml:"start"`   // text message sent once connected, none when empty
+	Audio   string            `yaml:"audio"`   // text message carrying each chunk as {{.Audio}}, binary frames when empty
+	Stop    string            `yaml:"stop"`    // text message ending the audio, a close frame when empty
+	Results Results           `yaml:"results"`
+
+	url     *template.Template
+	headers map[string]*template.Template
+	start   *template.Template
+	audio   *template.Template
+	stop    *template.Template
+}
+
+// Results selects the contents of the messages received
+type Results struct {
+	Transcript   Selector  `yaml:"transcript"`    // transcript of a result, required
+	IsFinal      Condition `yaml:"is_final"`      // holds for final results; every result is final when unset
+	Words        Words     `yaml:"words"`         // word timings, optional
+	UtteranceEnd Condition `yaml:"utterance_end"` // holds for end of utterance messages
+	Error        Selector  `yaml:"error"`         // message of an error, which fails the stream when set
+	End          Condition `yaml:"end"`           // holds for the last message of the stream
+}
+
+// Words selects the words of a result
+type Words struct {
+	Path       Selector `yaml:"path"`       // array of words, relative to the message
+	Word       Selector `yaml:"word"`       // relative to a word, defaults to $.word
+	Start      Selector `yaml:"start"`      // relative to a word, defaults to $.start
+	End        Selector `yaml:"end"`        // relative to a word, defaults to $.end
+	Confidence Selector `yaml:"confidence"` // relative to a word, defaults to $.confidence
+	TimeScale  float64  `yaml:"time_scale"` // seconds per unit of the times, e.g. 0.001 for milliseconds, defaults to 1
+}
+
+// TemplateData is what templates are rendered with
+type TemplateData struct {
+	SampleRate int
+	Channels   int
+	Encoding   string // encoding of the audio, e.g. linear16
+	Language   string
+	Model      string
+	Interim    bool
+	APIKey     string
+	Options    url.Values // the --provider-opt values, e.g. {{.Options.Get "tier"}}
+	Seq        int        // number of the chunk, from 1, in audio messages
+	Audio      string     // the chunk, base64 encoded, in audio messages
+}
+
+// templateFuncs escape values for the URL and JSON messages
+var templateFuncs = template.FuncMap{
+	"query": url.QueryEscape,
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// LoadTemplate reads a template file
+func LoadTemplate(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+	return ParseTemplate(data)
+}
+
+// ParseTemplate decodes a YAML template, rejecting unknown settings, and compiles its strings
+func ParseTemplate(data []byte) (*Template, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var t Template
+	if err := decoder.Decode(&t); err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	if t.Results.Transcript.IsZero() {
+		return nil, fmt.Errorf("invalid template: results.transcript is required")
+	}
+
+	var err error
+	compile := func(name, text string) *template.Template {
+		if err != nil || text == "" {
+			return nil
+		}
+		var compiled *template.Template
+		compiled, err = template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			err = fmt.Errorf("invalid template %s: %w", name, err)
+		}
+		return compiled
+	}
+	t.url = compile("url", t.URL)
+	t.headers = make(map[string]*template.Template, len(t.Headers))
+	for name, value := range t.Headers {
+		t.headers[name] = compile("header "+name, value)
+	}
+	t.start = compile("start", t.Start)
+	t.audio = compile("audio", t.Audio)
+	t.stop = compile("stop", t.Stop)
+	if err != nil {
+		return nil, err
+	}
+
+	words := &t.Results.Words
+	for selector, field := range map[*Selector]string{&words.Word: "word", &words.Start: "start", &words.End: "end", &words.Confidence: "confidence"} {
+		if selector.IsZero() {
+			*selector, _ = ParseSelector("$." + field)
+		}
+	}
+	if words.TimeScale == 0 {
+		words.TimeScale = 1
+	}
+	return &t, nil
+}
+
+// render executes a compiled template, empty when it is not set
+func render(t *template.Template, data TemplateData) (string, error) {
+	if t == nil {
+		return "", nil
+	}
+	var out strings.Builder
+	if err := t.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to render %s: %w", t.Name(), err)
+	}
+	return out.String(), nil
+}
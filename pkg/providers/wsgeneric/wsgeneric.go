@@ -0,0 +1,253 @@
+// Package wsgeneric streams audio to any WebSocket speech recognition API whose
+// protocol is described by a template file: the URL and handshake headers, the
+// messages starting and stopping the stream, and selectors picking transcripts,
+// finality and word timings out of the JSON messages received.
+package wsgeneric
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers/internal/wsstream"
+	"github.com/gorilla/websocket"
+)
+
+// Config holds the provider configuration
+type Config struct {
+	Template   *Template
+	SampleRate int
+	Channels   int
+	Encoding   string
+	Language   string
+	Model      string
+	Interim    bool
+	BaseURL    string     // replaces the scheme and host of the template URL, and its path when it has one
+	Options    url.Values // available to templates as {{.Options}}
+}
+
+// Provider implements the speech recognition provider for a templated WebSocket API
+type Provider struct {
+	apiKey string
+	config *Config
+}
+
+// NewProvider creates a new templated WebSocket provider; the API key is available
+// to templates as {{.APIKey}}
+func NewProvider(config *Config, apiKey string) (*Provider, error) {
+	if config.Template == nil {
+		return nil, fmt.Errorf("a template is required")
+	}
+	return &Provider{
+		apiKey: apiKey,
+		config: config,
+	}, nil
+}
+
+// templateData returns the values templates are rendered with
+func (p *Provider) templateData() TemplateData {
+	return TemplateData{
+		SampleRate: p.config.SampleRate,
+		Channels:   p.config.Channels,
+		Encoding:   p.config.Encoding,
+		Language:   p.config.Language,
+		Model:      p.config.Model,
+		Interim:    p.config.Interim,
+		APIKey:     p.apiKey,
+		Options:    p.config.Options,
+	}
+}
+
+// streamURL renders the WebSocket URL of the template, pointed at the base URL when one is set
+func (p *Provider) streamURL(data TemplateData) (string, error) {
+	rendered, err := render(p.config.Template.url, data)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(rendered)
+	if err != nil {
+		return "", fmt.Errorf("invalid template URL: %w", err)
+	}
+	if p.config.BaseURL != "" {
+		base, err := url.Parse(strings.TrimRight(p.config.BaseURL, "/"))
+		if err != nil {
+			return "", fmt.Errorf("invalid base URL: %w", err)
+		}
+		u.Scheme, u.Host = base.Scheme, base.Host
+		if base.Path != "" {
+			u.Path = base.Path
+		}
+	}
+	if u.Scheme == "" {
+		return "", fmt.Errorf("the template has no URL and no base URL is set")
+	}
+	if err := wsstream.NormalizeScheme(u); err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// StreamAudio sends the audio chunk by chunk as it is paced by the reader and
+// emits the results picked by the template's selectors
+func (p *Provider) StreamAudio(ctx context.Context, audioReader io.Reader, sink events.Sink) error {
+	data := p.templateData()
+	wsURL, err := p.streamURL(data)
+	if err != nil {
+		return err
+	}
+	headers := http.Header{}
+	for name, value := range p.config.Template.headers {
+		rendered, err := render(value, data)
+		if err != nil {
+			return err
+		}
+		if rendered != "" {
+			headers.Set(name, rendered)
+		}
+	}
+	start, err := render(p.config.Template.start, data)
+	if err != nil {
+		return err
+	}
+
+	var startMessage []byte
+	if start != "" {
+		startMessage = []byte(start)
+	}
+	conn, err := wsstream.Dial(ctx, sink, wsURL, headers, startMessage)
+	if err != nil {
+		return events.EmitError(sink, err)
+	}
+	defer conn.Close()
+	sink.Emit(events.Event{Type: events.Opened})
+	defer sink.Emit(events.Event{Type: events.Closed})
+
+	sendErr := make(chan error, 1)
+	go func() {
+		sendErr <- p.sendAudio(conn, audioReader, data)
+	}()
+
+	for {
+		_, message, err := conn.Receive()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return events.EmitError(sink, err)
+		}
+		receivedAt := time.Now()
+
+		var doc any
+		if err := json.Unmarshal(message, &doc); err != nil {
+			return events.EmitError(sink, fmt.Errorf("failed to decode message: %w", err))
+		}
+		results := p.config.Template.Results
+		if value, ok := results.Error.Select(doc); ok && truthy(value) {
+			return events.EmitError(sink, fmt.Errorf("provider error: %s", text(value)))
+		}
+		if event, ok := p.result(doc); ok {
+			event.ReceivedAt = receivedAt
+			sink.Emit(event)
+		}
+		if results.UtteranceEnd.Holds(doc) {
+			sink.Emit(events.Event{Type: events.UtteranceEnd, ReceivedAt: receivedAt})
+		}
+		// The audio may still be sending; closing the connection stops it
+		if results.End.Holds(doc) {
+			return nil
+		}
+	}
+
+	if err := <-sendErr; err != nil {
+		return events.EmitError(sink, err)
+	}
+	return nil
+}
+
+// result picks the interim or final result of a message, if it has one worth emitting
+func (p *Provider) result(doc any) (events.Event, bool) {
+	results := p.config.Template.Results
+	value, ok := results.Transcript.Select(doc)
+	if !ok || value == nil {
+		return events.Event{}, false
+	}
+	event := events.Event{Type: events.Final, Transcript: text(value)}
+	if !results.IsFinal.Path.IsZero() && !results.IsFinal.Holds(doc) {
+		if !p.config.Interim {
+			return events.Event{}, false
+		}
+		event.Type = events.Interim
+	}
+
+	words, _ := results.Words.Path.Select(doc)
+	if list, ok := words.([]any); ok {
+		scale := results.Words.TimeScale
+		for _, w := range list {
+			word, _ := results.Words.Word.Select(w)
+			start, _ := results.Words.Start.Select(w)
+			end, _ := results.Words.End.Select(w)
+			confidence, _ := results.Words.Confidence.Select(w)
+			startTime, _ := number(start)
+			endTime, _ := number(end)
+			conf, _ := number(confidence)
+			event.Words = append(event.Words, events.Word{Word: text(word), Start: startTime * scale, End: endTime * scale, Confidence: conf})
+		}
+	} else if results.Words.Path.IsZero() {
+		// Without word timings, the transcript's words are untimed
+		for _, f := range strings.Fields(event.Transcript) {
+			event.Words = append(event.Words, events.Word{Word: f})
+		}
+	}
+	if event.Transcript == "" && len(event.Words) == 0 {
+		return events.Event{}, false
+	}
+	return event, true
+}
+
+// sendAudio writes each chunk as it is paced by the reader, then the stop message or a close frame
+func (p *Provider) sendAudio(conn *wsstream.Conn, audioReader io.Reader, data TemplateData) error {
+	err := wsstream.SendChunks(audioReader, func(chunk []byte) error {
+		data.Seq++
+		return p.sendChunk(conn, chunk, data)
+	})
+	if err != nil {
+		return err
+	}
+
+	stop, err := render(p.config.Template.stop, data)
+	if err != nil {
+		return err
+	}
+	if stop == "" {
+		err = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	} else {
+		err = conn.WriteMessage(websocket.TextMessage, []byte(stop))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to end stream: %w", err)
+	}
+	return nil
+}
+
+// sendChunk writes a chunk as a binary frame, or in the template's audio message
+func (p *Provider) sendChunk(conn *wsstream.Conn, chunk []byte, data TemplateData) error {
+	if p.config.Template.audio == nil {
+		return conn.SendBinary(chunk)
+	}
+	data.Audio = base64.StdEncoding.EncodeToString(chunk)
+	message, err := render(p.config.Template.audio, data)
+	if err != nil {
+		return err
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+		return fmt.Errorf("failed to send audio: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package wsgeneric
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/events"
+	"github.com/elishowk/speech_latency/pkg/providers/internal/providertest"
+	"github.com/gorilla/websocket"
+)
+
+// echoTemplate describes the protocol of echoServer
+const echoTemplate = `
+url: wss://stt.example.com/v1/stream?rate={{.SampleRate}}&lang={{query .Language}}
+headers:
+  Authorization: Token {{.APIKey}}
+  X-Tier: '{{.Options.Get "tier"}}'
+start: '{"type":"start","sample_rate":{{.SampleRate}},"language":{{json .Language}}}'
+stop: '{"type":"stop"}'
+results:
+  transcript: $.alternatives[0].transcript
+  is_final: {path: $.type, equals: final}
+  words:
+    path: $.alternatives[0].words
+    word: $.w
+    start: $.s
+    end: $.e
+    time_scale: 0.001
+  utterance_end: {path: $.type, equals: utterance_end}
+  error: $.error
+  end: {path: $.type, equals: closed}
+`
+
+// echoServer recognizes one word per chunk of 100ms of audio, sent as binary frames
+// or base64 in {"type":"audio"} messages: a partial per chunk, and a final and an end
+// of utterance every second chunk. {"type":"stop"} is answered with {"type":"closed"}
+func echoServer(t *testing.T, received *bytes.Buffer) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/stream" || r.URL.Query().Get("rate") != "16000" || r.URL.Query().Get("lang") != "en-US" {
+			t.Errorf("unexpected URL %s", r.URL)
+		}
+		if r.Header.Get("Authorization") != "Token secret" {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		if _, ok := r.Header["X-Tier"]; ok {
+			t.Errorf("empty header sent")
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		var utterance []map[string]any
+		result := func(kind string) {
+			var words []string
+			for _, w := range utterance {
+				words = append(words, w["w"].(string))
+			}
+			conn.WriteJSON(map[string]any{
+				"type":         kind,
+				"is_final":     kind == "final",
+				"alternatives": []map[string]any{{"transcript": strings.Join(words, " "), "words": utterance}},
+			})
+		}
+		var chunks int
+		for {
+			msgType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if msgType == websocket.TextMessage {
+				var msg struct {
+					Type       string `json:"type"`
+					SampleRate int    `json:"sample_rate"`
+					Data       []byte `json:"data"`
+				}
+				if err := json.Unmarshal(data, &msg); err != nil {
+					t.Errorf("invalid message %s", data)
+					return
+				}
+				switch msg.Type {
+				case "start":
+					if msg.SampleRate != 16000 {
+						t.Errorf("unexpected start %s", data)
+					}
+					continue
+				case "stop":
+					conn.WriteJSON(map[string]any{"type": "closed"})
+					continue
+				}
+				data = msg.Data
+			}
+
+			received.Write(data)
+			chunks++
+			utterance = append(utterance, map[string]any{"w": fmt.Sprintf("chunk%d", chunks), "s": (chunks - 1) * 100, "e": chunks * 100})
+			if chunks%2 == 1 {
+				result("partial")
+				continue
+			}
+			result("final")
+			utterance = nil
+			conn.WriteJSON(map[string]any{"type": "utterance_end"})
+		}
+	}))
+}
+
+// stream runs a stream of 400ms to srv with the given template
+func stream(t *testing.T, srv *httptest.Server, templateText string, interim bool) ([]events.Event, []byte) {
+	template, err := ParseTemplate([]byte(templateText))
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+	provider, err := NewProvider(&Config{Template: template, SampleRate: 16000, Channels: 1, Language: "en-US", Interim: interim, BaseURL: srv.URL}, "secret")
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+
+	audio := bytes.Repeat([]byte{1, 2}, 6400)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	log := events.NewLog(nil)
+	if err := provider.StreamAudio(ctx, &providertest.ChunkReader{Data: audio, Size: 3200}, log); err != nil {
+		t.Fatalf("StreamAudio failed: %v", err)
+	}
+	return log.Events(), audio
+}
+
+func TestStreamAudio(t *testing.T) {
+	var received bytes.Buffer
+	srv := echoServer(t, &received)
+	defer srv.Close()
+
+	got, audio := stream(t, srv, echoTemplate, true)
+	if !bytes.Equal(received.Bytes(), audio) {
+		t.Errorf("server received %d bytes, want %d", received.Len(), len(audio))
+	}
+	providertest.CheckTypes(t, got, []events.Type{
+		events.Opened,
+		events.Interim, events.Final, events.UtteranceEnd,
+		events.Interim, events.Final, events.UtteranceEnd,
+		events.Closed,
+	})
+	final := got[5]
+	if final.Transcript != "chunk3 chunk4" || len(final.Words) != 2 || final.Words[1].Word != "chunk4" ||
+		final.Words[1].Start != 0.3 || final.Words[1].End != 0.4 {
+		t.Errorf("unexpected final %+v", final)
+	}
+}
+
+func TestStreamAudio_AudioMessages(t *testing.T) {
+	var received bytes.Buffer
+	srv := echoServer(t, &received)
+	defer srv.Close()
+
+	// Base64 audio messages and a close frame, without word timings nor interim results
+	template := `
+url: ws://localhost/v1/stream?rate={{.SampleRate}}&lang={{.Language}}
+headers: {Authorization: 'Token {{.APIKey}}'}
+audio: '{"type":"audio","seq":{{.Seq}},"data":"{{.Audio}}"}'
+results:
+  transcript: alternatives[0].transcript
+  is_final: $.is_final
+`
+	got, audio := stream(t, srv, template, false)
+	if !bytes.Equal(received.Bytes(), audio) {
+		t.Errorf("server received %d bytes, want %d", received.Len(), len(audio))
+	}
+	providertest.CheckTypes(t, got, []events.Type{events.Opened, events.Final, events.Final, events.Closed})
+	if final := got[1]; final.Transcript != "chunk1 chunk2" || len(final.Words) != 2 || final.Words[0].End != 0 {
+		t.Errorf("unexpected final %+v", final)
+	}
+}
+
+func TestParseTemplate_Errors(t *testing.T) {
+	for name, text := range map[string]string{
+		"no transcript":    "url: ws://localhost\n",
+		"unknown setting":  "url: ws://localhost\nresults: {transcript: $.text, final: $.final}\n",
+		"invalid selector": "results: {transcript: '$.alternatives[first]'}\n",
+		"invalid template": "url: ws://localhost/{{.Rate\nresults: {transcript: $.text}\n",
+	} {
+		if _, err := ParseTemplate([]byte(text)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestStreamAudio_Errors(t *testing.T) {
+	if _, err := NewProvider(&Config{}, ""); err == nil {
+		t.Error("expected error without a template")
+	}
+
+	template, _ := ParseTemplate([]byte("results: {transcript: $.text, error: $.error}\n"))
+	provider, _ := NewProvider(&Config{Template: template}, "")
+	if err := provider.StreamAudio(context.Background(), &providertest.ChunkReader{}, events.NewLog(nil)); err == nil {
+		t.Error("expected error without a URL")
+	}
+
+	// The handshake is refused without the API key
+	var received bytes.Buffer
+	srv := echoServer(t, &received)
+	defer srv.Close()
+	echo, _ := ParseTemplate([]byte(echoTemplate))
+	provider, _ = NewProvider(&Config{Template: echo, SampleRate: 16000, Language: "en-US", BaseURL: srv.URL}, "")
+	err := provider.StreamAudio(context.Background(), &providertest.ChunkReader{}, events.NewLog(nil))
+	if err == nil || !strings.Contains(err.Error(), "API error 401: invalid token") {
+		t.Errorf("expected the handshake error, got %v", err)
+	}
+
+	// An error message fails the stream
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.ReadMessage()
+		conn.WriteJSON(map[string]any{"error": "quota exceeded"})
+		conn.ReadMessage()
+	}))
+	defer failing.Close()
+	provider, _ = NewProvider(&Config{Template: template, BaseURL: failing.URL}, "")
+	err = provider.StreamAudio(context.Background(), &providertest.ChunkReader{Data: []byte{0, 0}, Size: 2}, events.NewLog(nil))
+	if err == nil || !strings.Contains(err.Error(), "provider error: quota exceeded") {
+		t.Errorf("expected the provider error, got %v", err)
+	}
+}
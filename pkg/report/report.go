@@ -0,0 +1,266 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/bench"
+	"github.com/elishowk/speech_latency/pkg/metrics"
+	"github.com/elishowk/speech_latency/pkg/scoring"
+)
+
+// Format selects how records are written
+type Format string
+
+const (
+	// Text is the human readable output, records are not written
+	Text Format = "text"
+	// JSON writes all records as a single JSON array
+	JSON Format = "json"
+	// JSONL writes one JSON record per line as runs complete
+	JSONL Format = "jsonl"
+	// CSV writes a header line then one row per record
+	CSV Format = "csv"
+)
+
+// ParseFormat validates an output format
+func ParseFormat(format string) (Format, error) {
+	switch Format(format) {
+	case Text, JSON, JSONL, CSV:
+		return Format(format), nil
+	default:
+		return "", fmt.Errorf("unknown output format: %s (expected text, json, jsonl or csv)", format)
+	}
+}
+
+// Record is the structured result of a single benchmark run
+type Record struct {
+	Run             int       `json:"run"`
+	Cell            string    `json:"cell,omitempty"`            // scenario cell of the run
+	Concurrency     int       `json:"concurrency,omitempty"`     // streams in flight when a load test stream started
+	StartOffsetMs   float64   `json:"start_offset_ms,omitempty"` // start of a load test stream
+	Provider        string    `json:"provider"`
+	Model           string    `json:"model,omitempty"`
+	Language        string    `json:"language"`
+	Live            bool      `json:"live"`
+	AudioFile       string    `json:"audio_file"`
+	SampleRate      int       `json:"sample_rate"`
+	Channels        int       `json:"channels"`
+	ChunkSize       int       `json:"chunk_size"`
+	ChunkIntervalMs float64   `json:"chunk_interval_ms"`
+	RealtimeFactor  float64   `json:"realtime_factor"`
+	StartedAt       time.Time `json:"started_at"`
+
+	FirstWordLatencyMs  float64 `json:"first_word_latency_ms"`
+	FirstFinalLatencyMs float64 `json:"first_final_latency_ms"`
+	FinalLatencyMs      float64 `json:"final_latency_ms"`
+	Throughput          float64 `json:"throughput_wps"`
+	WordCount           int     `json:"word_count"`
+	WordFirstSeenMeanMs float64 `json:"word_first_seen_mean_ms"`
+	WordFirstSeenP90Ms  float64 `json:"word_first_seen_p90_ms"`
+	WordFinalMeanMs     float64 `json:"word_final_mean_ms"`
+	WordFinalP90Ms      float64 `json:"word_final_p90_ms"`
+	PacingErrorMeanMs   float64 `json:"pacing_error_mean_ms"`
+	PacingErrorP99Ms    float64 `json:"pacing_error_p99_ms"`
+	PacingErrorMaxMs    float64 `json:"pacing_error_max_ms"`
+
+	Transcript string   `json:"transcript"`
+	WER        *float64 `json:"wer,omitempty"`
+	CER        *float64 `json:"cer,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// NewRecord builds the record of a run; run may be nil or partial when err is set
+func NewRecord(index int, opts bench.Options, run *bench.Run, err error) Record {
+	rec := Record{
+		Run:             index,
+		Provider:        opts.Provider,
+		Model:           opts.Config.Model,
+		Language:        opts.Config.Language,
+		Live:            opts.Config.Live,
+		AudioFile:       opts.AudioPath,
+		ChunkSize:       opts.ChunkSize,
+		ChunkIntervalMs: metrics.Milliseconds(opts.ChunkInterval),
+		RealtimeFactor:  opts.RealtimeFactor,
+	}
+	if rec.RealtimeFactor == 0 {
+		rec.RealtimeFactor = 1
+	}
+	if opts.Unpaced {
+		rec.RealtimeFactor = 0
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	if run == nil {
+		return rec
+	}
+
+	rec.SampleRate = run.SampleRate
+	rec.Channels = run.Channels
+	rec.ChunkSize = run.ChunkSize
+	rec.ChunkIntervalMs = metrics.Milliseconds(run.ChunkInterval)
+	rec.StartedAt = run.Started
+	if s := run.Summary; s != nil {
+		rec.FirstWordLatencyMs = metrics.Milliseconds(s.FirstWordLatency)
+		rec.FirstFinalLatencyMs = metrics.Milliseconds(s.FirstFinalLatency)
+		rec.FinalLatencyMs = metrics.Milliseconds(s.FinalLatency)
+		rec.Throughput = s.Throughput
+		rec.WordCount = s.WordCount
+		rec.Transcript = s.Transcript
+	}
+	if w := run.Words; w != nil {
+		rec.WordFirstSeenMeanMs = w.FirstSeen.Mean
+		rec.WordFirstSeenP90Ms = w.FirstSeen.P90
+		rec.WordFinalMeanMs = w.Finalized.Mean
+		rec.WordFinalP90Ms = w.Finalized.P90
+	}
+	rec.PacingErrorMeanMs = run.Pacing.Mean
+	rec.PacingErrorP99Ms = run.Pacing.P99
+	rec.PacingErrorMaxMs = run.Pacing.Max
+	return rec
+}
+
+// NewStreamRecord builds the record of a load test stream
+func NewStreamRecord(opts bench.Options, s bench.StreamResult) Record {
+	rec := NewRecord(s.Stream, opts, s.Run, s.Err)
+	rec.Concurrency = s.Concurrency
+	rec.StartOffsetMs = metrics.Milliseconds(s.Offset)
+	return rec
+}
+
+// SetScore records the accuracy of the transcript
+func (r *Record) SetScore(score scoring.Result) {
+	wer, cer := score.WER(), score.CER()
+	r.WER = &wer
+	r.CER = &cer
+}
+
+// Writer writes records in a structured format
+type Writer interface {
+	Write(Record) error
+	// Close flushes buffered records; it does not close the underlying writer
+	Close() error
+}
+
+// NewWriter creates a record writer for a structured format
+func NewWriter(format Format, w io.Writer) (Writer, error) {
+	switch format {
+	case JSON:
+		return &jsonWriter{out: w}, nil
+	case JSONL:
+		return &jsonlWriter{encoder: json.NewEncoder(w)}, nil
+	case CSV:
+		return &csvWriter{writer: csv.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("format %s has no record writer", format)
+	}
+}
+
+// jsonWriter buffers records and writes them as an array on close
+type jsonWriter struct {
+	out     io.Writer
+	records []Record
+}
+
+func (w *jsonWriter) Write(r Record) error {
+	w.records = append(w.records, r)
+	return nil
+}
+
+func (w *jsonWriter) Close() error {
+	records := w.records
+	if records == nil {
+		records = []Record{}
+	}
+	encoder := json.NewEncoder(w.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
+
+// jsonlWriter writes one record per line
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *jsonlWriter) Write(r Record) error {
+	return w.encoder.Encode(r)
+}
+
+func (w *jsonlWriter) Close() error {
+	return nil
+}
+
+// csvHeader lists the CSV columns in the order written by csvWriter
+var csvHeader = []string{
+	"run", "cell", "concurrency", "start_offset_ms", "provider", "model", "language", "live", "audio_file", "sample_rate", "channels",
+	"chunk_size", "chunk_interval_ms", "realtime_factor", "started_at",
+	"first_word_latency_ms", "first_final_latency_ms", "final_latency_ms", "throughput_wps", "word_count",
+	"word_first_seen_mean_ms", "word_first_seen_p90_ms", "word_final_mean_ms", "word_final_p90_ms",
+	"pacing_error_mean_ms", "pacing_error_p99_ms", "pacing_error_max_ms",
+	"transcript", "wer", "cer", "error",
+}
+
+// csvWriter writes a header line before the first record
+type csvWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvWriter) Write(r Record) error {
+	if !w.headerWritten {
+		if err := w.writer.Write(csvHeader); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+
+	var startedAt string
+	if !r.StartedAt.IsZero() {
+		startedAt = r.StartedAt.Format(time.RFC3339Nano)
+	}
+	row := []string{
+		strconv.Itoa(r.Run), r.Cell, strconv.Itoa(r.Concurrency), formatFloat(r.StartOffsetMs), r.Provider, r.Model, r.Language, strconv.FormatBool(r.Live), r.AudioFile,
+		strconv.Itoa(r.SampleRate), strconv.Itoa(r.Channels), strconv.Itoa(r.ChunkSize),
+		formatFloat(r.ChunkIntervalMs), formatFloat(r.RealtimeFactor), startedAt,
+		formatFloat(r.FirstWordLatencyMs), formatFloat(r.FirstFinalLatencyMs), formatFloat(r.FinalLatencyMs),
+		formatFloat(r.Throughput), strconv.Itoa(r.WordCount),
+		formatFloat(r.WordFirstSeenMeanMs), formatFloat(r.WordFirstSeenP90Ms),
+		formatFloat(r.WordFinalMeanMs), formatFloat(r.WordFinalP90Ms),
+		formatFloat(r.PacingErrorMeanMs), formatFloat(r.PacingErrorP99Ms), formatFloat(r.PacingErrorMaxMs),
+		r.Transcript, formatOptional(r.WER), formatOptional(r.CER), r.Error,
+	}
+	if err := w.writer.Write(row); err != nil {
+		return err
+	}
+	// Flush every row so partial results survive an interrupted benchmark
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func (w *csvWriter) Close() error {
+	if !w.headerWritten {
+		if err := w.writer.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// formatFloat formats a float with the shortest exact representation
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// formatOptional formats an optional float, leaving the cell empty when unset
+func formatOptional(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return formatFloat(*v)
+}
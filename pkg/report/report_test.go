@@ -0,0 +1,133 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/bench"
+	"github.com/elishowk/speech_latency/pkg/metrics"
+	"github.com/elishowk/speech_latency/pkg/providers"
+	"github.com/elishowk/speech_latency/pkg/scoring"
+)
+
+func testRecords() []Record {
+	opts := bench.Options{
+		Provider:      "deepgram",
+		AudioPath:     "audio.wav",
+		ChunkSize:     4096,
+		ChunkInterval: 100 * time.Millisecond,
+		Config:        providers.Config{Language: "en-US", Model: "nova-3"},
+	}
+	run := &bench.Run{
+		SampleRate:    16000,
+		Channels:      1,
+		ChunkSize:     4096,
+		ChunkInterval: 100 * time.Millisecond,
+		Started:       time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Summary: &metrics.Summary{
+			FirstWordLatency: 250 * time.Millisecond,
+			FinalLatency:     1500 * time.Millisecond,
+			Throughput:       2,
+			WordCount:        3,
+			Transcript:       "hello, big world",
+		},
+		Words:  &metrics.WordSummary{},
+		Pacing: metrics.Distribution{Count: 10, Mean: 0.5, P99: 1.2, Max: 1.5},
+	}
+
+	ok := NewRecord(1, opts, run, nil)
+	ok.SetScore(scoring.Score("hello big world", run.Summary.Transcript, scoring.DefaultNormalizer))
+	failed := NewRecord(2, opts, nil, errors.New("API error 401"))
+	return []Record{ok, failed}
+}
+
+func TestJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(JSON, &buf)
+	for _, r := range testRecords() {
+		w.Write(r)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	var got []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(got))
+	}
+	if got[0]["first_word_latency_ms"] != 250.0 || got[0]["wer"] != 0.0 || got[0]["model"] != "nova-3" {
+		t.Errorf("unexpected first record %v", got[0])
+	}
+	if _, ok := got[1]["wer"]; ok {
+		t.Errorf("failed run should have no score: %v", got[1])
+	}
+	if got[1]["error"] != "API error 401" {
+		t.Errorf("expected error in failed record, got %v", got[1]["error"])
+	}
+}
+
+func TestJSONLWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(JSONL, &buf)
+	for _, r := range testRecords() {
+		w.Write(r)
+	}
+	w.Close()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	var rec Record
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("invalid JSON line: %v", err)
+	}
+	if rec.Provider != "deepgram" || rec.SampleRate != 16000 || rec.Transcript != "hello, big world" {
+		t.Errorf("unexpected record %+v", rec)
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(CSV, &buf)
+	for _, r := range testRecords() {
+		w.Write(r)
+	}
+	w.Close()
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected header and 2 rows, got %d rows", len(rows))
+	}
+	column := make(map[string]int)
+	for i, name := range rows[0] {
+		column[name] = i
+	}
+	if rows[1][column["transcript"]] != "hello, big world" || rows[1][column["final_latency_ms"]] != "1500" ||
+		rows[1][column["pacing_error_max_ms"]] != "1.5" || rows[1][column["realtime_factor"]] != "1" {
+		t.Errorf("unexpected first row %v", rows[1])
+	}
+	if rows[2][column["wer"]] != "" || rows[2][column["error"]] != "API error 401" {
+		t.Errorf("unexpected failed row %v", rows[2])
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	if f, err := ParseFormat("jsonl"); err != nil || f != JSONL {
+		t.Errorf("expected jsonl, got %s (%v)", f, err)
+	}
+}
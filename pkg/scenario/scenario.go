@@ -0,0 +1,287 @@
+package scenario
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/bench"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario declares a set of benchmark cells, from a matrix of values and listed cases
+type Scenario struct {
+	Name     string `yaml:"name"`
+	Defaults Case   `yaml:"defaults"` // applied to every cell before its own values
+	Matrix   Matrix `yaml:"matrix"`   // every combination of the values is a cell
+	Cases    []Case `yaml:"cases"`    // cells listed one by one
+
+	dir string // directory of the scenario file, relative paths start there
+}
+
+// Case holds the settings of a cell; unset fields keep the command line defaults
+type Case struct {
+	Provider        *string  `yaml:"provider"`
+	Language        *string  `yaml:"language"`
+	Model           *string  `yaml:"model"`
+	Audio           *string  `yaml:"audio"`
+	Reference       *string  `yaml:"reference"`
+	ChunkSize       *int     `yaml:"chunk_size"`
+	ChunkIntervalMs *int     `yaml:"chunk_interval_ms"`
+	ChunkMs         *int     `yaml:"chunk_ms"`
+	RealtimeFactor  *float64 `yaml:"realtime_factor"`
+	Live            *bool    `yaml:"live"`
+	Interim         *bool    `yaml:"interim"`
+	Punctuate       *bool    `yaml:"punctuate"`
+	SmartFormat     *bool    `yaml:"smart_format"`
+	TargetRate      *int     `yaml:"target_rate"`
+	Channels        *int     `yaml:"channels"`
+	Runs            *int     `yaml:"runs"`
+	Warmup          *int     `yaml:"warmup"`
+}
+
+// Matrix lists the values of each setting to combine
+type Matrix struct {
+	Provider        []string  `yaml:"provider"`
+	Language        []string  `yaml:"language"`
+	Model           []string  `yaml:"model"`
+	Audio           []string  `yaml:"audio"`
+	ChunkSize       []int     `yaml:"chunk_size"`
+	ChunkIntervalMs []int     `yaml:"chunk_interval_ms"`
+	ChunkMs         []int     `yaml:"chunk_ms"`
+	RealtimeFactor  []float64 `yaml:"realtime_factor"`
+	Live            []bool    `yaml:"live"`
+	TargetRate      []int     `yaml:"target_rate"`
+	Channels        []int     `yaml:"channels"`
+}
+
+// Cell is one combination of settings of a scenario
+type Cell struct {
+	Name string // the settings that identify the cell, e.g. "provider=deepgram language=en-US"
+	Case Case   // the scenario defaults merged with the cell settings
+}
+
+// Load reads a scenario file
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	s.dir = filepath.Dir(path)
+	return s, nil
+}
+
+// Parse decodes a YAML scenario, rejecting unknown settings
+func Parse(data []byte) (*Scenario, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var s Scenario
+	if err := decoder.Decode(&s); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario: %w", err)
+	}
+	return &s, nil
+}
+
+// Cells expands the matrix then appends the listed cases, in declaration order
+func (s *Scenario) Cells() ([]Cell, error) {
+	var cases []Case
+	if combinations := s.Matrix.expand(); len(combinations) > 0 {
+		cases = append(cases, combinations...)
+	}
+	cases = append(cases, s.Cases...)
+	if len(cases) == 0 {
+		// A scenario with only defaults is a single cell
+		cases = append(cases, Case{})
+	}
+
+	cells := make([]Cell, len(cases))
+	for i, c := range cases {
+		merged := merge(s.Defaults, c)
+		merged.Audio = s.resolve(merged.Audio)
+		merged.Reference = s.resolve(merged.Reference)
+		if err := merged.validate(); err != nil {
+			return nil, fmt.Errorf("cell %d: %w", i+1, err)
+		}
+
+		name := label(c)
+		if name == "" {
+			name = fmt.Sprintf("cell %d", i+1)
+		}
+		cells[i] = Cell{Name: name, Case: merged}
+	}
+	return cells, nil
+}
+
+// resolve makes a path relative to the scenario file
+func (s *Scenario) resolve(path *string) *string {
+	if path == nil || filepath.IsAbs(*path) || s.dir == "" {
+		return path
+	}
+	resolved := filepath.Join(s.dir, *path)
+	return &resolved
+}
+
+// dimension is one setting of the matrix
+type dimension struct {
+	size int
+	set  func(c *Case, i int)
+}
+
+// expand returns every combination of the matrix values, the first setting varying slowest
+func (m Matrix) expand() []Case {
+	dims := []dimension{
+		{len(m.Provider), func(c *Case, i int) { c.Provider = &m.Provider[i] }},
+		{len(m.Language), func(c *Case, i int) { c.Language = &m.Language[i] }},
+		{len(m.Model), func(c *Case, i int) { c.Model = &m.Model[i] }},
+		{len(m.Audio), func(c *Case, i int) { c.Audio = &m.Audio[i] }},
+		{len(m.ChunkSize), func(c *Case, i int) { c.ChunkSize = &m.ChunkSize[i] }},
+		{len(m.ChunkIntervalMs), func(c *Case, i int) { c.ChunkIntervalMs = &m.ChunkIntervalMs[i] }},
+		{len(m.ChunkMs), func(c *Case, i int) { c.ChunkMs = &m.ChunkMs[i] }},
+		{len(m.RealtimeFactor), func(c *Case, i int) { c.RealtimeFactor = &m.RealtimeFactor[i] }},
+		{len(m.Live), func(c *Case, i int) { c.Live = &m.Live[i] }},
+		{len(m.TargetRate), func(c *Case, i int) { c.TargetRate = &m.TargetRate[i] }},
+		{len(m.Channels), func(c *Case, i int) { c.Channels = &m.Channels[i] }},
+	}
+
+	var used []dimension
+	for _, d := range dims {
+		if d.size > 0 {
+			used = append(used, d)
+		}
+	}
+	if len(used) == 0 {
+		return nil
+	}
+
+	// Count through the combinations like an odometer
+	var cases []Case
+	indexes := make([]int, len(used))
+	for {
+		var c Case
+		for i, d := range used {
+			d.set(&c, indexes[i])
+		}
+		cases = append(cases, c)
+
+		i := len(used) - 1
+		for ; i >= 0; i-- {
+			indexes[i]++
+			if indexes[i] < used[i].size {
+				break
+			}
+			indexes[i] = 0
+		}
+		if i < 0 {
+			return cases
+		}
+	}
+}
+
+// merge returns base with every field set in over replacing its value
+func merge(base, over Case) Case {
+	merged := base
+	m, o := reflect.ValueOf(&merged).Elem(), reflect.ValueOf(over)
+	for i := 0; i < o.NumField(); i++ {
+		if !o.Field(i).IsNil() {
+			m.Field(i).Set(o.Field(i))
+		}
+	}
+	return merged
+}
+
+// label names a cell by its own settings, in field order
+func label(c Case) string {
+	var parts []string
+	v, t := reflect.ValueOf(c), reflect.TypeOf(c)
+	for i := 0; i < v.NumField(); i++ {
+		if field := v.Field(i); !field.IsNil() {
+			parts = append(parts, fmt.Sprintf("%s=%v", t.Field(i).Tag.Get("yaml"), field.Elem().Interface()))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// validate rejects settings that cannot be run
+func (c Case) validate() error {
+	if c.Runs != nil && *c.Runs < 1 {
+		return fmt.Errorf("runs must be at least 1, got %d", *c.Runs)
+	}
+	if c.Warmup != nil && *c.Warmup < 0 {
+		return fmt.Errorf("warmup must not be negative, got %d", *c.Warmup)
+	}
+	if c.ChunkMs != nil && *c.ChunkMs < 0 {
+		return fmt.Errorf("chunk duration must not be negative, got %d ms", *c.ChunkMs)
+	}
+	if c.RealtimeFactor != nil && *c.RealtimeFactor < 0 {
+		return fmt.Errorf("realtime factor must not be negative, got %g", *c.RealtimeFactor)
+	}
+	return nil
+}
+
+// Apply overrides the run options with the settings of the case
+func (c Case) Apply(opts bench.Options) bench.Options {
+	if c.Provider != nil {
+		opts.Provider = *c.Provider
+	}
+	if c.Language != nil {
+		opts.Config.Language = *c.Language
+	}
+	if c.Model != nil {
+		opts.Config.Model = *c.Model
+	}
+	if c.Audio != nil {
+		opts.AudioPath = *c.Audio
+	}
+	if c.ChunkSize != nil {
+		opts.ChunkSize = *c.ChunkSize
+	}
+	if c.ChunkIntervalMs != nil {
+		opts.ChunkInterval = time.Duration(*c.ChunkIntervalMs) * time.Millisecond
+	}
+	if c.ChunkMs != nil {
+		opts.ChunkDuration = time.Duration(*c.ChunkMs) * time.Millisecond
+	}
+	if c.RealtimeFactor != nil {
+		opts.RealtimeFactor = *c.RealtimeFactor
+		opts.Unpaced = *c.RealtimeFactor == 0
+	}
+	if c.Live != nil {
+		opts.Config.Live = *c.Live
+	}
+	if c.Interim != nil {
+		opts.Config.Interim = *c.Interim
+	}
+	if c.Punctuate != nil {
+		opts.Config.Punctuate = *c.Punctuate
+	}
+	if c.SmartFormat != nil {
+		opts.Config.SmartFormat = *c.SmartFormat
+	}
+	if c.TargetRate != nil {
+		opts.Transform.SampleRate = *c.TargetRate
+	}
+	if c.Channels != nil {
+		opts.Transform.Channels = *c.Channels
+	}
+	return opts
+}
+
+// RunCounts returns the measured and warmup runs of the case, or the given defaults
+func (c Case) RunCounts(defaultRuns, defaultWarmup int) (runs, warmup int) {
+	runs, warmup = defaultRuns, defaultWarmup
+	if c.Runs != nil {
+		runs = *c.Runs
+	}
+	if c.Warmup != nil {
+		warmup = *c.Warmup
+	}
+	return runs, warmup
+}
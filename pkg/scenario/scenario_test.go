@@ -0,0 +1,138 @@
+package scenario
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/bench"
+	"github.com/elishowk/speech_latency/pkg/providers"
+)
+
+const matrixScenario = `
+name: chunking
+defaults:
+  provider: deepgram
+  runs: 3
+matrix:
+  language: [en-US, fr-FR]
+  chunk_ms: [20, 100]
+cases:
+  - provider: other
+    audio: /data/phone.wav
+    live: true
+    runs: 1
+`
+
+func TestCells_MatrixAndCases(t *testing.T) {
+	s, err := Parse([]byte(matrixScenario))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	cells, err := s.Cells()
+	if err != nil {
+		t.Fatalf("Cells failed: %v", err)
+	}
+
+	want := []string{
+		"language=en-US chunk_ms=20",
+		"language=en-US chunk_ms=100",
+		"language=fr-FR chunk_ms=20",
+		"language=fr-FR chunk_ms=100",
+		"provider=other audio=/data/phone.wav live=true runs=1",
+	}
+	if len(cells) != len(want) {
+		t.Fatalf("expected %d cells, got %d", len(want), len(cells))
+	}
+	for i, name := range want {
+		if cells[i].Name != name {
+			t.Errorf("cell %d: got %q, want %q", i+1, cells[i].Name, name)
+		}
+	}
+
+	// Defaults apply to every cell unless the cell overrides them
+	if *cells[0].Case.Provider != "deepgram" || *cells[4].Case.Provider != "other" {
+		t.Errorf("unexpected providers %q and %q", *cells[0].Case.Provider, *cells[4].Case.Provider)
+	}
+	if runs, warmup := cells[0].Case.RunCounts(1, 2); runs != 3 || warmup != 2 {
+		t.Errorf("expected 3 runs and 2 warmups, got %d and %d", runs, warmup)
+	}
+	if runs, _ := cells[4].Case.RunCounts(1, 0); runs != 1 {
+		t.Errorf("expected the case to override runs, got %d", runs)
+	}
+}
+
+func TestCase_Apply(t *testing.T) {
+	s, _ := Parse([]byte(matrixScenario))
+	cells, _ := s.Cells()
+
+	base := bench.Options{
+		Provider:      "deepgram",
+		AudioPath:     "audio.wav",
+		ChunkSize:     4096,
+		ChunkInterval: 100 * time.Millisecond,
+		Config:        providers.Config{Language: "en-US", Punctuate: true},
+	}
+	opts := cells[3].Case.Apply(base)
+	if opts.Config.Language != "fr-FR" || opts.ChunkDuration != 100*time.Millisecond {
+		t.Errorf("expected the cell settings, got %+v", opts)
+	}
+	if opts.AudioPath != "audio.wav" || opts.ChunkSize != 4096 || !opts.Config.Punctuate {
+		t.Errorf("expected the defaults to be kept, got %+v", opts)
+	}
+
+	opts = cells[4].Case.Apply(base)
+	if opts.Provider != "other" || opts.AudioPath != "/data/phone.wav" || !opts.Config.Live {
+		t.Errorf("expected the listed case settings, got %+v", opts)
+	}
+}
+
+func TestLoad_ResolvesRelativePaths(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bench.yaml")
+	if err := os.WriteFile(path, []byte("matrix:\n  audio: [a.wav, /abs/b.wav]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	cells, err := s.Cells()
+	if err != nil {
+		t.Fatalf("Cells failed: %v", err)
+	}
+	if got := *cells[0].Case.Audio; got != filepath.Join(dir, "a.wav") {
+		t.Errorf("expected path relative to the scenario, got %q", got)
+	}
+	if got := *cells[1].Case.Audio; got != "/abs/b.wav" {
+		t.Errorf("expected absolute path unchanged, got %q", got)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+	}{
+		{"unknown setting", "matrix:\n  providr: [deepgram]\n"},
+		{"wrong type", "defaults:\n  runs: many\n"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse([]byte(tt.yaml)); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+
+	s, _ := Parse([]byte("cases:\n  - runs: 0\n"))
+	if _, err := s.Cells(); err == nil {
+		t.Error("expected error for zero runs")
+	}
+
+	// Only defaults make a single cell
+	s, _ = Parse([]byte("defaults:\n  provider: deepgram\n"))
+	if cells, err := s.Cells(); err != nil || len(cells) != 1 || cells[0].Name != "cell 1" {
+		t.Errorf("expected a single unnamed cell, got %+v (%v)", cells, err)
+	}
+}
@@ -0,0 +1,280 @@
+package scoring
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// NumberMode selects how numbers are normalized before scoring
+type NumberMode string
+
+const (
+	// NumbersKeep leaves numbers as they were written
+	NumbersKeep NumberMode = "keep"
+	// NumbersDigits rewrites spelled-out numbers as digits
+	NumbersDigits NumberMode = "digits"
+	// NumbersWords spells out numbers written as digits
+	NumbersWords NumberMode = "words"
+)
+
+// Normalizer holds the text normalization applied to both reference and hypothesis
+type Normalizer struct {
+	IgnoreCase        bool
+	IgnorePunctuation bool
+	Numbers           NumberMode
+}
+
+// DefaultNormalizer ignores case and punctuation and compares numbers as digits,
+// so smart formatting does not count as errors
+var DefaultNormalizer = Normalizer{
+	IgnoreCase:        true,
+	IgnorePunctuation: true,
+	Numbers:           NumbersDigits,
+}
+
+// ParseNumberMode validates a number normalization mode
+func ParseNumberMode(mode string) (NumberMode, error) {
+	switch NumberMode(mode) {
+	case NumbersKeep, NumbersDigits, NumbersWords:
+		return NumberMode(mode), nil
+	default:
+		return "", fmt.Errorf("unknown number mode: %s (expected keep, digits or words)", mode)
+	}
+}
+
+// thousandsSeparator matches commas grouping the digits of a number
+var thousandsSeparator = regexp.MustCompile(`(\d),(\d{3})`)
+
+// Words normalizes text and splits it into words
+func (n Normalizer) Words(text string) []string {
+	if n.IgnoreCase {
+		text = strings.ToLower(text)
+	}
+	for thousandsSeparator.MatchString(text) {
+		text = thousandsSeparator.ReplaceAllString(text, "$1$2")
+	}
+	if n.IgnorePunctuation {
+		text = stripPunctuation(text)
+	}
+
+	words := strings.Fields(text)
+	switch n.Numbers {
+	case NumbersDigits:
+		words = numberWordsToDigits(words)
+	case NumbersWords:
+		words = digitsToNumberWords(words)
+	}
+	return words
+}
+
+// stripPunctuation replaces punctuation with spaces, keeping apostrophes inside words
+// and decimal points inside numbers
+func stripPunctuation(text string) string {
+	runes := []rune(text)
+	out := make([]rune, 0, len(runes))
+	for i, r := range runes {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r):
+			out = append(out, r)
+		case r == '\'' && between(runes, i, unicode.IsLetter):
+			out = append(out, r)
+		case r == '.' && between(runes, i, unicode.IsDigit):
+			out = append(out, r)
+		default:
+			out = append(out, ' ')
+		}
+	}
+	return string(out)
+}
+
+// between reports whether the runes on both sides of position i satisfy is
+func between(runes []rune, i int, is func(rune) bool) bool {
+	return i > 0 && i < len(runes)-1 && is(runes[i-1]) && is(runes[i+1])
+}
+
+var (
+	units = []string{
+		"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+		"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen",
+		"seventeen", "eighteen", "nineteen",
+	}
+	tens = []string{
+		"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety",
+	}
+	scales = []struct {
+		word  string
+		value int64
+	}{
+		{"billion", 1_000_000_000},
+		{"million", 1_000_000},
+		{"thousand", 1_000},
+	}
+)
+
+// numberWordValues maps unit and ten words to their values
+var numberWordValues = func() map[string]int64 {
+	values := make(map[string]int64)
+	for i, w := range units {
+		values[w] = int64(i)
+	}
+	for i, w := range tens {
+		if w != "" {
+			values[w] = int64(i * 10)
+		}
+	}
+	return values
+}()
+
+// scaleValue returns the value of a scale word such as "thousand"
+func scaleValue(word string) (int64, bool) {
+	if word == "hundred" {
+		return 100, true
+	}
+	for _, s := range scales {
+		if s.word == word {
+			return s.value, true
+		}
+	}
+	return 0, false
+}
+
+// isNumberWord reports whether word can be part of a spelled-out number
+func isNumberWord(word string) bool {
+	if _, ok := numberWordValues[strings.ToLower(word)]; ok {
+		return true
+	}
+	_, ok := scaleValue(strings.ToLower(word))
+	return ok
+}
+
+// numberWordsToDigits rewrites runs of number words such as "twenty three" as "23"
+func numberWordsToDigits(words []string) []string {
+	out := make([]string, 0, len(words))
+	for i := 0; i < len(words); {
+		if !isNumberWord(words[i]) {
+			out = append(out, words[i])
+			i++
+			continue
+		}
+
+		// "and" only belongs to the number when followed by another number word
+		end := i
+		for end < len(words) {
+			if isNumberWord(words[end]) {
+				end++
+			} else if strings.EqualFold(words[end], "and") && end > i && end+1 < len(words) && isNumberWord(words[end+1]) {
+				end++
+			} else {
+				break
+			}
+		}
+		for _, value := range parseNumberWords(words[i:end]) {
+			out = append(out, strconv.FormatInt(value, 10))
+		}
+		i = end
+	}
+	return out
+}
+
+// parseNumberWords computes the values of a run of number words; a unit that cannot
+// extend the current number, as in "one two", starts a new one
+func parseNumberWords(words []string) []int64 {
+	var values []int64
+	var total, current int64
+	started := false
+	for _, w := range words {
+		w = strings.ToLower(w)
+		if v, ok := numberWordValues[w]; ok {
+			if started && current%100 != 0 && (current%100 < 20 || v >= 10) {
+				values = append(values, total+current)
+				total, current = 0, 0
+			} else if started && current == 0 && total == 0 {
+				// "zero" is only ever a number on its own
+				values = append(values, 0)
+			}
+			current += v
+			started = true
+			continue
+		}
+		scale, ok := scaleValue(w)
+		if !ok {
+			continue
+		}
+		if current == 0 {
+			current = 1
+		}
+		if scale == 100 {
+			current *= scale
+		} else {
+			total += current * scale
+			current = 0
+		}
+		started = true
+	}
+	return append(values, total+current)
+}
+
+// digitsToNumberWords spells out numbers such as "23" as "twenty three"
+func digitsToNumberWords(words []string) []string {
+	out := make([]string, 0, len(words))
+	for _, w := range words {
+		integer, fraction, hasFraction := strings.Cut(w, ".")
+		value, err := strconv.ParseInt(integer, 10, 64)
+		if err != nil || !allDigits(integer) || value >= 1_000_000_000_000 || (hasFraction && !allDigits(fraction)) {
+			out = append(out, w)
+			continue
+		}
+		out = append(out, spellNumber(value)...)
+		if hasFraction {
+			out = append(out, "point")
+			for _, d := range fraction {
+				out = append(out, units[d-'0'])
+			}
+		}
+	}
+	return out
+}
+
+// allDigits reports whether s is a non-empty run of ASCII digits
+func allDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// spellNumber spells out a non-negative integer below one trillion
+func spellNumber(value int64) []string {
+	if value < 20 {
+		return []string{units[value]}
+	}
+
+	var words []string
+	for _, s := range scales {
+		if value >= s.value {
+			words = append(words, spellNumber(value/s.value)...)
+			words = append(words, s.word)
+			value %= s.value
+		}
+	}
+	if value >= 100 {
+		words = append(words, units[value/100], "hundred")
+		value %= 100
+	}
+	if value >= 20 {
+		words = append(words, tens[value/10])
+		value %= 10
+	}
+	if value > 0 {
+		words = append(words, units[value])
+	}
+	return words
+}
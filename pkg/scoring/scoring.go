@@ -0,0 +1,96 @@
+package scoring
+
+import (
+	"strings"
+)
+
+// Alignment counts the edit operations aligning a hypothesis with a reference
+type Alignment struct {
+	Hits          int
+	Substitutions int
+	Deletions     int
+	Insertions    int
+	Reference     int // number of reference tokens
+}
+
+// ErrorRate returns (substitutions + deletions + insertions) / reference length
+func (a Alignment) ErrorRate() float64 {
+	errors := a.Substitutions + a.Deletions + a.Insertions
+	if a.Reference == 0 {
+		if errors == 0 {
+			return 0
+		}
+		return 1
+	}
+	return float64(errors) / float64(a.Reference)
+}
+
+// Result contains the word and character level scores of a hypothesis
+type Result struct {
+	Words Alignment
+	Chars Alignment
+}
+
+// WER returns the word error rate
+func (r Result) WER() float64 {
+	return r.Words.ErrorRate()
+}
+
+// CER returns the character error rate
+func (r Result) CER() float64 {
+	return r.Chars.ErrorRate()
+}
+
+// Score compares a hypothesis with a reference transcript after normalizing both
+func Score(reference, hypothesis string, normalizer Normalizer) Result {
+	refWords := normalizer.Words(reference)
+	hypWords := normalizer.Words(hypothesis)
+
+	return Result{
+		Words: Align(refWords, hypWords),
+		Chars: Align(strings.Split(strings.Join(refWords, " "), ""), strings.Split(strings.Join(hypWords, " "), "")),
+	}
+}
+
+// Align computes the minimum edit distance alignment of two token sequences
+func Align(reference, hypothesis []string) Alignment {
+	// cost[i][j] is the edit distance between reference[:i] and hypothesis[:j]
+	cost := make([][]int, len(reference)+1)
+	for i := range cost {
+		cost[i] = make([]int, len(hypothesis)+1)
+		cost[i][0] = i
+	}
+	for j := range cost[0] {
+		cost[0][j] = j
+	}
+	for i := 1; i <= len(reference); i++ {
+		for j := 1; j <= len(hypothesis); j++ {
+			substitution := cost[i-1][j-1]
+			if reference[i-1] != hypothesis[j-1] {
+				substitution++
+			}
+			cost[i][j] = min(substitution, cost[i-1][j]+1, cost[i][j-1]+1)
+		}
+	}
+
+	// Walk the table back, preferring hits and substitutions over gaps
+	alignment := Alignment{Reference: len(reference)}
+	i, j := len(reference), len(hypothesis)
+	for i > 0 || j > 0 {
+		switch {
+		case i > 0 && j > 0 && reference[i-1] == hypothesis[j-1] && cost[i][j] == cost[i-1][j-1]:
+			alignment.Hits++
+			i, j = i-1, j-1
+		case i > 0 && j > 0 && cost[i][j] == cost[i-1][j-1]+1:
+			alignment.Substitutions++
+			i, j = i-1, j-1
+		case i > 0 && cost[i][j] == cost[i-1][j]+1:
+			alignment.Deletions++
+			i--
+		default:
+			alignment.Insertions++
+			j--
+		}
+	}
+	return alignment
+}
@@ -0,0 +1,121 @@
+package scoring
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAlign(t *testing.T) {
+	tests := []struct {
+		name      string
+		ref, hyp  []string
+		want      Alignment
+		errorRate float64
+	}{
+		{
+			name:      "identical",
+			ref:       []string{"a", "b", "c"},
+			hyp:       []string{"a", "b", "c"},
+			want:      Alignment{Hits: 3, Reference: 3},
+			errorRate: 0,
+		},
+		{
+			name:      "substitution",
+			ref:       []string{"the", "cat", "sat"},
+			hyp:       []string{"the", "bat", "sat"},
+			want:      Alignment{Hits: 2, Substitutions: 1, Reference: 3},
+			errorRate: 1.0 / 3,
+		},
+		{
+			name:      "deletion and insertion",
+			ref:       []string{"the", "cat", "sat", "on", "the", "mat"},
+			hyp:       []string{"the", "cat", "on", "the", "big", "mat"},
+			want:      Alignment{Hits: 5, Deletions: 1, Insertions: 1, Reference: 6},
+			errorRate: 2.0 / 6,
+		},
+		{
+			name:      "empty hypothesis",
+			ref:       []string{"a", "b"},
+			hyp:       nil,
+			want:      Alignment{Deletions: 2, Reference: 2},
+			errorRate: 1,
+		},
+		{
+			name:      "empty reference",
+			ref:       nil,
+			hyp:       []string{"a"},
+			want:      Alignment{Insertions: 1},
+			errorRate: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Align(tt.ref, tt.hyp)
+			if got != tt.want {
+				t.Errorf("Align() = %+v, want %+v", got, tt.want)
+			}
+			if got.ErrorRate() != tt.errorRate {
+				t.Errorf("ErrorRate() = %v, want %v", got.ErrorRate(), tt.errorRate)
+			}
+		})
+	}
+}
+
+func TestNormalizerWords(t *testing.T) {
+	tests := []struct {
+		name       string
+		normalizer Normalizer
+		text       string
+		want       []string
+	}{
+		{
+			name:       "case and punctuation",
+			normalizer: Normalizer{IgnoreCase: true, IgnorePunctuation: true, Numbers: NumbersKeep},
+			text:       "Hello, World! Don't stop - it's 3.5 km.",
+			want:       []string{"hello", "world", "don't", "stop", "it's", "3.5", "km"},
+		},
+		{
+			name:       "keep punctuation",
+			normalizer: Normalizer{Numbers: NumbersKeep},
+			text:       "Hello, World!",
+			want:       []string{"Hello,", "World!"},
+		},
+		{
+			name:       "number words to digits",
+			normalizer: DefaultNormalizer,
+			text:       "Twenty-three cats, one hundred and five dogs, and two thousand twenty birds; call one two three",
+			want:       []string{"23", "cats", "105", "dogs", "and", "2020", "birds", "call", "1", "2", "3"},
+		},
+		{
+			name:       "digits to number words",
+			normalizer: Normalizer{IgnoreCase: true, IgnorePunctuation: true, Numbers: NumbersWords},
+			text:       "1,250 people paid 3.5 dollars",
+			want:       []string{"one", "thousand", "two", "hundred", "fifty", "people", "paid", "three", "point", "five", "dollars"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.normalizer.Words(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Words() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScore(t *testing.T) {
+	// Smart formatting writes numbers as digits, which must not count as errors
+	result := Score("I have twenty three apples.", "i have 23 apples", DefaultNormalizer)
+	if result.WER() != 0 || result.CER() != 0 {
+		t.Errorf("expected perfect score, got WER %v CER %v", result.WER(), result.CER())
+	}
+
+	result = Score("the cat sat", "the bat sat", DefaultNormalizer)
+	if result.Words.Substitutions != 1 || result.Chars.Substitutions != 1 {
+		t.Errorf("expected one word and one character substitution, got %+v", result)
+	}
+	if result.Chars.Reference != len("the cat sat") {
+		t.Errorf("expected %d reference characters, got %d", len("the cat sat"), result.Chars.Reference)
+	}
+}
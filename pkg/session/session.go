@@ -0,0 +1,401 @@
+// Package session records benchmark runs to JSONL session files and reads them
+// back, so that a run can be replayed without calling the provider again.
+//
+// A session is a "start" line describing the run, then in time order the "request"
+// lines of the requests sent to the provider, without their credentials, the "send"
+// lines of the audio chunks handed to it, the "message" lines of the raw messages it
+// returned and the "event" lines of the events it emitted, and an "end" line with
+// the outcome of the stream. A file holds the sessions of consecutive runs.
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/elishowk/speech_latency/pkg/audio"
+	"github.com/elishowk/speech_latency/pkg/events"
+)
+
+// Line kinds
+const (
+	KindStart   = "start"
+	KindRequest = "request"
+	KindSend    = "send"
+	KindMessage = "message"
+	KindEvent   = "event"
+	KindEnd     = "end"
+)
+
+// redacted replaces the credentials of recorded requests
+const redacted = "REDACTED"
+
+// Header describes the request of a recorded run
+type Header struct {
+	Run            int             `json:"run"` // number of the session in its file, from 1
+	Started        time.Time       `json:"started"`
+	Provider       string          `json:"provider"`
+	AudioPath      string          `json:"audio"`
+	SampleRate     int             `json:"sample_rate"`
+	Channels       int             `json:"channels"`
+	Encoding       string          `json:"encoding"`
+	ChunkSize      int             `json:"chunk_size"`
+	ChunkInterval  float64         `json:"chunk_interval_ms"`
+	RealtimeFactor float64         `json:"realtime_factor"`  // pacing speed of the audio, 0 when unpaced
+	Config         json.RawMessage `json:"config,omitempty"` // the provider configuration, without the API key
+}
+
+// Session is a recorded run, with times relative to Header.Started
+type Session struct {
+	Header
+	Requests []events.Request
+	Sends    []audio.Send
+	Messages []events.Message
+	Events   []events.Event
+	Err      error // the error the stream ended with
+}
+
+// Elapsed returns the time from the start of the session to t
+func (s *Session) Elapsed(t time.Time) time.Duration {
+	return t.Sub(s.Started)
+}
+
+// line is a line of a session file; the fields used depend on its kind
+type line struct {
+	Kind string `json:"kind"`
+	*Header
+	Elapsed     *float64    `json:"elapsed_ms,omitempty"`
+	URL         string      `json:"url,omitempty"`
+	HTTPHeader  http.Header `json:"header,omitempty"`
+	Data        string      `json:"data,omitempty"`      // request body or message
+	Base64      bool        `json:"base64,omitempty"`    // the data is binary, base64 encoded
+	Offset      *float64    `json:"offset_ms,omitempty"` // audio sent, including the chunk
+	Type        string      `json:"type,omitempty"`
+	AudioOffset *float64    `json:"audio_offset_ms,omitempty"`
+	Transcript  string      `json:"transcript,omitempty"`
+	Words       []word      `json:"words,omitempty"`
+	Error       string      `json:"error,omitempty"`
+}
+
+// word is a recognized word of an event line
+type word struct {
+	Word       string  `json:"word"`
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
+// Writer writes sessions to a file, numbering them; it is safe for concurrent use
+type Writer struct {
+	mu   sync.Mutex
+	w    *bufio.Writer
+	runs int
+}
+
+// NewWriter creates a session writer
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// timedLine is a line with the time it happened at, to merge the lines in time order
+type timedLine struct {
+	at time.Time
+	line
+}
+
+// Write writes a session, its lines merged in time order, and flushes it
+func (w *Writer) Write(s *Session) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.runs++
+	header := s.Header
+	header.Run = w.runs
+
+	// At the same time, requests come before the audio and messages before their events
+	var timed []timedLine
+	for _, r := range s.Requests {
+		l := line{Kind: KindRequest, URL: redactURL(r.URL), HTTPHeader: redactHeader(r.Header)}
+		l.Data, l.Base64 = encodeData(redactBody(r.Body))
+		timed = append(timed, timedLine{r.SentAt, l})
+	}
+	for _, send := range s.Sends {
+		timed = append(timed, timedLine{send.At, line{Kind: KindSend, Offset: milliseconds(send.Offset)}})
+	}
+	for _, m := range s.Messages {
+		l := line{Kind: KindMessage}
+		l.Data, l.Base64 = encodeData(m.Data)
+		timed = append(timed, timedLine{m.ReceivedAt, l})
+	}
+	for _, e := range s.Events {
+		l := line{
+			Kind:        KindEvent,
+			Type:        string(e.Type),
+			AudioOffset: milliseconds(e.AudioOffset),
+			Transcript:  e.Transcript,
+		}
+		for _, ew := range e.Words {
+			l.Words = append(l.Words, word{Word: ew.Word, Start: ew.Start, End: ew.End, Confidence: ew.Confidence})
+		}
+		if e.Err != nil {
+			l.Error = e.Err.Error()
+		}
+		timed = append(timed, timedLine{e.ReceivedAt, l})
+	}
+	sort.SliceStable(timed, func(i, j int) bool { return timed[i].at.Before(timed[j].at) })
+
+	lines := []line{{Kind: KindStart, Header: &header}}
+	for _, t := range timed {
+		t.Elapsed = milliseconds(s.Elapsed(t.at))
+		lines = append(lines, t.line)
+	}
+	end := line{Kind: KindEnd}
+	if s.Err != nil {
+		end.Error = s.Err.Error()
+	}
+	lines = append(lines, end)
+
+	encoder := json.NewEncoder(w.w)
+	for _, l := range lines {
+		if err := encoder.Encode(l); err != nil {
+			return fmt.Errorf("failed to write session: %w", err)
+		}
+	}
+	if err := w.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	return nil
+}
+
+// Load reads the sessions of a file
+func Load(path string) ([]*Session, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session file: %w", err)
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Read reads sessions written by a Writer
+func Read(r io.Reader) ([]*Session, error) {
+	var sessions []*Session
+	var current *Session
+	decoder := json.NewDecoder(r)
+	for n := 1; ; n++ {
+		var l line
+		if err := decoder.Decode(&l); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read session line %d: %w", n, err)
+		}
+		if l.Kind == KindStart {
+			if current != nil {
+				return nil, fmt.Errorf("invalid session line %d: run %d has no end", n, current.Run)
+			}
+			if l.Header == nil {
+				return nil, fmt.Errorf("invalid session line %d: no header", n)
+			}
+			current = &Session{Header: *l.Header}
+			continue
+		}
+		if current == nil {
+			return nil, fmt.Errorf("invalid session line %d: %q before start", n, l.Kind)
+		}
+
+		at := current.Started.Add(duration(l.Elapsed))
+		switch l.Kind {
+		case KindRequest:
+			body, err := decodeData(l.Data, l.Base64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid session line %d: %w", n, err)
+			}
+			current.Requests = append(current.Requests, events.Request{SentAt: at, URL: l.URL, Header: l.HTTPHeader, Body: body})
+		case KindMessage:
+			data, err := decodeData(l.Data, l.Base64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid session line %d: %w", n, err)
+			}
+			current.Messages = append(current.Messages, events.Message{ReceivedAt: at, Data: data})
+		case KindSend:
+			current.Sends = append(current.Sends, audio.Send{At: at, Offset: duration(l.Offset)})
+		case KindEvent:
+			e := events.Event{
+				Type:        events.Type(l.Type),
+				ReceivedAt:  at,
+				AudioOffset: duration(l.AudioOffset),
+				Transcript:  l.Transcript,
+			}
+			for _, w := range l.Words {
+				e.Words = append(e.Words, events.Word{Word: w.Word, Start: w.Start, End: w.End, Confidence: w.Confidence})
+			}
+			if l.Error != "" {
+				e.Err = errors.New(l.Error)
+			}
+			current.Events = append(current.Events, e)
+		case KindEnd:
+			if l.Error != "" {
+				current.Err = errors.New(l.Error)
+			}
+			sessions = append(sessions, current)
+			current = nil
+		default:
+			return nil, fmt.Errorf("invalid session line %d: unknown kind %q", n, l.Kind)
+		}
+	}
+	if current != nil {
+		return nil, fmt.Errorf("invalid session file: run %d has no end", current.Run)
+	}
+	return sessions, nil
+}
+
+// secret reports whether a header, query parameter or JSON field carries a credential,
+// from the words of its name, separated by dashes, underscores or a change of case
+func secret(name string) bool {
+	var words []string
+	word := []rune{}
+	for _, r := range name {
+		if r == '-' || r == '_' || unicode.IsUpper(r) && len(word) > 0 && unicode.IsLower(word[len(word)-1]) {
+			words = append(words, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+		if r != '-' && r != '_' {
+			word = append(word, r)
+		}
+	}
+	words = append(words, strings.ToLower(string(word)))
+	for _, w := range words {
+		switch w {
+		case "authorization", "auth", "key", "apikey", "token", "secret", "password", "signature", "credential", "credentials":
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL replaces the values of the query parameters carrying credentials
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.RawQuery == "" {
+		return raw
+	}
+	query := u.Query()
+	redact := false
+	for name := range query {
+		if secret(name) {
+			query[name] = []string{redacted}
+			redact = true
+		}
+	}
+	if redact {
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// redactHeader returns a copy of a header with the values carrying credentials replaced
+func redactHeader(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+	redactedHeader := h.Clone()
+	for name := range redactedHeader {
+		if secret(name) {
+			redactedHeader[name] = []string{redacted}
+		}
+	}
+	return redactedHeader
+}
+
+// redactBody replaces the values of the JSON fields carrying credentials, at any depth,
+// and returns any other body as is
+func redactBody(body []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return body
+	}
+	if !redactValue(value) {
+		return body
+	}
+	redactedBody, err := json.Marshal(value)
+	if err != nil {
+		return body
+	}
+	return redactedBody
+}
+
+// redactValue replaces in place the fields of decoded JSON carrying credentials and
+// reports whether it found any
+func redactValue(value any) bool {
+	found := false
+	switch v := value.(type) {
+	case map[string]any:
+		for name, field := range v {
+			if secret(name) {
+				v[name] = redacted
+				found = true
+			} else if redactValue(field) {
+				found = true
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if redactValue(item) {
+				found = true
+			}
+		}
+	}
+	return found
+}
+
+// encodeData returns text data as is and binary data base64 encoded
+func encodeData(data []byte) (string, bool) {
+	if utf8.Valid(data) {
+		return string(data), false
+	}
+	return base64.StdEncoding.EncodeToString(data), true
+}
+
+// decodeData reverses encodeData
+func decodeData(data string, isBase64 bool) ([]byte, error) {
+	if !isBase64 {
+		if data == "" {
+			return nil, nil
+		}
+		return []byte(data), nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 data: %w", err)
+	}
+	return decoded, nil
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) *float64 {
+	ms := float64(d) / float64(time.Millisecond)
+	return &ms
+}
+
+// duration converts fractional milliseconds to a duration
+func duration(ms *float64) time.Duration {
+	if ms == nil {
+		return 0
+	}
+	return time.Duration(math.Round(*ms * float64(time.Millisecond)))
+}
@@ -0,0 +1,144 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elishowk/speech_latency/pkg/audio"
+	"github.com/elishowk/speech_latency/pkg/events"
+)
+
+func TestWriteRead(t *testing.T) {
+	started := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return started.Add(time.Duration(ms) * time.Millisecond) }
+	recorded := &Session{
+		Header: Header{Started: started, Provider: "deepgram", SampleRate: 16000, Channels: 1, RealtimeFactor: 2, Config: []byte(`{"Language":"en-US"}`)},
+		Requests: []events.Request{{
+			SentAt: at(0),
+			URL:    "wss://api.example.com/v1/listen?keyterm=hello&token=secret",
+			Header: http.Header{"Authorization": {"Token secret"}, "X-Amz-Security-Token": {"secret"}, "Content-Type": {"audio/wav"}},
+			Body:   []byte(`{"config":{}}`),
+		}},
+		Messages: []events.Message{
+			{ReceivedAt: at(150), Data: []byte(`{"transcript":"hel"}`)},
+			{ReceivedAt: at(250), Data: []byte{0, 0xff, 1}},
+		},
+		Sends: []audio.Send{
+			{At: at(100), Offset: 100 * time.Millisecond},
+			{At: at(200), Offset: 200 * time.Millisecond},
+		},
+		Events: []events.Event{
+			{Type: events.Opened, ReceivedAt: at(5)},
+			{Type: events.Interim, ReceivedAt: at(150), AudioOffset: 100 * time.Millisecond, Transcript: "hel"},
+			{Type: events.Final, ReceivedAt: at(250), AudioOffset: 200 * time.Millisecond, Transcript: "hello",
+				Words: []events.Word{{Word: "hello", Start: 0.05, End: 0.18, Confidence: 0.9}}},
+			{Type: events.Error, ReceivedAt: at(260), Err: errors.New("connection reset")},
+		},
+		Err: errors.New("connection reset"),
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.Write(recorded); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Write(&Session{Header: Header{Started: started, Provider: "vosk"}}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// Requests, sends, messages and events are interleaved in time order
+	var kinds []string
+	for _, l := range strings.Split(strings.TrimSpace(buf.String()), "\n")[:10] {
+		kinds = append(kinds, l[len(`{"kind":"`):strings.Index(l, `",`)])
+	}
+	if got := strings.Join(kinds, " "); got != "start request event send message event send message event event" {
+		t.Errorf("unexpected line order %s", got)
+	}
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("credentials were recorded:\n%s", buf.String())
+	}
+
+	sessions, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].Run != 1 || sessions[1].Run != 2 || sessions[1].Provider != "vosk" {
+		t.Fatalf("unexpected sessions %+v", sessions)
+	}
+	got := sessions[0]
+	if !got.Started.Equal(started) || got.Provider != "deepgram" || got.RealtimeFactor != 2 || string(got.Config) != `{"Language":"en-US"}` {
+		t.Errorf("unexpected header %+v", got.Header)
+	}
+	if len(got.Sends) != 2 || !got.Sends[1].At.Equal(at(200)) || got.Sends[1].Offset != 200*time.Millisecond {
+		t.Errorf("unexpected sends %+v", got.Sends)
+	}
+	if len(got.Events) != 4 {
+		t.Fatalf("unexpected events %+v", got.Events)
+	}
+	final := got.Events[2]
+	if final.Type != events.Final || !final.ReceivedAt.Equal(at(250)) || final.AudioOffset != 200*time.Millisecond ||
+		final.Transcript != "hello" || len(final.Words) != 1 || final.Words[0] != recorded.Events[2].Words[0] {
+		t.Errorf("unexpected final %+v", final)
+	}
+	if got.Events[3].Err == nil || got.Err == nil || got.Err.Error() != "connection reset" {
+		t.Errorf("errors not restored: %v, %v", got.Events[3].Err, got.Err)
+	}
+	if len(got.Requests) != 1 || !got.Requests[0].SentAt.Equal(at(0)) || string(got.Requests[0].Body) != `{"config":{}}` ||
+		got.Requests[0].URL != "wss://api.example.com/v1/listen?keyterm=hello&token=REDACTED" ||
+		got.Requests[0].Header.Get("Authorization") != "REDACTED" || got.Requests[0].Header.Get("Content-Type") != "audio/wav" {
+		t.Errorf("unexpected requests %+v", got.Requests)
+	}
+	if len(got.Messages) != 2 || !got.Messages[0].ReceivedAt.Equal(at(150)) || string(got.Messages[0].Data) != `{"transcript":"hel"}` ||
+		!bytes.Equal(got.Messages[1].Data, []byte{0, 0xff, 1}) {
+		t.Errorf("unexpected messages %+v", got.Messages)
+	}
+	if sessions[1].Err != nil {
+		t.Errorf("unexpected error %v", sessions[1].Err)
+	}
+}
+
+func TestWrite_RedactsBody(t *testing.T) {
+	started := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	bodies := map[string]string{
+		// A start message rendered from a websocket-generic template with the API key
+		`{"type":"start","config":{"apiKey":"secret"},"sessions":[{"access_token":"secret"}],"sample_rate":16000,"duration":1.50}`: `{"config":{"apiKey":"REDACTED"},"duration":1.50,"sample_rate":16000,"sessions":[{"access_token":"REDACTED"}],"type":"start"}`,
+		`{"type":"start","sample_rate":16000}`: `{"type":"start","sample_rate":16000}`,
+		`{"auth":{"user":"me"}}`:               `{"auth":"REDACTED"}`,
+		`start`:                                `start`,
+	}
+	for body, want := range bodies {
+		var buf bytes.Buffer
+		recorded := &Session{
+			Header:   Header{Started: started, Provider: "websocket-generic"},
+			Requests: []events.Request{{SentAt: started, URL: "wss://api.example.com/v1/stream", Body: []byte(body)}},
+		}
+		if err := NewWriter(&buf).Write(recorded); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		sessions, err := Read(&buf)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if got := string(sessions[0].Requests[0].Body); got != want {
+			t.Errorf("expected body %s to be recorded as %s, got %s", body, want, got)
+		}
+	}
+}
+
+func TestRead_Errors(t *testing.T) {
+	for name, text := range map[string]string{
+		"not JSON":     "start\n",
+		"no start":     `{"kind":"send","elapsed_ms":1}` + "\n",
+		"no end":       `{"kind":"start","run":1}` + "\n",
+		"unknown kind": `{"kind":"start","run":1}` + "\n" + `{"kind":"response"}` + "\n",
+		"bad base64":   `{"kind":"start","run":1}` + "\n" + `{"kind":"message","data":"!","base64":true}` + "\n",
+	} {
+		if _, err := Read(strings.NewReader(text)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}